@@ -15,10 +15,14 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/DataBridgeTech/dbqcore"
 	"github.com/DataBridgeTech/dbqctl/internal"
@@ -26,15 +30,18 @@ import (
 	"github.com/spf13/cobra"
 )
 
-type FailedCheckDetails struct {
-	Expression string
-	Dataset    string
-	ActualVal  string
-	Err        string
-}
-
 func NewCheckCommand(app internal.DbqCliApp) *cobra.Command {
 	var checksFile string
+	var outputFormat string
+	var maxConcurrent int
+	var dryRun bool
+	var noHistory bool
+	var selector internal.CheckSelector
+	var onlyFailedFrom string
+	var failOn string
+	var failedRows int
+	var maxGroups int
+	var strict bool
 
 	cmd := &cobra.Command{
 		Use:   "check",
@@ -43,84 +50,155 @@ func NewCheckCommand(app internal.DbqCliApp) *cobra.Command {
 which outlines the rules and constraints that the data within the dataset should adhere to. For each defined check, the command analyzes the dataset and reports any violations or inconsistencies found.
 
 By automating these checks, you can proactively identify and address data quality issues, ensuring that your datasets meet the required standards for analysis and decision-making.
+
+Results can be printed as plain text (default), JSON, JUnit XML (for CI test reports), Markdown (e.g. for PR comments)
+or a self-contained HTML report using the --output flag.
+Failed checks are reported with the severity of their 'on_fail' setting: info, warn, error (default) or critical.
+The exit code is 1 if checks at or above --fail-on failed, 2 for config problems or checks which couldn't be evaluated
+(e.g. connection errors) and, with --strict, 3 if only checks with lower severities (warn and above) failed.
+
+With --failed-rows N (or a per-check 'sample_failures' setting) up to N offending rows of failed not_null and uniqueness
+checks are fetched, e.g. the duplicated keys with their counts. Orphaned keys of failed foreign_key checks are always
+sampled. Columns listed in 'failed_rows.mask_columns' of dbq config are masked.
+
+Checks with 'group_by' or 'partition_by' (set on the check or its rule) are evaluated once per group of rows, e.g. per
+county or per day, up to --max-groups groups. Such a check fails if it fails for any group and the failed groups are reported.
+
+With --dry-run nothing is executed, the SQL of every check is printed along with the check id used by 'dbqctl explain'.
+
+A subset of checks can be selected by data source, dataset, tag, check (or rule) id or failures of a previous run,
+checks not selected are reported as skipped.
 `,
-		RunE: func(cmd *cobra.Command, args []string) error {
-			slog.Debug("Reading checks configuration file",
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			// anything preventing the checks from running is a config problem, see ExitCodeConfigError
+			defer func() { err = configError(cmd, err) }()
+
+			reportWriter, err := NewCheckReportWriter(outputFormat)
+			if err != nil {
+				return err
+			}
+			failOnSeverity, err := internal.ParseSeverity(failOn)
+			if err != nil {
+				return fmt.Errorf("invalid --fail-on: %w", err)
+			}
+			if maxGroups < 1 {
+				return fmt.Errorf("invalid --max-groups %d: expected a positive number", maxGroups)
+			}
+
+			// the run id correlates logs of parallel checks and is the id of the run in results history
+			runStartedAt := time.Now()
+			runId := internal.NewHistoryRunId(runStartedAt)
+			ctx := internal.WithLogAttrs(cmd.Context(), slog.String("run_id", runId))
+
+			slog.DebugContext(ctx, "Reading checks configuration file",
 				"checks_config_path", checksFile)
 
-			checksCfg, err := dbqcore.LoadChecksFileConfig(checksFile)
+			checksCfg, err := internal.LoadChecksFile(checksFile, app.IsLenient())
 			if err != nil {
 				return fmt.Errorf("error while loading checks configuration file: %w", err)
 			}
+			for _, warning := range checksCfg.Warnings {
+				fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+			}
 
-			exitCode := 0
-			passedCount := 0
-			var failedChecks []FailedCheckDetails
+			if err := selector.Validate(); err != nil {
+				return err
+			}
+			if onlyFailedFrom != "" {
+				history, err := openHistoryStore(app)
+				if err != nil {
+					return err
+				}
+				_, previousResults, err := history.GetRun(onlyFailedFrom)
+				if err != nil {
+					return err
+				}
+				selector.FailedCheckKeys = internal.FailedCheckKeys(previousResults)
+				selector.Environment = app.GetEnvironment()
+			}
 
-			for _, rule := range checksCfg.Rules {
-				dataSourceId, datasets, err := parseDatasetString(rule.Dataset)
+			// checks are selected before any connection is opened
+			var tasks []checkTask
+			skipped := 0
+			for ruleIdx := range checksCfg.Rules {
+				rule := &checksCfg.Rules[ruleIdx]
+				dataSourceId, datasets, err := internal.ParseDatasetString(rule.Dataset)
 				if err != nil {
 					return fmt.Errorf("error while parsing dataset property: %w", err)
 				}
 
+				if !selector.SelectsDataSource(dataSourceId) {
+					skipped += len(datasets) * len(rule.Checks)
+					continue
+				}
+
 				dataSource := app.FindDataSourceById(dataSourceId)
 				if dataSource == nil {
 					return fmt.Errorf("specified data source not found in dbq configuration: %s", dataSourceId)
 				}
 
 				for _, dataset := range datasets {
-					fmt.Printf("running %d quality checks for '%s'\n", len(rule.Checks), dataset)
 					for _, check := range rule.Checks {
-						validationResult := app.RunCheck(&check, dataSource, dataset, rule.Where)
-
-						checkLabel := check.Expression
-						if check.Description != "" {
-							checkLabel = check.Description
-						}
-
-						fmt.Printf("  %s: %s \n", getCheckResultLabel(validationResult.Pass), checkLabel)
-						if validationResult.Pass {
-							passedCount += 1
-						} else {
-							failedChecks = append(failedChecks, FailedCheckDetails{
-								Expression: check.Expression,
-								Dataset:    dataset,
-								ActualVal:  validationResult.QueryResultValue,
-								Err:        validationResult.Error,
-							})
-						}
-
-						if !validationResult.Pass && strGetOrDefault(string(check.OnFail), string(dbqcore.OnFailActionError)) == string(dbqcore.OnFailActionError) {
-							exitCode = 1
+						if !selector.Selects(dataSourceId, dataset, rule, &check) {
+							skipped += 1
+							continue
 						}
+						tasks = append(tasks, checkTask{
+							dataSource: dataSource,
+							dataset:    dataset,
+							where:      rule.Where,
+							check:      check,
+							failedRows: failedRows,
+							maxGroups:  maxGroups,
+						})
 					}
 				}
 			}
 
-			if len(failedChecks) != 0 {
-				for _, result := range failedChecks {
-					fmt.Println()
-					fmt.Printf("--- %s : %s ---\n", result.Dataset, result.Expression)
-					if result.ActualVal != "" {
-						units := ""
-						if strings.HasPrefix(result.Expression, "freshness") {
-							units = " (diff in seconds)"
-						}
+			if dryRun {
+				return printCheckQueries(ctx, cmd, app, tasks, skipped)
+			}
 
-						fmt.Printf("actual value: %s%s\n", result.ActualVal, units)
-					}
-					if result.Err != "" {
-						fmt.Printf("error: %s\n", result.Err)
+			slog.DebugContext(ctx, "Running quality checks",
+				"checks_count", len(tasks),
+				"jobs", maxConcurrent)
+
+			report := &CheckReport{ChecksFile: checksFile, Environment: app.GetEnvironment(), Skipped: skipped}
+
+			results, executed := runCheckTasks(ctx, app, tasks, maxConcurrent)
+			for i, result := range results {
+				if !executed[i] {
+					report.NotExecuted += 1
+					continue
+				}
+
+				if anomalyCfg := tasks[i].check.Anomaly; anomalyCfg != nil && result.Err == "" {
+					result.Anomaly = evaluateCheckAnomaly(app, anomalyCfg, &result)
+					if result.Anomaly.Detected {
+						result.Pass = false
 					}
 				}
+
+				report.AddResult(result)
 			}
 
-			fmt.Println()
-			failedCount := len(failedChecks)
-			fmt.Printf("\ncheck result: %s. %d passed; %d failed; \n", getCheckResultLabel(failedCount == 0), passedCount, failedCount)
+			report.Duration = time.Since(runStartedAt)
+			report.Interrupted = cmd.Context().Err() != nil
+			report.ExitCode = checkRunExitCode(report.Results, failOnSeverity, strict)
+
+			if !noHistory {
+				if err := saveCheckRunHistory(app, checksCfg, report, runId, runStartedAt); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: failed to save check results history: %s\n", err)
+				}
+			}
+			if err := reportWriter.Write(os.Stdout, report); err != nil {
+				return fmt.Errorf("error while writing check results: %w", err)
+			}
 
-			if exitCode != 0 {
-				os.Exit(exitCode)
+			if report.ExitCode != 0 {
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+				return &ExitCodeError{Code: report.ExitCode}
 			}
 
 			return nil
@@ -129,43 +207,263 @@ By automating these checks, you can proactively identify and address data qualit
 
 	cmd.Flags().StringVarP(&checksFile, "checks", "c", "", "path to data quality checks file")
 	_ = cmd.MarkFlagRequired("checks")
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", OutputFormatText, "output format of check results: text, json, junit, markdown or html")
+	cmd.Flags().IntVarP(&maxConcurrent, "jobs", "j", runtime.NumCPU(), "set the maximum number of checks to execute in parallel across datasets and data sources. By default, this is equal to the number of CPUs on the host machine.")
+	cmd.Flags().BoolVar(&noHistory, "no-history", false, "do not save results of this run to the check results history")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the SQL of every check instead of running it")
+	cmd.Flags().StringVar(&failOn, "fail-on", string(internal.SeverityError), "lowest severity of failed checks causing exit code 1: info, warn, error or critical")
+	cmd.Flags().IntVar(&failedRows, "failed-rows", 0, "sample up to N offending rows of every failed not_null, uniqueness and foreign_key check, a check can override it with 'sample_failures'")
+	cmd.Flags().IntVar(&maxGroups, "max-groups", internal.DefaultMaxGroups, "evaluate checks with 'group_by' or 'partition_by' for at most N groups of rows")
+	cmd.Flags().BoolVar(&strict, "strict", false, "exit with code 3 if checks failed only with severities below --fail-on (warn and above)")
+	cmd.Flags().StringSliceVar(&selector.DataSources, "datasource", nil, "run only checks of the data source, can be repeated")
+	cmd.Flags().StringSliceVar(&selector.Datasets, "dataset", nil, "run only checks of datasets matching the glob pattern (e.g. 'nyc_taxi.*'), can be repeated")
+	cmd.Flags().StringSliceVar(&selector.Tags, "tag", nil, "run only checks with the tag (own or of their rule), can be repeated")
+	cmd.Flags().StringSliceVar(&selector.ExcludeTags, "exclude-tag", nil, "skip checks with the tag, can be repeated")
+	cmd.Flags().StringSliceVar(&selector.CheckIds, "check-id", nil, "run only the check with the id, or all checks of the rule with the id, can be repeated")
+	cmd.Flags().StringVar(&onlyFailedFrom, "only-failed-from", "", "run only checks which failed in the given run from results history ('latest' for the most recent run)")
 
 	return cmd
 }
 
-func parseDatasetString(input string) (datasource string, datasets []string, err error) {
-	atIndex := strings.Index(input, "@")
-	if atIndex == -1 {
-		return "", nil, fmt.Errorf("invalid dataset string format: %s", input)
+type checkTask struct {
+	dataSource *dbqcore.DataSource
+	dataset    string
+	where      string
+	check      internal.Check
+	// failedRows is the --failed-rows sample size, used unless the check sets its own
+	failedRows int
+	// maxGroups limits the number of groups of grouped checks, see --max-groups
+	maxGroups int
+}
+
+// runCheckTasks executes tasks using at most maxConcurrent workers, results are returned in the same
+// order as the given tasks. Once ctx is cancelled no new tasks are started, executed reports which ones ran
+func runCheckTasks(ctx context.Context, app internal.DbqCliApp, tasks []checkTask, maxConcurrent int) (results []CheckResult, executed []bool) {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+
+	results = make([]CheckResult, len(tasks))
+	executed = make([]bool, len(tasks))
+	taskIdx := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(maxConcurrent, len(tasks)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range taskIdx {
+				results[i] = runCheckTask(ctx, app, &tasks[i])
+				executed[i] = true
+			}
+		}()
+	}
+
+dispatch:
+	for i := range tasks {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case taskIdx <- i:
+		}
+	}
+	close(taskIdx)
+	wg.Wait()
+
+	return results, executed
+}
+
+// checkRunExitCode applies the exit code policy: checks which couldn't be evaluated (e.g. due to connection problems)
+// take precedence over failed checks at or above failOn, failures below failOn only count with strict (warn and above)
+func checkRunExitCode(results []CheckResult, failOn internal.Severity, strict bool) int {
+	errored, failed, warned := false, false, false
+	for _, result := range results {
+		switch severity := internal.Severity(result.OnFail); {
+		case result.Pass:
+		case result.Err != "":
+			errored = true
+		case severity.AtLeast(failOn):
+			failed = true
+		case strict && severity.AtLeast(internal.SeverityWarn):
+			warned = true
+		}
 	}
 
-	datasource = strings.TrimSpace(input[:atIndex])
-	if datasource == "" {
-		return "", nil, fmt.Errorf("datasource part cannot be empty: %s", input)
+	switch {
+	case errored:
+		return ExitCodeConfigError
+	case failed:
+		return ExitCodeChecksFailed
+	case warned:
+		return ExitCodeWarnings
+	default:
+		return 0
 	}
+}
+
+// printCheckQueries prints the SQL every task would run as a SQL script, nothing is executed
+func printCheckQueries(ctx context.Context, cmd *cobra.Command, app internal.DbqCliApp, tasks []checkTask, skipped int) error {
+	renderErrors := 0
+	for i, task := range tasks {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("-- [%s] %s@%s: %s\n", task.check.ID, task.dataSource.ID, task.dataset, task.check.Expression)
+		if task.check.Description != "" {
+			fmt.Printf("-- %s\n", strings.Join(strings.Fields(task.check.Description), " "))
+		}
+		if len(task.check.GroupBy) > 0 {
+			fmt.Printf("-- evaluated per group of %s, the query runs once per group with the group condition added to 'where'\n",
+				strings.Join(task.check.GroupBy, ", "))
+		}
 
-	datasetPart := strings.TrimSpace(input[atIndex+1:])
-	if !strings.HasPrefix(datasetPart, "[") || !strings.HasSuffix(datasetPart, "]") {
-		return "", nil, fmt.Errorf("invalid dataset format (expected '[dataset1, dataset2,...]'): %s", input)
+		if strings.TrimSpace(task.check.Expression) == internal.CheckFuncSchemaCheck {
+			fmt.Println("-- schema_check compares the dataset columns with the expected ones, there is no query to render")
+			continue
+		}
+
+		query, err := app.RenderCheckQuery(ctx, &task.check.DataQualityCheck, task.dataSource, task.dataset, task.where)
+		if err != nil {
+			fmt.Printf("-- error: %s\n", err)
+			renderErrors += 1
+			continue
+		}
+		fmt.Printf("%s;\n", strings.TrimRight(strings.TrimSpace(query), ";"))
+	}
+
+	fmt.Fprintf(os.Stderr, "rendered %d check(s), %d skipped by selection, nothing was executed\n", len(tasks)-renderErrors, skipped)
+	if renderErrors > 0 {
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		return &ExitCodeError{Code: 1}
+	}
+	return nil
+}
+
+func runCheckTask(ctx context.Context, app internal.DbqCliApp, task *checkTask) CheckResult {
+	ctx, cancel := withTimeout(ctx, task.check.Timeout)
+	defer cancel()
+
+	if len(task.check.GroupBy) > 0 {
+		return runGroupedCheckTask(ctx, app, task)
 	}
 
-	// slice off '[' and ']'
-	datasetsContent := datasetPart[1 : len(datasetPart)-1]
-	trimmedContent := strings.TrimSpace(datasetsContent)
-	if trimmedContent == "" {
-		return "", nil, fmt.Errorf("dataset part can't be empty: %s", input)
+	startedAt := time.Now()
+	validationResult := app.RunCheck(ctx, &task.check.DataQualityCheck, task.dataSource, task.dataset, task.where)
+
+	result := CheckResult{
+		DataSource:  task.dataSource.ID,
+		Dataset:     task.dataset,
+		Expression:  task.check.Expression,
+		Description: task.check.Description,
+		OnFail:      string(task.check.Severity),
+		Pass:        validationResult.Pass,
+		ActualVal:   validationResult.QueryResultValue,
+		Err:         validationResult.Error,
+		Duration:    time.Since(startedAt),
 	}
 
-	rawDatasets := strings.Split(datasetsContent, ",")
-	datasets = make([]string, 0, len(rawDatasets))
-	for _, ds := range rawDatasets {
-		cleanedDS := strings.TrimSpace(ds)
-		if cleanedDS != "" {
-			datasets = append(datasets, cleanedDS)
+	sampleSize := task.check.SampleFailures
+	if sampleSize == 0 {
+		sampleSize = task.failedRows
+	}
+	if sampleSize == 0 && internal.IsForeignKeyCheck(task.check.Expression) {
+		// orphans are always sampled, they are the starting point of fixing the data
+		sampleSize = internal.DefaultOrphansSample
+	}
+	if !result.Pass && result.Err == "" && sampleSize > 0 && internal.SupportsFailedRows(task.check.Expression) {
+		failedRows, err := app.SampleFailedRows(ctx, &task.check.DataQualityCheck, task.dataSource, task.dataset, task.where, sampleSize)
+		if err != nil {
+			result.FailedRowsErr = err.Error()
+		} else {
+			result.FailedRows = failedRows
 		}
 	}
+	return result
+}
+
+// runGroupedCheckTask evaluates the check for every group of the dataset rows, the check passes if it passed for all groups.
+// Failed rows aren't sampled for grouped checks, the failed groups point to the offending rows instead
+func runGroupedCheckTask(ctx context.Context, app internal.DbqCliApp, task *checkTask) CheckResult {
+	startedAt := time.Now()
+	groupsResult := &CheckGroupsResult{GroupBy: task.check.GroupBy, FailedGroups: []CheckGroupResult{}}
+	result := CheckResult{
+		DataSource:  task.dataSource.ID,
+		Dataset:     task.dataset,
+		Expression:  task.check.Expression,
+		Description: task.check.Description,
+		OnFail:      string(task.check.Severity),
+		Groups:      groupsResult,
+	}
+
+	// one more group than needed tells whether there are groups left unchecked
+	groups, err := app.ListCheckGroups(ctx, task.dataSource, task.dataset, task.where, task.check.GroupBy, task.maxGroups+1)
+	if err != nil {
+		result.Err = fmt.Sprintf("failed to list groups: %s", err)
+		result.Duration = time.Since(startedAt)
+		return result
+	}
+	if len(groups) > task.maxGroups {
+		groups = groups[:task.maxGroups]
+		groupsResult.Truncated = true
+	}
+
+	var firstErr string
+	for _, group := range groups {
+		where := internal.CombineWhere(task.where, group.Where)
+		validationResult := app.RunCheck(ctx, &task.check.DataQualityCheck, task.dataSource, task.dataset, where)
+		groupsResult.Total += 1
+		groupResult := CheckGroupResult{Group: group.Key, Label: group.Label(task.check.GroupBy), ActualVal: validationResult.QueryResultValue}
+		switch {
+		case validationResult.Error != "":
+			groupsResult.Errored += 1
+			groupResult.Err = validationResult.Error
+			if firstErr == "" {
+				firstErr = validationResult.Error
+			}
+		case validationResult.Pass:
+			groupsResult.Passed += 1
+			continue
+		default:
+			groupsResult.Failed += 1
+		}
+		groupsResult.FailedGroups = append(groupsResult.FailedGroups, groupResult)
+	}
+
+	result.Pass = groupsResult.Failed == 0 && groupsResult.Errored == 0
+	if groupsResult.Failed == 0 && groupsResult.Errored > 0 {
+		// groups which failed make the check fail, errors only matter if nothing else is known
+		result.Err = fmt.Sprintf("%d of %d groups couldn't be evaluated: %s", groupsResult.Errored, groupsResult.Total, firstErr)
+	}
+	result.Duration = time.Since(startedAt)
+	return result
+}
+
+// evaluateCheckAnomaly compares the check value with values of the same check from previous runs
+func evaluateCheckAnomaly(app internal.DbqCliApp, anomalyCfg *internal.AnomalyConfig, result *CheckResult) *internal.AnomalyResult {
+	history, err := app.GetHistoryStore()
+	if err != nil || history == nil {
+		reason := "check results history is disabled"
+		if err != nil {
+			reason = fmt.Sprintf("check results history is not available: %s", err)
+		}
+		return &internal.AnomalyResult{Method: anomalyCfg.Method, Threshold: anomalyCfg.Threshold, Skipped: reason}
+	}
+
+	checkKey := internal.HistoryCheckKey(app.GetEnvironment(), result.DataSource, result.Dataset, result.Expression, result.Description)
+	previousResults, err := history.CheckTrend(checkKey, anomalyCfg.Window)
+	if err != nil {
+		return &internal.AnomalyResult{Method: anomalyCfg.Method, Threshold: anomalyCfg.Threshold, Skipped: err.Error()}
+	}
 
-	return datasource, datasets, nil
+	var previousValues []string
+	for _, previous := range previousResults {
+		if previous.Error == "" {
+			previousValues = append(previousValues, previous.ActualValue)
+		}
+	}
+
+	return internal.EvaluateAnomaly(anomalyCfg, result.ActualVal, previousValues)
 }
 
 func getCheckResultLabel(passed bool) string {
@@ -175,10 +473,3 @@ func getCheckResultLabel(passed bool) string {
 		return "FAILED"
 	}
 }
-
-func strGetOrDefault(original string, defaultVal string) string {
-	if original == "" {
-		return defaultVal
-	}
-	return original
-}
@@ -0,0 +1,701 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DataBridgeTech/dbqctl/internal"
+)
+
+const (
+	OutputFormatText     = "text"
+	OutputFormatJson     = "json"
+	OutputFormatJUnit    = "junit"
+	OutputFormatMarkdown = "markdown"
+	OutputFormatHtml     = "html"
+)
+
+// CheckResult holds the outcome of a single check executed against a single dataset
+type CheckResult struct {
+	DataSource  string        `json:"datasource"`
+	Dataset     string        `json:"dataset"`
+	Expression  string        `json:"expression"`
+	Description string        `json:"description,omitempty"`
+	OnFail      string        `json:"on_fail"`
+	Pass        bool          `json:"pass"`
+	ActualVal   string        `json:"actual_value,omitempty"`
+	Err         string        `json:"error,omitempty"`
+	Duration    time.Duration `json:"-"`
+	DurationMs  int64         `json:"duration_ms"`
+	// Anomaly is set for checks with anomaly detection enabled
+	Anomaly *internal.AnomalyResult `json:"anomaly,omitempty"`
+	// FailedRows is the sample of offending rows, set for failed checks with --failed-rows or 'sample_failures'
+	FailedRows    *internal.FailedRows `json:"failed_rows,omitempty"`
+	FailedRowsErr string               `json:"failed_rows_error,omitempty"`
+	// Groups is set for checks evaluated per group with 'group_by' or 'partition_by'
+	Groups *CheckGroupsResult `json:"groups,omitempty"`
+}
+
+// CheckGroupsResult summarizes a check evaluated per group, the check passes if it passed for every group
+type CheckGroupsResult struct {
+	GroupBy []string `json:"group_by"`
+	Total   int      `json:"total"`
+	Passed  int      `json:"passed"`
+	Failed  int      `json:"failed"`
+	Errored int      `json:"errored,omitempty"`
+	// Truncated is set if the dataset has more groups than --max-groups, only the first ones were evaluated
+	Truncated bool `json:"truncated,omitempty"`
+	// FailedGroups lists the groups which failed or couldn't be evaluated
+	FailedGroups []CheckGroupResult `json:"failed_groups"`
+}
+
+// CheckGroupResult is the outcome of a check for a single group
+type CheckGroupResult struct {
+	// Group maps every group expression to its value in the group
+	Group     map[string]string `json:"group"`
+	Label     string            `json:"-"`
+	ActualVal string            `json:"actual_value,omitempty"`
+	Err       string            `json:"error,omitempty"`
+}
+
+// Summary counts the evaluated groups, e.g. '14 groups by county: 12 passed, 2 failed'
+func (g *CheckGroupsResult) Summary() string {
+	summary := fmt.Sprintf("%d groups by %s: %d passed, %d failed", g.Total, strings.Join(g.GroupBy, ", "), g.Passed, g.Failed)
+	if g.Errored > 0 {
+		summary += fmt.Sprintf(", %d errored", g.Errored)
+	}
+	if g.Truncated {
+		summary += " (more groups were not checked, see --max-groups)"
+	}
+	return summary
+}
+
+// DisplayValue is the actual value of the check, or the groups summary for grouped checks
+func (r *CheckResult) DisplayValue() string {
+	if r.Groups != nil {
+		return r.Groups.Summary()
+	}
+	return r.ActualVal
+}
+
+// StatusLabel labels the check outcome, failed checks are labeled by their severity
+func (r *CheckResult) StatusLabel() string {
+	switch {
+	case r.Pass:
+		return "ok"
+	case r.Err != "":
+		return "ERROR"
+	}
+	switch internal.Severity(r.OnFail) {
+	case internal.SeverityCritical:
+		return "CRITICAL"
+	case internal.SeverityWarn:
+		return "WARNING"
+	case internal.SeverityInfo:
+		return "INFO"
+	default:
+		return "FAILED"
+	}
+}
+
+// Label returns the check description if present, otherwise the check expression
+func (r *CheckResult) Label() string {
+	if r.Description != "" {
+		return r.Description
+	}
+	return r.Expression
+}
+
+// CheckReport is the full set of results of a 'check' run, passed as is to output writers
+type CheckReport struct {
+	RunID       string `json:"run_id,omitempty"`
+	ChecksFile  string `json:"checks_file"`
+	Environment string `json:"environment,omitempty"`
+	Passed      int    `json:"passed"`
+	Failed      int    `json:"failed"`
+	// FailedBySeverity counts the failed checks by their severity
+	FailedBySeverity map[string]int `json:"failed_by_severity,omitempty"`
+	// Errored is the number of checks which couldn't be evaluated, e.g. due to connection problems
+	Errored int `json:"errored,omitempty"`
+	// ExitCode is the exit code of the run according to --fail-on and --strict
+	ExitCode    int  `json:"exit_code"`
+	Interrupted bool `json:"interrupted,omitempty"`
+	NotExecuted int  `json:"not_executed,omitempty"`
+	// Skipped is the number of checks not selected for the run
+	Skipped    int           `json:"skipped,omitempty"`
+	Duration   time.Duration `json:"-"`
+	DurationMs int64         `json:"duration_ms"`
+	Results    []CheckResult `json:"results"`
+}
+
+func (r *CheckReport) AddResult(result CheckResult) {
+	result.DurationMs = result.Duration.Milliseconds()
+	r.Results = append(r.Results, result)
+	switch {
+	case result.Pass:
+		r.Passed += 1
+	case result.Err != "":
+		r.Errored += 1
+	default:
+		r.Failed += 1
+		if r.FailedBySeverity == nil {
+			r.FailedBySeverity = make(map[string]int)
+		}
+		r.FailedBySeverity[result.OnFail] += 1
+	}
+}
+
+// severityCounts lists failed checks per severity from the most severe one, e.g. 'critical: 1, warn: 2'
+func (r *CheckReport) severityCounts() []string {
+	var counts []string
+	for i := len(internal.Severities) - 1; i >= 0; i-- {
+		severity := string(internal.Severities[i])
+		if count := r.FailedBySeverity[severity]; count > 0 {
+			counts = append(counts, fmt.Sprintf("%s: %d", severity, count))
+		}
+	}
+	return counts
+}
+
+func (r *CheckReport) FailedResults() []CheckResult {
+	var failed []CheckResult
+	for _, result := range r.Results {
+		if !result.Pass {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+type CheckReportWriter interface {
+	Write(w io.Writer, report *CheckReport) error
+}
+
+func NewCheckReportWriter(format string) (CheckReportWriter, error) {
+	switch strings.ToLower(format) {
+	case "", OutputFormatText:
+		return &textReportWriter{}, nil
+	case OutputFormatJson:
+		return &jsonReportWriter{}, nil
+	case OutputFormatJUnit:
+		return &junitReportWriter{}, nil
+	case OutputFormatMarkdown, "md":
+		return &markdownReportWriter{}, nil
+	case OutputFormatHtml:
+		return &htmlReportWriter{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported output format '%s' (expected one of: %s, %s, %s, %s, %s)",
+			format, OutputFormatText, OutputFormatJson, OutputFormatJUnit, OutputFormatMarkdown, OutputFormatHtml)
+	}
+}
+
+type textReportWriter struct{}
+
+func (tw *textReportWriter) Write(w io.Writer, report *CheckReport) error {
+	for _, group := range groupResultsByDataset(report.Results) {
+		fmt.Fprintf(w, "running %d quality checks for '%s'\n", len(group), group[0].Dataset)
+		for _, result := range group {
+			if result.Groups != nil {
+				fmt.Fprintf(w, "  %s: %s [%s] \n", result.StatusLabel(), result.Label(), result.Groups.Summary())
+				continue
+			}
+			fmt.Fprintf(w, "  %s: %s \n", result.StatusLabel(), result.Label())
+		}
+	}
+
+	for _, result := range report.FailedResults() {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "--- %s : %s ---\n", result.Dataset, result.Expression)
+		if result.ActualVal != "" {
+			fmt.Fprintf(w, "actual value: %s%s\n", result.ActualVal, getActualValueUnits(result.Expression))
+		}
+		if result.Anomaly != nil {
+			fmt.Fprintf(w, "anomaly: %s\n", result.Anomaly.Describe())
+		}
+		if result.Err != "" {
+			fmt.Fprintf(w, "error: %s\n", result.Err)
+		}
+		writeFailedGroupsText(w, &result)
+		if err := writeFailedRowsText(w, &result); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w)
+	if report.Interrupted {
+		fmt.Fprintf(w, "\ncheck run was interrupted, %d checks were not executed\n", report.NotExecuted)
+	}
+	fmt.Fprintf(w, "\ncheck result: %s. %d passed; %d failed; ", getCheckResultLabel(report.ExitCode == 0), report.Passed, report.Failed)
+	if counts := report.severityCounts(); len(counts) > 0 {
+		fmt.Fprintf(w, "(%s) ", strings.Join(counts, ", "))
+	}
+	if report.Errored > 0 {
+		fmt.Fprintf(w, "%d errored; ", report.Errored)
+	}
+	if report.Skipped > 0 {
+		fmt.Fprintf(w, "%d skipped; ", report.Skipped)
+	}
+	fmt.Fprintln(w)
+	if report.Environment != "" {
+		fmt.Fprintf(w, "environment: %s\n", report.Environment)
+	}
+	if report.RunID != "" {
+		fmt.Fprintf(w, "run id: %s\n", report.RunID)
+	}
+	return nil
+}
+
+type jsonReportWriter struct{}
+
+func (jw *jsonReportWriter) Write(w io.Writer, report *CheckReport) error {
+	report.DurationMs = report.Duration.Milliseconds()
+	if report.Results == nil {
+		report.Results = []CheckResult{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr,omitempty"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+type junitReportWriter struct{}
+
+func (jw *junitReportWriter) Write(w io.Writer, report *CheckReport) error {
+	suiteName := "dbqctl"
+	if report.Environment != "" {
+		suiteName = fmt.Sprintf("dbqctl (%s)", report.Environment)
+	}
+
+	suites := junitTestSuites{
+		Name:    suiteName,
+		Skipped: report.Skipped,
+		Time:    formatJUnitTime(report.Duration),
+	}
+
+	for _, group := range groupResultsByDataset(report.Results) {
+		suite := junitTestSuite{
+			Name: fmt.Sprintf("%s@%s", group[0].DataSource, group[0].Dataset),
+		}
+
+		var suiteDuration time.Duration
+		for _, result := range group {
+			testCase := junitTestCase{
+				Name:      result.Label(),
+				ClassName: result.Dataset,
+				Time:      formatJUnitTime(result.Duration),
+			}
+
+			if !result.Pass {
+				details := fmt.Sprintf("expression: %s\non_fail: %s\n", result.Expression, result.OnFail)
+				if result.ActualVal != "" {
+					details += fmt.Sprintf("actual value: %s%s\n", result.ActualVal, getActualValueUnits(result.Expression))
+				}
+				if result.Anomaly != nil {
+					details += fmt.Sprintf("anomaly: %s\n", result.Anomaly.Describe())
+				}
+				if result.Groups != nil {
+					var groups strings.Builder
+					writeFailedGroupsText(&groups, &result)
+					details += groups.String()
+				}
+
+				if result.Err != "" {
+					testCase.Error = &junitMessage{Message: result.Err, Type: result.OnFail, Body: details}
+					suite.Errors += 1
+				} else {
+					testCase.Failure = &junitMessage{Message: fmt.Sprintf("check failed: %s", result.Expression), Type: result.OnFail, Body: details}
+					suite.Failures += 1
+				}
+			}
+
+			suite.TestCases = append(suite.TestCases, testCase)
+			suiteDuration += result.Duration
+		}
+
+		suite.Tests = len(group)
+		suite.Time = formatJUnitTime(suiteDuration)
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+type markdownReportWriter struct{}
+
+func (mw *markdownReportWriter) Write(w io.Writer, report *CheckReport) error {
+	fmt.Fprintf(w, "## dbqctl check result: %s", getCheckResultLabel(report.ExitCode == 0))
+	if report.Environment != "" {
+		fmt.Fprintf(w, " (%s)", report.Environment)
+	}
+	fmt.Fprintf(w, "\n\n")
+	fmt.Fprintf(w, "**%d** passed, **%d** failed", report.Passed, report.Failed)
+	if counts := report.severityCounts(); len(counts) > 0 {
+		fmt.Fprintf(w, " (%s)", strings.Join(counts, ", "))
+	}
+	if report.Errored > 0 {
+		fmt.Fprintf(w, ", **%d** errored", report.Errored)
+	}
+	if report.Skipped > 0 {
+		fmt.Fprintf(w, ", **%d** skipped", report.Skipped)
+	}
+	if report.ChecksFile != "" {
+		fmt.Fprintf(w, " (%s)", markdownCode(report.ChecksFile))
+	}
+	fmt.Fprintf(w, "\n\n")
+	if report.Interrupted {
+		fmt.Fprintf(w, "> **Note:** the run was interrupted, %d checks were not executed.\n\n", report.NotExecuted)
+	}
+
+	fmt.Fprintln(w, "| Status | Dataset | Check | Expression | On fail | Actual value | Duration |")
+	fmt.Fprintln(w, "|:------:|---------|-------|------------|---------|--------------|---------:|")
+	for _, result := range report.Results {
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s | %dms |\n",
+			markdownStatusIcon(&result),
+			escapeMarkdownCell(result.Dataset),
+			escapeMarkdownCell(result.Label()),
+			escapeMarkdownCell(markdownCode(result.Expression)),
+			result.OnFail,
+			escapeMarkdownCell(result.DisplayValue()),
+			result.Duration.Milliseconds())
+	}
+
+	failedResults := report.FailedResults()
+	if len(failedResults) != 0 {
+		fmt.Fprintf(w, "\n### Failed checks\n")
+		for _, result := range failedResults {
+			fmt.Fprintf(w, "\n- **%s** %s (%s)\n", result.Dataset, markdownCode(result.Expression), result.OnFail)
+			if result.ActualVal != "" {
+				fmt.Fprintf(w, "  - actual value: %s%s\n", markdownCode(result.ActualVal), getActualValueUnits(result.Expression))
+			}
+			if result.Anomaly != nil {
+				fmt.Fprintf(w, "  - anomaly: %s\n", result.Anomaly.Describe())
+			}
+			if result.Err != "" {
+				fmt.Fprintf(w, "  - error: %s\n", markdownCode(result.Err))
+			}
+			if result.Groups != nil {
+				fmt.Fprintf(w, "  - %s\n", result.Groups.Summary())
+				for _, group := range result.Groups.FailedGroups {
+					fmt.Fprintf(w, "    - %s: %s\n", escapeMarkdownCell(group.Label), failedGroupOutcome(&group, result.Expression))
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func markdownStatusIcon(result *CheckResult) string {
+	switch {
+	case result.Pass:
+		return "✅"
+	case result.Err != "":
+		return "❗"
+	}
+	switch internal.Severity(result.OnFail) {
+	case internal.SeverityWarn:
+		return "⚠️"
+	case internal.SeverityInfo:
+		return "ℹ️"
+	default:
+		return "❌"
+	}
+}
+
+// writeFailedGroupsText prints the groups summary of a grouped check followed by the failed groups
+func writeFailedGroupsText(w io.Writer, result *CheckResult) {
+	if result.Groups == nil {
+		return
+	}
+	fmt.Fprintln(w, result.Groups.Summary())
+	for _, group := range result.Groups.FailedGroups {
+		fmt.Fprintf(w, "  %s: %s\n", group.Label, failedGroupOutcome(&group, result.Expression))
+	}
+}
+
+// failedGroupOutcome describes why the group failed, e.g. 'actual value: 1520' or 'error: ...'
+func failedGroupOutcome(group *CheckGroupResult, expression string) string {
+	if group.Err != "" {
+		return "error: " + group.Err
+	}
+	return fmt.Sprintf("actual value: %s%s", group.ActualVal, getActualValueUnits(expression))
+}
+
+// writeFailedRowsText prints the failed rows sample as a table
+func writeFailedRowsText(w io.Writer, result *CheckResult) error {
+	if result.FailedRowsErr != "" {
+		fmt.Fprintf(w, "failed rows: %s\n", result.FailedRowsErr)
+	}
+	if result.FailedRows == nil {
+		return nil
+	}
+	if len(result.FailedRows.Rows) == 0 {
+		fmt.Fprintln(w, "failed rows: none found")
+		return nil
+	}
+
+	fmt.Fprintf(w, "failed rows (%d):\n", len(result.FailedRows.Rows))
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "  %s\n", strings.Join(result.FailedRows.Columns, "\t"))
+	for _, row := range result.FailedRows.Rows {
+		fmt.Fprintf(table, "  %s\n", strings.Join(failedRowCells(row, len(result.FailedRows.Columns)), "\t"))
+	}
+	return table.Flush()
+}
+
+// failedRowCells formats values of the row, cells missing in the row are empty
+func failedRowCells(row []any, columns int) []string {
+	cells := make([]string, columns)
+	for i := range cells {
+		if i < len(row) {
+			cells[i] = truncate(internal.FormatJsonValue(row[i]), 64)
+		}
+	}
+	return cells
+}
+
+type htmlReportWriter struct{}
+
+func (hw *htmlReportWriter) Write(w io.Writer, report *CheckReport) error {
+	tmpl, err := template.New("check").Funcs(template.FuncMap{
+		"cells":        failedRowCells,
+		"groupOutcome": func(group CheckGroupResult, expression string) string { return failedGroupOutcome(&group, expression) },
+		"statusIcon":   func(result CheckResult) string { return markdownStatusIcon(&result) },
+		"units":        getActualValueUnits,
+		"resultLabel":  getCheckResultLabel,
+		"severityList": func(r *CheckReport) string { return strings.Join(r.severityCounts(), ", ") },
+	}).Parse(checkHtmlTemplate)
+	if err != nil {
+		return err
+	}
+
+	var data struct {
+		Report      *CheckReport
+		Failed      []CheckResult
+		GeneratedAt string
+		Duration    string
+	}
+	data.Report = report
+	data.Failed = report.FailedResults()
+	data.GeneratedAt = time.Now().Format(time.DateTime)
+	data.Duration = report.Duration.Round(time.Millisecond).String()
+
+	return tmpl.Execute(w, data)
+}
+
+// groupResultsByDataset splits results into consecutive groups sharing the same data source and dataset
+func groupResultsByDataset(results []CheckResult) [][]CheckResult {
+	var groups [][]CheckResult
+	for i, result := range results {
+		if i == 0 || result.DataSource != results[i-1].DataSource || result.Dataset != results[i-1].Dataset {
+			groups = append(groups, []CheckResult{})
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], result)
+	}
+	return groups
+}
+
+func getActualValueUnits(expression string) string {
+	if strings.HasPrefix(expression, "freshness") {
+		return " (diff in seconds)"
+	}
+	if strings.HasPrefix(expression, internal.CheckFuncForeignKey) {
+		return " (orphaned rows)"
+	}
+	return ""
+}
+
+func formatJUnitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+const checkHtmlTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>dbqctl check report</title>
+<style>
+  body { font-family: -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; margin: 2rem; color: #1f2328; }
+  h1 { font-size: 1.6rem; }
+  h2 { font-size: 1.3rem; margin-top: 2.5rem; border-bottom: 1px solid #d0d7de; padding-bottom: .3rem; }
+  h3 { font-size: 1.1rem; margin-top: 2rem; }
+  .summary span { display: inline-block; margin-right: 2rem; }
+  .muted { color: #656d76; }
+  .errors { color: #cf222e; }
+  table { border-collapse: collapse; margin-top: 1rem; font-size: .9rem; }
+  th, td { border: 1px solid #d0d7de; padding: .3rem .6rem; text-align: left; vertical-align: top; }
+  th { background: #f6f8fa; }
+  td.num { text-align: right; font-variant-numeric: tabular-nums; }
+  .samples { max-width: 100%; overflow-x: auto; }
+</style>
+</head>
+<body>
+{{$report := .Report}}
+<h1>dbqctl check result: {{resultLabel (eq $report.ExitCode 0)}}</h1>
+<p class="muted">Generated at {{.GeneratedAt}}{{if $report.RunID}}, run {{$report.RunID}}{{end}}{{if $report.Environment}}, environment {{$report.Environment}}{{end}}</p>
+<p class="summary">
+  <span><b>Checks file:</b> {{$report.ChecksFile}}</span>
+  <span><b>Passed:</b> {{$report.Passed}}</span>
+  <span><b>Failed:</b> {{$report.Failed}}{{with severityList $report}} ({{.}}){{end}}</span>
+  {{if $report.Errored}}<span><b>Errored:</b> {{$report.Errored}}</span>{{end}}
+  {{if $report.Skipped}}<span><b>Skipped:</b> {{$report.Skipped}}</span>{{end}}
+  <span><b>Duration:</b> {{.Duration}}</span>
+</p>
+{{if $report.Interrupted}}<p class="errors">The run was interrupted, {{$report.NotExecuted}} checks were not executed.</p>{{end}}
+<table>
+  <thead>
+    <tr><th>Status</th><th>Dataset</th><th>Check</th><th>Expression</th><th>On fail</th><th>Actual value</th><th>Duration</th></tr>
+  </thead>
+  <tbody>
+  {{range $report.Results}}
+    <tr>
+      <td>{{statusIcon .}}</td>
+      <td>{{.DataSource}}@{{.Dataset}}</td>
+      <td>{{.Label}}</td>
+      <td><code>{{.Expression}}</code></td>
+      <td>{{.OnFail}}</td>
+      <td class="num">{{.DisplayValue}}</td>
+      <td class="num">{{.DurationMs}}ms</td>
+    </tr>
+  {{end}}
+  </tbody>
+</table>
+{{if .Failed}}
+<h2>Failed checks</h2>
+{{range .Failed}}
+<h3>{{.Dataset}}: <code>{{.Expression}}</code> ({{.StatusLabel}})</h3>
+{{if .ActualVal}}<p><b>Actual value:</b> {{.ActualVal}}{{units .Expression}}</p>{{end}}
+{{if .Anomaly}}<p><b>Anomaly:</b> {{.Anomaly.Describe}}</p>{{end}}
+{{if .Err}}<p class="errors">{{.Err}}</p>{{end}}
+{{$expression := .Expression}}
+{{with .Groups}}
+<p><b>Groups:</b> {{.Summary}}</p>
+{{if .FailedGroups}}
+<table>
+  <thead><tr><th>Group</th><th>Outcome</th></tr></thead>
+  <tbody>
+  {{range .FailedGroups}}
+    <tr><td>{{.Label}}</td><td>{{groupOutcome . $expression}}</td></tr>
+  {{end}}
+  </tbody>
+</table>
+{{end}}
+{{end}}
+{{if .FailedRowsErr}}<p class="errors">Failed rows: {{.FailedRowsErr}}</p>{{end}}
+{{with .FailedRows}}
+{{if .Rows}}
+<div class="samples">
+<table>
+  <thead><tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr></thead>
+  <tbody>
+  {{$columns := len .Columns}}
+  {{range .Rows}}
+    <tr>{{range cells . $columns}}<td>{{.}}</td>{{end}}</tr>
+  {{end}}
+  </tbody>
+</table>
+</div>
+{{else}}
+<p class="muted">No failed rows found.</p>
+{{end}}
+{{end}}
+{{end}}
+{{end}}
+</body>
+</html>
+`
+
+// markdownCode formats the value as a code span, delimited by more backticks than any run of backticks in it
+func markdownCode(value string) string {
+	value = strings.ReplaceAll(value, "\n", " ")
+	longestRun, run := 0, 0
+	for _, c := range value {
+		if c != '`' {
+			run = 0
+			continue
+		}
+		run++
+		longestRun = max(longestRun, run)
+	}
+
+	delimiter := strings.Repeat("`", longestRun+1)
+	if strings.HasPrefix(value, "`") || strings.HasSuffix(value, "`") {
+		// spaces keep backticks at the edges from merging with the delimiter, they are stripped when rendered
+		value = " " + value + " "
+	}
+	return delimiter + value + delimiter
+}
+
+func escapeMarkdownCell(value string) string {
+	value = strings.ReplaceAll(value, "|", "\\|")
+	return strings.ReplaceAll(value, "\n", " ")
+}
@@ -0,0 +1,138 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DataBridgeTech/dbqctl/internal"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files of check reports")
+
+// testCheckReport covers every kind of result, with values needing escaping in each output format
+func testCheckReport() *CheckReport {
+	report := &CheckReport{
+		RunID:       "20250301T120000-000001",
+		ChecksFile:  "checks/orders.yaml",
+		Environment: "staging",
+		ExitCode:    ExitCodeChecksFailed,
+		Interrupted: true,
+		NotExecuted: 2,
+		Skipped:     1,
+		Duration:    2345 * time.Millisecond,
+	}
+	for _, result := range []CheckResult{
+		{DataSource: "pg", Dataset: "public.orders", Expression: "row_count > 0", OnFail: "error", Pass: true, ActualVal: "1520", Duration: 12 * time.Millisecond},
+		{
+			DataSource: "pg", Dataset: "public.orders", Expression: "raw_query", Description: "no `test` | <draft> & \"demo\" orders",
+			OnFail: "error", ActualVal: "3", Duration: 40 * time.Millisecond,
+		},
+		{
+			DataSource: "pg", Dataset: "public.orders", Expression: "freshness(updated_at) < 3600", OnFail: "warn", ActualVal: "7200",
+			Anomaly: &internal.AnomalyResult{Skipped: "not enough history (2 of 5 runs)"}, Duration: 8 * time.Millisecond,
+		},
+		{
+			DataSource: "pg", Dataset: "public.orders", Expression: "not_null(`email`)", OnFail: "critical", ActualVal: "2",
+			FailedRows: &internal.FailedRows{
+				Columns: []string{"id", "email", "note"},
+				Rows:    [][]any{{"1", nil, "<b>vip</b>"}, {"2", nil}},
+			},
+			Duration: 15 * time.Millisecond,
+		},
+		{
+			DataSource: "ch", Dataset: "nyc.trips", Expression: "max(fare) < 500", OnFail: "error",
+			Groups: &CheckGroupsResult{
+				GroupBy: []string{"county"}, Total: 3, Passed: 1, Failed: 1, Errored: 1,
+				FailedGroups: []CheckGroupResult{
+					{Group: map[string]string{"county": "Kent"}, Label: "county=Kent", ActualVal: "640"},
+					{Group: map[string]string{"county": "Essex"}, Label: "county=Essex", Err: "query timeout"},
+				},
+			},
+			Duration: 30 * time.Millisecond,
+		},
+		{
+			DataSource: "ch", Dataset: "nyc.trips", Expression: "uniqueness(trip_id)", OnFail: "info", Err: "code: 60, table `nyc.trips` doesn't exist",
+			FailedRowsErr: "not sampled", Duration: 3 * time.Millisecond,
+		},
+	} {
+		report.AddResult(result)
+	}
+	return report
+}
+
+// generatedAtRegex matches the generation time of html reports
+var generatedAtRegex = regexp.MustCompile(`Generated at [0-9-]+ [0-9:]+`)
+
+func TestCheckReportWriters(t *testing.T) {
+	for _, format := range []string{OutputFormatText, OutputFormatJson, OutputFormatJUnit, OutputFormatMarkdown, OutputFormatHtml} {
+		t.Run(format, func(t *testing.T) {
+			writer, err := NewCheckReportWriter(format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := writer.Write(&out, testCheckReport()); err != nil {
+				t.Fatal(err)
+			}
+			got := generatedAtRegex.ReplaceAll(out.Bytes(), []byte("Generated at 2025-03-01 12:00:00"))
+
+			goldenPath := filepath.Join("testdata", "check_report", format+".golden")
+			if *updateGolden {
+				if err := os.MkdirAll(filepath.Dir(goldenPath), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(goldenPath, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			golden, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(golden) {
+				t.Errorf("%s report differs from %s:\n%s", format, goldenPath, got)
+			}
+		})
+	}
+}
+
+func TestMarkdownCode(t *testing.T) {
+	tests := map[string]string{
+		"row_count > 0":         "`row_count > 0`",
+		"a `b` c":               "``a `b` c``",
+		"a ``b`` c":             "```a ``b`` c```",
+		"`quoted`":              "`` `quoted` ``",
+		"multi\nline":           "`multi line`",
+		"raw_query with ` tick": "``raw_query with ` tick``",
+	}
+	for value, want := range tests {
+		if got := markdownCode(value); got != want {
+			t.Errorf("markdownCode(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestNewCheckReportWriterUnknownFormat(t *testing.T) {
+	if _, err := NewCheckReportWriter("yaml"); err == nil {
+		t.Error("NewCheckReportWriter(yaml) didn't fail")
+	}
+}
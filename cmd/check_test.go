@@ -0,0 +1,327 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DataBridgeTech/dbqcore"
+	"github.com/DataBridgeTech/dbqctl/internal"
+)
+
+// groupsTestApp serves check groups and evaluates checks per group from fixed results keyed by the group condition
+type groupsTestApp struct {
+	internal.DbqCliApp
+	groups     []internal.CheckGroup
+	groupsErr  error
+	results    map[string]dbqcore.ValidationResult
+	limit      int
+	conditions []string
+}
+
+func (a *groupsTestApp) ListCheckGroups(ctx context.Context, dataSource *dbqcore.DataSource, dataset string, defaultWhere string, groupBy []string, limit int) ([]internal.CheckGroup, error) {
+	a.limit = limit
+	if a.groupsErr != nil {
+		return nil, a.groupsErr
+	}
+	if len(a.groups) > limit {
+		return a.groups[:limit], nil
+	}
+	return a.groups, nil
+}
+
+func (a *groupsTestApp) RunCheck(ctx context.Context, check *dbqcore.DataQualityCheck, dataSource *dbqcore.DataSource, dataset string, defaultWhere string) *dbqcore.ValidationResult {
+	a.conditions = append(a.conditions, defaultWhere)
+	for condition, result := range a.results {
+		if strings.HasSuffix(defaultWhere, condition+")") || defaultWhere == condition {
+			return &result
+		}
+	}
+	return &dbqcore.ValidationResult{Pass: true, QueryResultValue: "0"}
+}
+
+func countyGroup(county string) internal.CheckGroup {
+	return internal.CheckGroup{Key: map[string]string{"county": county}, Where: "(county) = '" + county + "'"}
+}
+
+func TestRunGroupedCheckTask(t *testing.T) {
+	groups := []internal.CheckGroup{countyGroup("Essex"), countyGroup("Kent"), countyGroup("Surrey")}
+
+	tests := []struct {
+		name          string
+		where         string
+		maxGroups     int
+		groupsErr     error
+		results       map[string]dbqcore.ValidationResult
+		wantPass      bool
+		wantErr       string
+		wantTotal     int
+		wantFailed    []string
+		wantTruncated bool
+	}{
+		{
+			name:      "all groups passed",
+			maxGroups: 10,
+			wantPass:  true,
+			wantTotal: 3,
+		},
+		{
+			name:      "failed group",
+			where:     "status = 'open'",
+			maxGroups: 10,
+			results: map[string]dbqcore.ValidationResult{
+				"(county) = 'Kent'": {Pass: false, QueryResultValue: "5"},
+			},
+			wantTotal:  3,
+			wantFailed: []string{"county=Kent"},
+		},
+		{
+			name:      "errored groups only",
+			maxGroups: 10,
+			results: map[string]dbqcore.ValidationResult{
+				"(county) = 'Essex'": {Error: "timeout"},
+			},
+			wantErr:    "1 of 3 groups couldn't be evaluated: timeout",
+			wantTotal:  3,
+			wantFailed: []string{"county=Essex"},
+		},
+		{
+			name:      "failed group wins over errored one",
+			maxGroups: 10,
+			results: map[string]dbqcore.ValidationResult{
+				"(county) = 'Essex'":  {Error: "timeout"},
+				"(county) = 'Surrey'": {Pass: false, QueryResultValue: "1"},
+			},
+			wantTotal:  3,
+			wantFailed: []string{"county=Essex", "county=Surrey"},
+		},
+		{
+			name:          "truncated to max groups",
+			maxGroups:     2,
+			wantPass:      true,
+			wantTotal:     2,
+			wantTruncated: true,
+		},
+		{
+			name:      "groups can't be listed",
+			maxGroups: 10,
+			groupsErr: context.DeadlineExceeded,
+			wantErr:   "failed to list groups: context deadline exceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &groupsTestApp{groups: groups, groupsErr: tt.groupsErr, results: tt.results}
+			task := &checkTask{
+				dataSource: &dbqcore.DataSource{ID: "pg", Type: "postgresql"},
+				dataset:    "public.orders",
+				where:      tt.where,
+				check:      internal.Check{DataQualityCheck: dbqcore.DataQualityCheck{Expression: "row_count > 0"}, GroupBy: []string{"county"}},
+				maxGroups:  tt.maxGroups,
+			}
+
+			result := runGroupedCheckTask(context.Background(), app, task)
+
+			if app.limit != tt.maxGroups+1 {
+				t.Errorf("groups listed with limit %d, want %d", app.limit, tt.maxGroups+1)
+			}
+			if result.Pass != tt.wantPass {
+				t.Errorf("Pass = %t, want %t", result.Pass, tt.wantPass)
+			}
+			if result.Err != tt.wantErr {
+				t.Errorf("Err = %q, want %q", result.Err, tt.wantErr)
+			}
+			if result.Groups.Total != tt.wantTotal {
+				t.Errorf("Total = %d, want %d", result.Groups.Total, tt.wantTotal)
+			}
+			if result.Groups.Truncated != tt.wantTruncated {
+				t.Errorf("Truncated = %t, want %t", result.Groups.Truncated, tt.wantTruncated)
+			}
+			var failed []string
+			for _, group := range result.Groups.FailedGroups {
+				failed = append(failed, group.Label)
+			}
+			if strings.Join(failed, "; ") != strings.Join(tt.wantFailed, "; ") {
+				t.Errorf("failed groups = %v, want %v", failed, tt.wantFailed)
+			}
+			if tt.where != "" {
+				for _, condition := range app.conditions {
+					if !strings.HasPrefix(condition, "("+tt.where+") AND (") {
+						t.Errorf("group condition %q doesn't restrict the rule where", condition)
+					}
+				}
+			}
+		})
+	}
+}
+
+// tasksTestApp runs checks named 'sleep:<duration>' for that long, 'block' until the context is done and 'cancel'
+// by calling cancel. It records the context deadline of every check and the peak number of concurrent checks
+type tasksTestApp struct {
+	internal.DbqCliApp
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	running   int
+	peak      int
+	deadlines map[string]time.Duration
+}
+
+func (a *tasksTestApp) RunCheck(ctx context.Context, check *dbqcore.DataQualityCheck, dataSource *dbqcore.DataSource, dataset string, defaultWhere string) *dbqcore.ValidationResult {
+	a.mu.Lock()
+	a.running++
+	a.peak = max(a.peak, a.running)
+	if deadline, ok := ctx.Deadline(); ok {
+		a.deadlines[check.Description] = time.Until(deadline)
+	}
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		a.running--
+		a.mu.Unlock()
+	}()
+
+	switch name, arg, _ := strings.Cut(check.Expression, ":"); name {
+	case "sleep":
+		duration, _ := time.ParseDuration(arg)
+		time.Sleep(duration)
+	case "block":
+		<-ctx.Done()
+		return &dbqcore.ValidationResult{Error: ctx.Err().Error()}
+	case "cancel":
+		a.cancel()
+	}
+	return &dbqcore.ValidationResult{Pass: true, QueryResultValue: check.Description}
+}
+
+func testCheckTask(expression string, description string, timeout time.Duration) checkTask {
+	return checkTask{
+		dataSource: &dbqcore.DataSource{ID: "pg"},
+		dataset:    "public.orders",
+		check: internal.Check{
+			DataQualityCheck: dbqcore.DataQualityCheck{Expression: expression, Description: description},
+			Severity:         internal.SeverityError,
+			Timeout:          timeout,
+		},
+	}
+}
+
+func TestRunCheckTasksOrder(t *testing.T) {
+	var tasks []checkTask
+	for i, delay := range []string{"40ms", "30ms", "20ms", "10ms", "0s", "15ms"} {
+		tasks = append(tasks, testCheckTask("sleep:"+delay, string(rune('a'+i)), 0))
+	}
+
+	app := &tasksTestApp{deadlines: map[string]time.Duration{}}
+	results, executed := runCheckTasks(context.Background(), app, tasks, 3)
+
+	var order []string
+	for i, result := range results {
+		if !executed[i] {
+			t.Errorf("task %d wasn't executed", i)
+		}
+		order = append(order, result.ActualVal)
+	}
+	if got := strings.Join(order, ""); got != "abcdef" {
+		t.Errorf("results order = %s, want the order of tasks", got)
+	}
+	if app.peak < 2 || app.peak > 3 {
+		t.Errorf("peak concurrency = %d, want at most 3 workers busy at once", app.peak)
+	}
+}
+
+func TestRunCheckTasksTimeout(t *testing.T) {
+	defer func(globalTimeout time.Duration) { timeout = globalTimeout }(timeout)
+
+	tests := []struct {
+		name          string
+		globalTimeout time.Duration
+		checkTimeout  time.Duration
+		wantDeadline  time.Duration
+	}{
+		{name: "no timeout"},
+		{name: "global timeout", globalTimeout: time.Hour, wantDeadline: time.Hour},
+		{name: "check timeout", checkTimeout: time.Minute, wantDeadline: time.Minute},
+		{name: "check timeout overrides global timeout", globalTimeout: time.Hour, checkTimeout: time.Minute, wantDeadline: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeout = tt.globalTimeout
+			app := &tasksTestApp{deadlines: map[string]time.Duration{}}
+			runCheckTasks(context.Background(), app, []checkTask{testCheckTask("sleep:0s", "check", tt.checkTimeout)}, 1)
+
+			deadline, ok := app.deadlines["check"]
+			if ok != (tt.wantDeadline > 0) {
+				t.Fatalf("check has deadline %t, want %t", ok, tt.wantDeadline > 0)
+			}
+			if ok && (deadline > tt.wantDeadline || deadline < tt.wantDeadline-time.Second) {
+				t.Errorf("check deadline in %s, want %s", deadline, tt.wantDeadline)
+			}
+		})
+	}
+
+	// the timed out check errors without holding up the other checks
+	timeout = time.Hour
+	app := &tasksTestApp{deadlines: map[string]time.Duration{}}
+	startedAt := time.Now()
+	results, _ := runCheckTasks(context.Background(), app, []checkTask{
+		testCheckTask("block", "slow", 20*time.Millisecond),
+		testCheckTask("sleep:0s", "fast", 0),
+	}, 1)
+	if elapsed := time.Since(startedAt); elapsed > 10*time.Second {
+		t.Errorf("run took %s, the check timeout wasn't applied", elapsed)
+	}
+	if results[0].Pass || results[0].Err != context.DeadlineExceeded.Error() {
+		t.Errorf("timed out check = %+v", results[0])
+	}
+	if !results[1].Pass {
+		t.Errorf("check after the timed out one = %+v", results[1])
+	}
+}
+
+func TestRunCheckTasksCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	app := &tasksTestApp{cancel: cancel, deadlines: map[string]time.Duration{}}
+	tasks := []checkTask{
+		testCheckTask("sleep:0s", "first", 0),
+		testCheckTask("cancel", "cancelling", 0),
+		testCheckTask("sleep:0s", "third", 0),
+		testCheckTask("sleep:0s", "fourth", 0),
+	}
+	results, executed := runCheckTasks(ctx, app, tasks, 1)
+
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Fatal("context wasn't cancelled")
+	}
+	wantExecuted := []bool{true, true, false, false}
+	for i := range tasks {
+		if executed[i] != wantExecuted[i] {
+			t.Errorf("executed[%d] = %t, want %t", i, executed[i], wantExecuted[i])
+		}
+	}
+	// results of the checks which ran before the cancellation are kept
+	if results[0].ActualVal != "first" || results[1].ActualVal != "cancelling" || results[2].Expression != "" {
+		t.Errorf("results = %+v", results)
+	}
+}
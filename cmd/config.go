@@ -0,0 +1,412 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/DataBridgeTech/dbqcore"
+	"github.com/DataBridgeTech/dbqctl/internal"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func NewConfigCommand(app internal.DbqCliApp) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manages the dbq configuration",
+		Long: `The 'config' command groups operations on the dbq configuration.
+
+The configuration is loaded from the files given with --config (repeat the flag to merge several files),
+from $DBQ_CONFIG (several files separated by the OS path list separator), or from the first file found of
+./dbq.yaml, $XDG_CONFIG_HOME/dbq/dbq.yaml and $HOME/.dbq.yaml.
+
+When several files are merged, later files override earlier ones: mappings are merged key by key,
+data sources with the same id are merged field by field and new data sources are appended.
+`,
+	}
+
+	cmd.AddCommand(newConfigInitCommand(app))
+	cmd.AddCommand(newConfigShowCommand(app))
+	cmd.AddCommand(newConfigListCommand(app))
+	cmd.AddCommand(newConfigAddDataSourceCommand(app))
+	cmd.AddCommand(newConfigRemoveDataSourceCommand(app))
+	cmd.AddCommand(newConfigTestCommand(app))
+
+	return cmd
+}
+
+func newConfigShowCommand(app internal.DbqCliApp) *cobra.Command {
+	var resolved bool
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Prints the effective configuration merged from all config files",
+		Long: `The 'show' command prints the effective configuration merged from all loaded config files.
+
+Environment variable placeholders and secret references are printed as written, use --resolved to print
+their resolved values instead. Passwords, tokens and resolved secrets are always masked.
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := app.RenderConfig(resolved)
+			if err != nil {
+				return configError(cmd, err)
+			}
+
+			for _, path := range app.GetConfigFiles() {
+				fmt.Printf("# source: %s\n", path)
+			}
+			if environment := app.GetEnvironment(); environment != "" {
+				fmt.Printf("# environment: %s\n", environment)
+			}
+			fmt.Print(string(out))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&resolved, "resolved", false, "print interpolated values resolved, secrets are still masked")
+
+	return cmd
+}
+
+// dataSourceDefaultPorts lists supported data source types with their default ports
+var dataSourceDefaultPorts = map[string]int{
+	"clickhouse": 9000,
+	"postgresql": 5432,
+	"mysql":      3306,
+}
+
+func newConfigInitCommand(app internal.DbqCliApp) *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "init [path]",
+		Short: "Writes a commented config file template",
+		Long: `The 'init' command writes a commented dbq config template to the given path, to the path given with --config,
+or to ./dbq.yaml. An existing file is only overwritten with --force.
+`,
+		Args: cobra.MaximumNArgs(1),
+		Annotations: map[string]string{
+			skipConfigAnnotation: "true",
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := "dbq.yaml"
+			if len(args) > 0 {
+				path = args[0]
+			} else if configFiles := app.GetConfigFiles(); len(configFiles) > 0 && app.GetConfigError() != nil {
+				// explicitly given config file which doesn't exist yet
+				path = configFiles[0]
+			}
+
+			if err := internal.WriteConfigTemplate(path, force); err != nil {
+				return err
+			}
+			fmt.Printf("dbqctl config template has been written to %s\n", path)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "overwrite an existing config file")
+
+	return cmd
+}
+
+func newConfigListCommand(app internal.DbqCliApp) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists configured data sources",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tTYPE\tHOST\tPORT\tDATABASE\tDATASETS")
+			dataSources := app.GetDbqConfig().DataSources
+			for i := range dataSources {
+				// unresolvable connection settings are listed empty
+				if err := app.ResolveDataSource(dataSources[i].ID); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: %s\n", err)
+				}
+				ds := dataSources[i]
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%d\n",
+					ds.ID, ds.Type, ds.Configuration.Host, ds.Configuration.Port, ds.Configuration.Database, len(ds.Datasets))
+			}
+			return tw.Flush()
+		},
+	}
+
+	return cmd
+}
+
+func newConfigAddDataSourceCommand(app internal.DbqCliApp) *cobra.Command {
+	var ds dbqcore.DataSource
+	var skipPing bool
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "add-datasource",
+		Short: "Adds a data source to the config file",
+		Long: `The 'add-datasource' command adds a data source to the (first) config file, keeping its comments and formatting.
+Connection settings not given with flags are prompted for when running in a terminal, the password is read without echo.
+The data source is pinged before it's saved, unless --skip-ping is set.
+
+Prefer environment variable placeholders or secret references over plain passwords, e.g.:
+  dbqctl config add-datasource --id pg --type postgresql --host localhost --user app --password '${PG_PASSWORD}' --database analytics
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := completeDataSource(cmd, &ds, os.Stdin, os.Stderr); err != nil {
+				return err
+			}
+			if err := app.AddDataSource(ds); err != nil {
+				return err
+			}
+
+			if !skipPing {
+				fmt.Fprintf(os.Stderr, "Connecting to data source: %s...\n", ds.ID)
+				ctx, cancel := withTimeout(cmd.Context(), 0)
+				info, err := app.PingDataSource(ctx, ds.ID)
+				cancel()
+				if err != nil {
+					return fmt.Errorf("data source '%s' is not reachable, not saving it (use --skip-ping to save anyway): %w", ds.ID, err)
+				}
+				fmt.Fprintf(os.Stderr, "Connected: %s\n", info)
+			}
+
+			return saveConfigChanges(app, dryRun, fmt.Sprintf("data source '%s' has been added", ds.ID))
+		},
+	}
+
+	cmd.Flags().StringVar(&ds.ID, "id", "", "data source id, referenced by checks files")
+	cmd.Flags().StringVar(&ds.Type, "type", "", "data source type (clickhouse, postgresql or mysql)")
+	cmd.Flags().StringVar(&ds.Configuration.Host, "host", "", "host to connect to")
+	cmd.Flags().IntVar(&ds.Configuration.Port, "port", 0, "port to connect to (default depends on the type)")
+	cmd.Flags().StringVar(&ds.Configuration.Username, "user", "", "user name")
+	cmd.Flags().StringVar(&ds.Configuration.Password, "password", "", "password, preferably a placeholder like '${PG_PASSWORD}' or a secret reference")
+	cmd.Flags().StringVar(&ds.Configuration.Database, "database", "", "database name")
+	cmd.Flags().StringSliceVar(&ds.Datasets, "datasets", nil, "datasets (e.g. tables) of the data source, can be imported later with 'dbqctl import'")
+	cmd.Flags().BoolVar(&skipPing, "skip-ping", false, "save the data source without checking it's reachable")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print a unified diff of the config changes instead of writing them")
+
+	return cmd
+}
+
+// completeDataSource prompts for connection settings which weren't given with flags, if the input is a terminal
+func completeDataSource(cmd *cobra.Command, ds *dbqcore.DataSource, in *os.File, out io.Writer) error {
+	interactive := false
+	if info, err := in.Stat(); err == nil {
+		interactive = info.Mode()&os.ModeCharDevice != 0
+	}
+	if !interactive && (ds.ID == "" || ds.Type == "" || ds.Configuration.Host == "") {
+		return fmt.Errorf("--id, --type and --host are required when not running in a terminal")
+	}
+	reader := bufio.NewReader(in)
+
+	readLine := func(secret bool) (string, error) {
+		if secret && term.IsTerminal(int(in.Fd())) {
+			// the password isn't echoed, so the line break typed by the user has to be printed
+			line, err := term.ReadPassword(int(in.Fd()))
+			fmt.Fprintln(out)
+			return string(line), err
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		return line, nil
+	}
+
+	prompt := func(flag string, label string, value *string, defaultValue string, secret bool) error {
+		if cmd.Flags().Changed(flag) || !interactive {
+			return nil
+		}
+		if defaultValue != "" {
+			fmt.Fprintf(out, "%s [%s]: ", label, defaultValue)
+		} else {
+			fmt.Fprintf(out, "%s: ", label)
+		}
+		line, err := readLine(secret)
+		if err != nil {
+			return err
+		}
+		*value = strings.TrimSpace(line)
+		if *value == "" {
+			*value = defaultValue
+		}
+		return nil
+	}
+
+	if err := prompt("id", "id", &ds.ID, "", false); err != nil {
+		return err
+	}
+	if err := prompt("type", "type (clickhouse, postgresql, mysql)", &ds.Type, "postgresql", false); err != nil {
+		return err
+	}
+	ds.Type = strings.ToLower(ds.Type)
+	defaultPort, supported := dataSourceDefaultPorts[ds.Type]
+	if !supported {
+		return fmt.Errorf("unsupported data source type '%s' (expected one of: clickhouse, postgresql, mysql)", ds.Type)
+	}
+
+	if err := prompt("host", "host", &ds.Configuration.Host, "localhost", false); err != nil {
+		return err
+	}
+	port := ""
+	if err := prompt("port", "port", &port, strconv.Itoa(defaultPort), false); err != nil {
+		return err
+	}
+	if port != "" {
+		parsed, err := strconv.Atoi(port)
+		if err != nil {
+			return fmt.Errorf("invalid port '%s'", port)
+		}
+		ds.Configuration.Port = parsed
+	}
+	if ds.Configuration.Port == 0 {
+		ds.Configuration.Port = defaultPort
+	}
+	if err := prompt("user", "user", &ds.Configuration.Username, "", false); err != nil {
+		return err
+	}
+	if err := prompt("password", "password (e.g. ${PG_PASSWORD} or file:///run/secrets/pg)", &ds.Configuration.Password, "", true); err != nil {
+		return err
+	}
+	if err := prompt("database", "database", &ds.Configuration.Database, "", false); err != nil {
+		return err
+	}
+
+	if ds.ID == "" || ds.Configuration.Host == "" {
+		return fmt.Errorf("data source id and host are required")
+	}
+	return nil
+}
+
+func newConfigRemoveDataSourceCommand(app internal.DbqCliApp) *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "remove-datasource <id>",
+		Short: "Removes a data source from the config files",
+		Long: `The 'remove-datasource' command removes the data source, along with its environment overrides,
+from every config file defining it, keeping comments and formatting of the rest of the files.
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !app.RemoveDataSource(args[0]) {
+				return fmt.Errorf("data source '%s' not found in dbq configuration", args[0])
+			}
+			return saveConfigChanges(app, dryRun, fmt.Sprintf("data source '%s' has been removed", args[0]))
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print a unified diff of the config changes instead of writing them")
+
+	return cmd
+}
+
+func newConfigTestCommand(app internal.DbqCliApp) *cobra.Command {
+	var dataSource string
+
+	cmd := &cobra.Command{
+		Use:   "test",
+		Short: "Checks that all configured data sources are reachable",
+		Long: `The 'test' command pings every configured data source (or the one given with --datasource)
+and exits with a non-zero code if any of them is not reachable.
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var sources []string
+			if dataSource != "" {
+				if app.FindDataSourceById(dataSource) == nil {
+					return fmt.Errorf("data source '%s' not found in dbq configuration", dataSource)
+				}
+				sources = append(sources, dataSource)
+			} else {
+				for _, ds := range app.GetDbqConfig().DataSources {
+					sources = append(sources, ds.ID)
+				}
+			}
+
+			failed := 0
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tSTATUS\tDETAILS")
+			for _, id := range sources {
+				if cmd.Context().Err() != nil {
+					break
+				}
+				ctx, cancel := withTimeout(cmd.Context(), 0)
+				info, err := app.PingDataSource(ctx, id)
+				cancel()
+				if err != nil {
+					failed += 1
+					fmt.Fprintf(tw, "%s\tfailed\t%s\n", id, err)
+				} else {
+					fmt.Fprintf(tw, "%s\tok\t%s\n", id, info)
+				}
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+
+			if failed > 0 {
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+				return &ExitCodeError{Code: 1}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&dataSource, "datasource", "d", "", "data source to test")
+
+	return cmd
+}
+
+// saveConfigChanges writes the in-memory config changes to the config files, or prints them as a diff
+func saveConfigChanges(app internal.DbqCliApp, dryRun bool, message string) error {
+	if dryRun {
+		updates, err := app.PrepareDbqConfigUpdates()
+		if err != nil {
+			return err
+		}
+		changed := false
+		for _, update := range updates {
+			diff, err := update.Diff()
+			if err != nil {
+				return err
+			}
+			if diff != "" {
+				fmt.Print(diff)
+				changed = true
+			}
+		}
+		if !changed {
+			fmt.Println("dbqctl config is up to date")
+		}
+		return nil
+	}
+
+	if err := app.SaveDbqConfig(); err != nil {
+		return err
+	}
+	fmt.Println(message)
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/DataBridgeTech/dbqctl/internal"
+	"github.com/spf13/cobra"
+)
+
+func NewExplainCommand(app internal.DbqCliApp) *cobra.Command {
+	var checksFile string
+	var checkId string
+	var dataset string
+
+	cmd := &cobra.Command{
+		Use:   "explain",
+		Short: "Shows the query plan of a check",
+		Long: `The 'explain' command renders the SQL of a single check and runs the EXPLAIN of the data source for it,
+which helps to tune expensive checks. The check is selected by its id, ids are printed by 'dbqctl check --dry-run'.
+
+If the rule of the check has several datasets, the check is explained for each of them unless --dataset is given.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			checksCfg, err := internal.LoadChecksFile(checksFile, app.IsLenient())
+			if err != nil {
+				return fmt.Errorf("error while loading checks configuration file: %w", err)
+			}
+
+			rule, check := checksCfg.FindCheck(checkId)
+			if check == nil {
+				return fmt.Errorf("check '%s' not found in %s (check ids are printed by 'dbqctl check --dry-run')", checkId, checksFile)
+			}
+			if strings.TrimSpace(check.Expression) == internal.CheckFuncSchemaCheck {
+				return fmt.Errorf("check '%s' is a schema_check, there is no query to explain", checkId)
+			}
+
+			dataSourceId, datasets, err := internal.ParseDatasetString(rule.Dataset)
+			if err != nil {
+				return fmt.Errorf("error while parsing dataset property: %w", err)
+			}
+			dataSource := app.FindDataSourceById(dataSourceId)
+			if dataSource == nil {
+				return fmt.Errorf("specified data source not found in dbq configuration: %s", dataSourceId)
+			}
+			if dataset != "" {
+				if !slices.Contains(datasets, dataset) {
+					return fmt.Errorf("dataset '%s' is not checked by rule '%s'", dataset, rule.Dataset)
+				}
+				datasets = []string{dataset}
+			}
+
+			for i, curDataset := range datasets {
+				if i > 0 {
+					fmt.Println()
+				}
+				ctx, cancel := withTimeout(cmd.Context(), check.Timeout)
+				query, plan, err := app.ExplainCheck(ctx, &check.DataQualityCheck, dataSource, curDataset, rule.Where)
+				cancel()
+				if err != nil {
+					return fmt.Errorf("failed to explain check '%s' for '%s': %w", check.Expression, curDataset, err)
+				}
+
+				fmt.Printf("-- [%s] %s@%s: %s\n", check.ID, dataSource.ID, curDataset, check.Expression)
+				fmt.Printf("%s;\n\n", strings.TrimRight(strings.TrimSpace(query), ";"))
+				fmt.Println(plan)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&checksFile, "checks", "c", "", "path to data quality checks file")
+	_ = cmd.MarkFlagRequired("checks")
+	cmd.Flags().StringVar(&checkId, "check", "", "id of the check to explain, either its 'id' or derived from its position, e.g. '2.5' for the fifth check of the second rule")
+	_ = cmd.MarkFlagRequired("check")
+	cmd.Flags().StringVarP(&dataset, "dataset", "s", "", "explain the check only for this dataset of the rule")
+
+	return cmd
+}
@@ -0,0 +1,279 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DataBridgeTech/dbqctl/internal"
+	"github.com/spf13/cobra"
+)
+
+func NewHistoryCommand(app internal.DbqCliApp) *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Shows results of previous check runs",
+		Long: `The 'history' command gives access to the results of previous 'check' runs which are persisted in a local history store.
+Every run records the checks file and its hash, and for each check the dataset, expression, pass/fail status, actual value and error.
+
+By default the store is located at $HOME/.dbq/history.db, the location can be changed (or history disabled) in dbq.yaml:
+
+history:
+  enabled: true
+  path: ./.dbq/history.db
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listHistoryRuns(app, limit)
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "maximum number of runs to show")
+
+	cmd.AddCommand(newHistoryListCommand(app))
+	cmd.AddCommand(newHistoryShowCommand(app))
+	cmd.AddCommand(newHistoryTrendCommand(app))
+
+	return cmd
+}
+
+func newHistoryListCommand(app internal.DbqCliApp) *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists most recent check runs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listHistoryRuns(app, limit)
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "maximum number of runs to show")
+
+	return cmd
+}
+
+func newHistoryShowCommand(app internal.DbqCliApp) *cobra.Command {
+	var failOn string
+	var strict bool
+
+	cmd := &cobra.Command{
+		Use:   "show <run-id|latest>",
+		Short: "Shows all check results of a single run",
+		Long: `Shows all check results of a single run.
+The run result is derived from the severities of failed checks with the same --fail-on and --strict policy as 'dbqctl check'.
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			failOnSeverity, err := internal.ParseSeverity(failOn)
+			if err != nil {
+				return fmt.Errorf("invalid --fail-on: %w", err)
+			}
+
+			history, err := openHistoryStore(app)
+			if err != nil {
+				return err
+			}
+
+			run, results, err := history.GetRun(args[0])
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("run:         %s\n", run.ID)
+			fmt.Printf("started at:  %s\n", run.StartedAt.Local().Format(time.DateTime))
+			fmt.Printf("checks file: %s (sha256: %s)\n", run.ChecksFile, shortHash(run.ChecksFileHash))
+			if run.Environment != "" {
+				fmt.Printf("environment: %s\n", run.Environment)
+			}
+			checkResults := historyCheckResults(results)
+			exitCode := checkRunExitCode(checkResults, failOnSeverity, strict)
+			fmt.Printf("result:      %s. %d passed; %d failed; (%s)\n", getCheckResultLabel(exitCode == 0), run.Passed, run.Failed, time.Duration(run.DurationMs)*time.Millisecond)
+			if run.Errored > 0 {
+				fmt.Printf("errored:     %d check(s) couldn't be evaluated\n", run.Errored)
+			}
+			if run.Skipped > 0 {
+				fmt.Printf("skipped:     %d check(s) not selected for the run\n", run.Skipped)
+			}
+			if run.Interrupted {
+				fmt.Println("note:        run was interrupted")
+			}
+			fmt.Println()
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "CHECK KEY\tSTATUS\tDATASET\tEXPRESSION\tACTUAL VALUE\tERROR")
+			for i, result := range results {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+					result.CheckKey, checkResults[i].StatusLabel(), result.Dataset, result.Expression, result.ActualValue, result.Error)
+			}
+			return tw.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&failOn, "fail-on", string(internal.SeverityError), "lowest severity of failed checks labeling the run as failed: info, warn, error or critical")
+	cmd.Flags().BoolVar(&strict, "strict", false, "label the run as failed if checks failed only with severities below --fail-on (warn and above)")
+
+	return cmd
+}
+
+// historyCheckResults converts persisted results to check results to apply the same exit code policy as the 'check' command
+func historyCheckResults(results []internal.HistoryCheckResult) []CheckResult {
+	checkResults := make([]CheckResult, len(results))
+	for i, result := range results {
+		checkResults[i] = CheckResult{
+			DataSource:  result.DataSource,
+			Dataset:     result.Dataset,
+			Expression:  result.Expression,
+			Description: result.Description,
+			OnFail:      result.OnFail,
+			Pass:        result.Pass,
+			ActualVal:   result.ActualValue,
+			Err:         result.Error,
+			DurationMs:  result.DurationMs,
+		}
+	}
+	return checkResults
+}
+
+func newHistoryTrendCommand(app internal.DbqCliApp) *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "trend <check-key>",
+		Short: "Shows results of a single check over time",
+		Long: `Shows results of a single check over time, oldest first. The check key is listed by 'dbqctl history show'.
+`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			history, err := openHistoryStore(app)
+			if err != nil {
+				return err
+			}
+
+			results, err := history.CheckTrend(args[0], limit)
+			if err != nil {
+				return err
+			}
+			if len(results) == 0 {
+				return fmt.Errorf("no results found for check: %s", args[0])
+			}
+
+			last := results[len(results)-1]
+			fmt.Printf("%s : %s\n\n", last.Dataset, last.Expression)
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "RUN\tTIMESTAMP\tSTATUS\tACTUAL VALUE\tERROR")
+			for _, result := range results {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+					result.RunID, result.Timestamp.Local().Format(time.DateTime), getCheckResultLabel(result.Pass), result.ActualValue, result.Error)
+			}
+			return tw.Flush()
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "n", 30, "maximum number of results to show")
+
+	return cmd
+}
+
+func listHistoryRuns(app internal.DbqCliApp, limit int) error {
+	history, err := openHistoryStore(app)
+	if err != nil {
+		return err
+	}
+
+	runs, err := history.ListRuns(limit)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RUN\tSTARTED AT\tCHECKS FILE\tSHA256\tPASSED\tFAILED\tDURATION")
+	for _, run := range runs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+			run.ID, run.StartedAt.Local().Format(time.DateTime), run.ChecksFile, shortHash(run.ChecksFileHash),
+			run.Passed, run.Failed, time.Duration(run.DurationMs)*time.Millisecond)
+	}
+	return tw.Flush()
+}
+
+func openHistoryStore(app internal.DbqCliApp) (*internal.HistoryStore, error) {
+	history, err := app.GetHistoryStore()
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		return nil, fmt.Errorf("check results history is disabled in dbq configuration")
+	}
+	return history, nil
+}
+
+// saveCheckRunHistory persists the report in the history store under the given run id and assigns the id to the report
+func saveCheckRunHistory(app internal.DbqCliApp, checksCfg *internal.ChecksFile, report *CheckReport, runId string, startedAt time.Time) error {
+	history, err := app.GetHistoryStore()
+	if err != nil || history == nil {
+		return err
+	}
+
+	run := &internal.HistoryRun{
+		ID:             runId,
+		StartedAt:      startedAt,
+		ChecksFile:     checksCfg.Path,
+		ChecksFileHash: checksCfg.Hash,
+		Environment:    report.Environment,
+		Passed:         report.Passed,
+		Failed:         report.Failed,
+		Errored:        report.Errored,
+		Skipped:        report.Skipped,
+		Interrupted:    report.Interrupted,
+		DurationMs:     report.Duration.Milliseconds(),
+	}
+
+	results := make([]internal.HistoryCheckResult, 0, len(report.Results))
+	for _, result := range report.Results {
+		results = append(results, internal.HistoryCheckResult{
+			Timestamp:   startedAt,
+			CheckKey:    internal.HistoryCheckKey(report.Environment, result.DataSource, result.Dataset, result.Expression, result.Description),
+			DataSource:  result.DataSource,
+			Dataset:     result.Dataset,
+			Expression:  result.Expression,
+			Description: result.Description,
+			OnFail:      result.OnFail,
+			Pass:        result.Pass,
+			ActualValue: result.ActualVal,
+			Error:       result.Err,
+			DurationMs:  result.DurationMs,
+		})
+	}
+
+	if err := history.SaveRun(run, results); err != nil {
+		return err
+	}
+
+	report.RunID = run.ID
+	return nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
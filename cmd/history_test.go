@@ -0,0 +1,136 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DataBridgeTech/dbqctl/internal"
+)
+
+func TestHistoryRunExitCode(t *testing.T) {
+	result := func(onFail string, pass bool, err string) internal.HistoryCheckResult {
+		return internal.HistoryCheckResult{OnFail: onFail, Pass: pass, Error: err}
+	}
+
+	tests := []struct {
+		name    string
+		results []internal.HistoryCheckResult
+		failOn  internal.Severity
+		strict  bool
+		want    int
+	}{
+		{"all passed", []internal.HistoryCheckResult{result("error", true, "")}, internal.SeverityError, false, 0},
+		{"only warn failed", []internal.HistoryCheckResult{result("error", true, ""), result("warn", false, "")}, internal.SeverityError, false, 0},
+		{"only info failed with strict", []internal.HistoryCheckResult{result("info", false, "")}, internal.SeverityError, true, 0},
+		{"warn failed with strict", []internal.HistoryCheckResult{result("warn", false, "")}, internal.SeverityError, true, ExitCodeWarnings},
+		{"warn failed with fail-on warn", []internal.HistoryCheckResult{result("warn", false, "")}, internal.SeverityWarn, false, ExitCodeChecksFailed},
+		{"error failed", []internal.HistoryCheckResult{result("error", false, "")}, internal.SeverityError, false, ExitCodeChecksFailed},
+		{"errored check", []internal.HistoryCheckResult{result("info", false, "timeout"), result("critical", false, "")}, internal.SeverityError, false, ExitCodeConfigError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkRunExitCode(historyCheckResults(tt.results), tt.failOn, tt.strict); got != tt.want {
+				t.Errorf("checkRunExitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryStatusLabel(t *testing.T) {
+	results := historyCheckResults([]internal.HistoryCheckResult{
+		{OnFail: "error", Pass: true},
+		{OnFail: "warn", Pass: false},
+		{OnFail: "error", Pass: false, Error: "boom"},
+	})
+
+	want := []string{"ok", "WARNING", "ERROR"}
+	for i, result := range results {
+		if got := result.StatusLabel(); got != want[i] {
+			t.Errorf("result %d: StatusLabel() = %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+// historyTestApp serves a history store, nil when history is disabled
+type historyTestApp struct {
+	internal.DbqCliApp
+	store *internal.HistoryStore
+}
+
+func (a *historyTestApp) GetHistoryStore() (*internal.HistoryStore, error) {
+	return a.store, nil
+}
+
+func TestSaveCheckRunHistory(t *testing.T) {
+	store, err := internal.OpenHistoryStore(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	startedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	checksCfg := &internal.ChecksFile{Path: "checks.yaml", Hash: "abc"}
+	report := &CheckReport{
+		Environment: "staging",
+		Passed:      1,
+		Failed:      1,
+		Duration:    1500 * time.Millisecond,
+		Results: []CheckResult{
+			{DataSource: "pg", Dataset: "public.orders", Expression: "row_count > 0", OnFail: "error", Pass: true, ActualVal: "42"},
+			{DataSource: "pg", Dataset: "public.orders", Expression: "not_null(id)", OnFail: "warn", ActualVal: "3"},
+		},
+	}
+
+	runId := internal.NewHistoryRunId(startedAt)
+	if err := saveCheckRunHistory(&historyTestApp{store: store}, checksCfg, report, runId, startedAt); err != nil {
+		t.Fatal(err)
+	}
+	if report.RunID != runId {
+		t.Errorf("report.RunID = %q, want %q", report.RunID, runId)
+	}
+
+	run, results, err := store.GetRun("latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.ID != runId || run.ChecksFile != "checks.yaml" || run.Environment != "staging" || run.Passed != 1 || run.Failed != 1 || run.DurationMs != 1500 {
+		t.Errorf("saved run = %+v", run)
+	}
+	if len(results) != 2 || results[1].Expression != "not_null(id)" || results[1].OnFail != "warn" || results[1].ActualValue != "3" {
+		t.Fatalf("saved results = %+v", results)
+	}
+
+	trend, err := store.CheckTrend(internal.HistoryCheckKey("staging", "pg", "public.orders", "row_count > 0", ""), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trend) != 1 || trend[0].ActualValue != "42" {
+		t.Errorf("CheckTrend() = %+v", trend)
+	}
+}
+
+func TestSaveCheckRunHistoryDisabled(t *testing.T) {
+	report := &CheckReport{Results: []CheckResult{{Expression: "row_count > 0", Pass: true}}}
+	if err := saveCheckRunHistory(&historyTestApp{}, &internal.ChecksFile{}, report, "run", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if report.RunID != "" {
+		t.Errorf("report.RunID = %q with history disabled", report.RunID)
+	}
+}
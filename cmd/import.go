@@ -18,13 +18,13 @@ import (
 	"fmt"
 	"github.com/DataBridgeTech/dbqctl/internal"
 	"github.com/spf13/cobra"
-	"log"
 )
 
 func NewImportCommand(app internal.DbqCliApp) *cobra.Command {
 	var dataSource string
 	var filter string
 	var updateCfg bool
+	var dryRun bool
 
 	cmd := &cobra.Command{
 		Use:   "import",
@@ -44,10 +44,11 @@ This command is useful for quickly onboarding data from external systems, allowi
 			}
 
 			for _, curDataSource := range importFromSources {
-				datasets, err := app.ImportDatasets(curDataSource, filter)
+				ctx, cancel := withTimeout(cmd.Context(), 0)
+				datasets, err := app.ImportDatasets(ctx, curDataSource, filter)
+				cancel()
 				if err != nil {
-					log.Println("Failed to fetch datasets: " + err.Error())
-					return nil
+					return configError(cmd, fmt.Errorf("failed to fetch datasets of '%s': %w", curDataSource, err))
 				}
 
 				fmt.Printf("found %d datasets in %s to import: %v\n", len(datasets), curDataSource, datasets)
@@ -58,12 +59,8 @@ This command is useful for quickly onboarding data from external systems, allowi
 				}
 			}
 
-			if updateCfg {
-				err := app.SaveDbqConfig()
-				if err != nil {
-					return err
-				}
-				fmt.Println("dbqctl config has been updated")
+			if dryRun || updateCfg {
+				return saveConfigChanges(app, dryRun, "dbqctl config has been updated")
 			}
 
 			return nil
@@ -73,6 +70,7 @@ This command is useful for quickly onboarding data from external systems, allowi
 	cmd.Flags().StringVarP(&dataSource, "datasource", "d", "", "datasource from which datasets will be imported")
 	cmd.Flags().StringVarP(&filter, "filter", "f", "", "filter applied for dataset selection")
 	cmd.Flags().BoolVarP(&updateCfg, "update-config", "u", false, "update dbq config file in place")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print a unified diff of the config changes instead of writing them")
 
 	return cmd
 }
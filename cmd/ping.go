@@ -39,8 +39,13 @@ This is useful for quickly determining if the data source is online and respondi
 			}
 
 			for _, curDataSource := range sourcesToPing {
+				if cmd.Context().Err() != nil {
+					break
+				}
 				fmt.Printf("Conneting to data source: %s...\n", curDataSource)
-				info, err := app.PingDataSource(curDataSource)
+				ctx, cancel := withTimeout(cmd.Context(), 0)
+				info, err := app.PingDataSource(ctx, curDataSource)
+				cancel()
 				if err != nil {
 					fmt.Printf("Connection failed: %s\n", err.Error())
 				} else {
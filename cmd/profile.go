@@ -15,16 +15,20 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
 	"github.com/DataBridgeTech/dbqcore"
 	"github.com/DataBridgeTech/dbqctl/internal"
 	"github.com/spf13/cobra"
-	"runtime"
 )
 
 type ProfileResultOutput struct {
-	Profiles map[string]*dbqcore.TableMetrics `json:"profiles"`
+	Environment string                           `json:"environment,omitempty"`
+	Profiles    map[string]*dbqcore.TableMetrics `json:"profiles"`
 }
 
 func NewProfileCommand(app internal.DbqCliApp) *cobra.Command {
@@ -32,6 +36,9 @@ func NewProfileCommand(app internal.DbqCliApp) *cobra.Command {
 	var dataSet string
 	var sample bool
 	var maxConcurrent int
+	var format string
+	var outputFile string
+	var snapshotDir string
 
 	cmd := &cobra.Command{
 		Use:   "profile",
@@ -42,8 +49,15 @@ and other relevant statistics depending on the data type and the capabilities of
 
 This command is useful for understanding the characteristics and quality of your data. It provides a quick overview of the data distribution, identifies potential data quality issues like missing values, 
 and helps in making better decisions about data processing and analysis.
+
+The exit code is 2 if any dataset couldn't be profiled, results of the other datasets are still written but no snapshot is saved.
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			reportWriter, err := NewProfileReportWriter(format)
+			if err != nil {
+				return err
+			}
+
 			var dataSetsToProfile []string
 			if dataSet != "" {
 				dataSetsToProfile = append(dataSetsToProfile, dataSet)
@@ -57,26 +71,66 @@ and helps in making better decisions about data processing and analysis.
 			}
 
 			profileResults := &ProfileResultOutput{
-				Profiles: make(map[string]*dbqcore.TableMetrics),
+				Environment: app.GetEnvironment(),
+				Profiles:    make(map[string]*dbqcore.TableMetrics),
 			}
 
+			var failedDataSets []string
 			for _, curDataSet := range dataSetsToProfile {
-				fmt.Printf("Profiling '%s' (using %d jobs) , this may take some time...\n", curDataSet, maxConcurrent)
-				metrics, err := app.ProfileDataset(dataSource, curDataSet, sample, maxConcurrent)
+				if cmd.Context().Err() != nil {
+					break
+				}
+
+				fmt.Fprintf(os.Stderr, "Profiling '%s' (using %d jobs) , this may take some time...\n", curDataSet, maxConcurrent)
+				ctx, cancel := withTimeout(cmd.Context(), 0)
+				metrics, err := app.ProfileDataset(ctx, dataSource, curDataSet, sample, maxConcurrent)
+				cancel()
 				if err != nil {
-					fmt.Printf("Failed to profile %s: %s\n", curDataSet, err)
+					fmt.Fprintf(os.Stderr, "Failed to profile %s: %s\n", curDataSet, err)
+					failedDataSets = append(failedDataSets, curDataSet)
 				} else {
 					profileResults.Profiles[curDataSet] = metrics
 				}
 			}
 
-			// todo: introduce output format flag
-			jsonData, err := json.Marshal(profileResults)
-			if err != nil {
-				fmt.Println("failed to marshal metrics to JSON")
-				panic(err)
+			out := io.Writer(os.Stdout)
+			if outputFile != "" {
+				file, err := os.Create(outputFile)
+				if err != nil {
+					return fmt.Errorf("failed to create output file: %w", err)
+				}
+				defer file.Close()
+				out = file
+			}
+
+			if err := reportWriter.Write(out, profileResults); err != nil {
+				return fmt.Errorf("failed to write profiling results: %w", err)
+			}
+
+			if outputFile != "" {
+				fmt.Fprintf(os.Stderr, "Profiling results saved to %s\n", outputFile)
+			}
+
+			// an incomplete snapshot would be reported as removed datasets by 'profile diff'
+			if err := cmd.Context().Err(); err != nil {
+				return fmt.Errorf("profiling was interrupted, %d of %d dataset(s) profiled: %w", len(profileResults.Profiles), len(dataSetsToProfile), err)
+			}
+			if len(failedDataSets) > 0 {
+				cmd.SilenceUsage = true
+				failedErr := fmt.Errorf("failed to profile %d of %d dataset(s): %s", len(failedDataSets), len(dataSetsToProfile), strings.Join(failedDataSets, ", "))
+				if snapshotDir != "" {
+					failedErr = fmt.Errorf("%w, profile snapshot not saved", failedErr)
+				}
+				return &ExitCodeError{Code: ExitCodeConfigError, Err: failedErr}
+			}
+
+			if snapshotDir != "" {
+				snapshotPath, err := saveProfileSnapshot(snapshotDir, dataSource, profileResults)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(os.Stderr, "Profile snapshot saved to %s\n", snapshotPath)
 			}
-			fmt.Println(string(jsonData))
 
 			return nil
 		},
@@ -87,7 +141,12 @@ and helps in making better decisions about data processing and analysis.
 
 	cmd.Flags().StringVarP(&dataSet, "dataset", "s", "", "dataset within specified data source")
 	cmd.Flags().BoolVarP(&sample, "sample", "m", false, "include data samples in profiling report")
+	cmd.Flags().StringVarP(&format, "format", "f", ProfileFormatJson, "output format: json, json-pretty, yaml, table or html")
+	cmd.Flags().StringVar(&outputFile, "output-file", "", "write profiling results to the file instead of stdout")
+	cmd.Flags().StringVar(&snapshotDir, "snapshot-dir", "", "save profiling results as a timestamped snapshot in the directory, to be compared later with 'profile diff'")
 	cmd.Flags().IntVarP(&maxConcurrent, "jobs", "j", runtime.NumCPU(), "set the maximum number of jobs to execute against the datasource during profiling. By default, this is equal to the number of CPUs on the host machine.")
 
+	cmd.AddCommand(newProfileDiffCommand())
+
 	return cmd
 }
@@ -0,0 +1,169 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/DataBridgeTech/dbqctl/internal"
+	"github.com/spf13/cobra"
+)
+
+func newProfileDiffCommand() *cobra.Command {
+	var tolerances internal.ProfileDiffTolerances
+	var format string
+	var failOnDrift bool
+
+	cmd := &cobra.Command{
+		Use:   "diff <old-snapshot> <new-snapshot>",
+		Short: "Compares two profile snapshots and reports schema and statistics drift",
+		Annotations: map[string]string{
+			skipConfigAnnotation: "true",
+		},
+		Long: `The 'profile diff' command compares two profiling results saved with 'dbqctl profile --snapshot-dir' (or produced by 'dbqctl profile --format json').
+It reports added, removed and retyped columns, row count changes, null ratio changes and min/max/avg/stddev shifts beyond the given tolerances.
+
+Use --fail-on-drift to exit with a non-zero code when a drift is detected, e.g. to gate a pipeline.
+`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != OutputFormatText && format != OutputFormatJson {
+				return fmt.Errorf("unsupported output format '%s' (expected %s or %s)", format, OutputFormatText, OutputFormatJson)
+			}
+
+			oldProfiles, err := loadProfileSnapshot(args[0])
+			if err != nil {
+				return err
+			}
+			newProfiles, err := loadProfileSnapshot(args[1])
+			if err != nil {
+				return err
+			}
+
+			diff := internal.DiffProfiles(oldProfiles.Profiles, newProfiles.Profiles, tolerances)
+			if format == OutputFormatJson {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(diff); err != nil {
+					return err
+				}
+			} else {
+				writeProfileDiff(os.Stdout, diff)
+			}
+
+			if failOnDrift && diff.HasDrift() {
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+				return &ExitCodeError{Code: 1}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Float64Var(&tolerances.RowCountPct, "row-count-tolerance", 10, "allowed row count change, in percent")
+	cmd.Flags().Float64Var(&tolerances.NullRatioPts, "null-ratio-tolerance", 5, "allowed change of a column null ratio, in percentage points")
+	cmd.Flags().Float64Var(&tolerances.StatsPct, "stats-tolerance", 10, "allowed change of a column min/max/avg/stddev, in percent")
+	cmd.Flags().StringVarP(&format, "format", "f", OutputFormatText, "output format: text or json")
+	cmd.Flags().BoolVar(&failOnDrift, "fail-on-drift", false, "exit with code 1 if any drift is detected")
+
+	return cmd
+}
+
+func loadProfileSnapshot(path string) (*ProfileResultOutput, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile snapshot: %w", err)
+	}
+
+	var snapshot ProfileResultOutput
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse profile snapshot %s: %w", path, err)
+	}
+	return &snapshot, nil
+}
+
+// saveProfileSnapshot writes profiling results as a timestamped json file into the snapshot directory
+func saveProfileSnapshot(snapshotDir string, dataSource string, profiles *ProfileResultOutput) (string, error) {
+	if err := os.MkdirAll(snapshotDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	snapshotPath := filepath.Join(snapshotDir, fmt.Sprintf("%s_%s.json", dataSource, time.Now().UTC().Format("20060102T150405Z")))
+	if err := os.WriteFile(snapshotPath, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write profile snapshot: %w", err)
+	}
+
+	return snapshotPath, nil
+}
+
+func writeProfileDiff(w io.Writer, diff *internal.ProfileDiff) {
+	drifted := 0
+	for _, dataset := range diff.Datasets {
+		switch dataset.Status {
+		case internal.ProfileDiffAdded:
+			fmt.Fprintf(w, "+ %s: new dataset (%d rows)\n", dataset.Dataset, dataset.NewRowCount)
+		case internal.ProfileDiffRemoved:
+			fmt.Fprintf(w, "- %s: dataset removed\n", dataset.Dataset)
+		case internal.ProfileDiffUnchanged:
+			fmt.Fprintf(w, "  %s: no drift\n", dataset.Dataset)
+		default:
+			fmt.Fprintf(w, "~ %s:\n", dataset.Dataset)
+			if dataset.RowCountDrift {
+				fmt.Fprintf(w, "    row count: %d -> %d (%s)\n", dataset.OldRowCount, dataset.NewRowCount, formatChange(dataset.RowCountChange, "%"))
+			}
+			for _, column := range dataset.AddedColumns {
+				fmt.Fprintf(w, "    + column %s (%s)\n", column.Column, column.NewType)
+			}
+			for _, column := range dataset.RemovedColumns {
+				fmt.Fprintf(w, "    - column %s (%s)\n", column.Column, column.OldType)
+			}
+			for _, column := range dataset.RetypedColumns {
+				fmt.Fprintf(w, "    ~ column %s: type %s -> %s\n", column.Column, column.OldType, column.NewType)
+			}
+			for _, change := range dataset.StatChanges {
+				units := "%"
+				if change.Metric == "null_ratio" {
+					units = " pts"
+				}
+				fmt.Fprintf(w, "    ~ column %s: %s %.6g -> %.6g (%s)\n", change.Column, change.Metric, change.Old, change.New, formatChange(change.Change, units))
+			}
+		}
+
+		if dataset.Status != internal.ProfileDiffUnchanged {
+			drifted += 1
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d of %d datasets drifted\n", drifted, len(diff.Datasets))
+}
+
+func formatChange(change *float64, units string) string {
+	if change == nil {
+		return "n/a"
+	}
+	return strings.TrimSpace(fmt.Sprintf("%+.2f%s", *change, units))
+}
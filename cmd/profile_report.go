@@ -0,0 +1,318 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DataBridgeTech/dbqcore"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ProfileFormatJson       = "json"
+	ProfileFormatJsonPretty = "json-pretty"
+	ProfileFormatYaml       = "yaml"
+	ProfileFormatTable      = "table"
+	ProfileFormatHtml       = "html"
+)
+
+type ProfileReportWriter interface {
+	Write(w io.Writer, profiles *ProfileResultOutput) error
+}
+
+func NewProfileReportWriter(format string) (ProfileReportWriter, error) {
+	switch strings.ToLower(format) {
+	case "", ProfileFormatJson:
+		return &profileJsonWriter{}, nil
+	case ProfileFormatJsonPretty:
+		return &profileJsonWriter{pretty: true}, nil
+	case ProfileFormatYaml, "yml":
+		return &profileYamlWriter{}, nil
+	case ProfileFormatTable:
+		return &profileTableWriter{}, nil
+	case ProfileFormatHtml:
+		return &profileHtmlWriter{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported profile format '%s' (expected one of: %s, %s, %s, %s, %s)", format,
+			ProfileFormatJson, ProfileFormatJsonPretty, ProfileFormatYaml, ProfileFormatTable, ProfileFormatHtml)
+	}
+}
+
+type profileJsonWriter struct {
+	pretty bool
+}
+
+func (jw *profileJsonWriter) Write(w io.Writer, profiles *ProfileResultOutput) error {
+	encoder := json.NewEncoder(w)
+	if jw.pretty {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(profiles)
+}
+
+type profileYamlWriter struct{}
+
+// Write converts the JSON representation to YAML, so both formats share the same field names
+func (yw *profileYamlWriter) Write(w io.Writer, profiles *ProfileResultOutput) error {
+	jsonData, err := json.Marshal(profiles)
+	if err != nil {
+		return err
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(jsonData, &node); err != nil {
+		return err
+	}
+	resetYamlStyle(&node)
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func resetYamlStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYamlStyle(child)
+	}
+}
+
+type profileTableWriter struct{}
+
+func (tw *profileTableWriter) Write(w io.Writer, profiles *ProfileResultOutput) error {
+	for i, dataset := range sortedProfileDatasets(profiles) {
+		metrics := profiles.Profiles[dataset]
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		fmt.Fprintf(w, "%s: %d rows, %d columns, profiled in %s\n", dataset, metrics.TotalRows, len(metrics.ColumnsMetrics),
+			time.Duration(metrics.ProfilingDurationMs)*time.Millisecond)
+
+		table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "#\tCOLUMN\tTYPE\tNULLS\tBLANKS\tMIN\tMAX\tAVG\tSTDDEV\tMOST FREQUENT")
+		for _, column := range sortedColumnsMetrics(metrics) {
+			fmt.Fprintf(table, "%d\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				column.ColumnPosition, column.ColumnName, column.DataType, column.NullCount,
+				formatIntPtr(column.BlankCount), formatFloatPtr(column.MinValue), formatFloatPtr(column.MaxValue),
+				formatFloatPtr(column.AvgValue), formatFloatPtr(column.StddevValue), truncate(formatStringPtr(column.MostFrequentValue), 40))
+		}
+		if err := table.Flush(); err != nil {
+			return err
+		}
+
+		for _, dbqErr := range metrics.DbqErrors {
+			fmt.Fprintf(w, "error: %s\n", dbqErr)
+		}
+	}
+
+	return nil
+}
+
+type profileHtmlWriter struct{}
+
+type htmlProfile struct {
+	Dataset    string
+	Metrics    *dbqcore.TableMetrics
+	Columns    []*dbqcore.ColumnMetrics
+	ProfiledAt string
+	Duration   string
+	SampleCols []string
+}
+
+func (hw *profileHtmlWriter) Write(w io.Writer, profiles *ProfileResultOutput) error {
+	tmpl, err := template.New("profile").Funcs(template.FuncMap{
+		"float":  formatFloatPtr,
+		"int":    formatIntPtr,
+		"string": formatStringPtr,
+		"nullPct": func(nulls uint64, total uint64) string {
+			if total == 0 {
+				return "-"
+			}
+			return strconv.FormatFloat(float64(nulls)*100/float64(total), 'f', 2, 64) + "%"
+		},
+		"cell": func(row map[string]interface{}, col string) string {
+			return fmt.Sprintf("%v", row[col])
+		},
+	}).Parse(profileHtmlTemplate)
+	if err != nil {
+		return err
+	}
+
+	var data struct {
+		GeneratedAt string
+		Profiles    []htmlProfile
+	}
+	data.GeneratedAt = time.Now().Format(time.DateTime)
+
+	for _, dataset := range sortedProfileDatasets(profiles) {
+		metrics := profiles.Profiles[dataset]
+		profile := htmlProfile{
+			Dataset:  dataset,
+			Metrics:  metrics,
+			Columns:  sortedColumnsMetrics(metrics),
+			Duration: (time.Duration(metrics.ProfilingDurationMs) * time.Millisecond).String(),
+		}
+		if metrics.ProfiledAt > 0 {
+			profile.ProfiledAt = time.Unix(metrics.ProfiledAt, 0).Format(time.DateTime)
+		}
+		if len(metrics.RowsSample) > 0 {
+			for _, column := range profile.Columns {
+				profile.SampleCols = append(profile.SampleCols, column.ColumnName)
+			}
+		}
+		data.Profiles = append(data.Profiles, profile)
+	}
+
+	return tmpl.Execute(w, data)
+}
+
+func sortedProfileDatasets(profiles *ProfileResultOutput) []string {
+	datasets := make([]string, 0, len(profiles.Profiles))
+	for dataset := range profiles.Profiles {
+		datasets = append(datasets, dataset)
+	}
+	sort.Strings(datasets)
+	return datasets
+}
+
+func sortedColumnsMetrics(metrics *dbqcore.TableMetrics) []*dbqcore.ColumnMetrics {
+	columns := make([]*dbqcore.ColumnMetrics, 0, len(metrics.ColumnsMetrics))
+	for _, column := range metrics.ColumnsMetrics {
+		columns = append(columns, column)
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		return columns[i].ColumnPosition < columns[j].ColumnPosition
+	})
+	return columns
+}
+
+func formatFloatPtr(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func formatIntPtr(v *int64) string {
+	if v == nil {
+		return "-"
+	}
+	return strconv.FormatInt(*v, 10)
+}
+
+func formatStringPtr(v *string) string {
+	if v == nil {
+		return "-"
+	}
+	return *v
+}
+
+func truncate(value string, maxLen int) string {
+	runes := []rune(value)
+	if len(runes) <= maxLen {
+		return value
+	}
+	return string(runes[:maxLen-1]) + "…"
+}
+
+const profileHtmlTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>dbqctl profile report</title>
+<style>
+  body { font-family: -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; margin: 2rem; color: #1f2328; }
+  h1 { font-size: 1.6rem; }
+  h2 { font-size: 1.3rem; margin-top: 2.5rem; border-bottom: 1px solid #d0d7de; padding-bottom: .3rem; }
+  nav a { margin-right: 1rem; }
+  .summary span { display: inline-block; margin-right: 2rem; }
+  .muted { color: #656d76; }
+  .errors { color: #cf222e; }
+  table { border-collapse: collapse; margin-top: 1rem; font-size: .9rem; }
+  th, td { border: 1px solid #d0d7de; padding: .3rem .6rem; text-align: left; vertical-align: top; }
+  th { background: #f6f8fa; }
+  td.num { text-align: right; font-variant-numeric: tabular-nums; }
+  tr.has-nulls td.nulls { color: #9a6700; font-weight: 600; }
+  .samples { max-width: 100%; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>dbqctl profile report</h1>
+<p class="muted">Generated at {{.GeneratedAt}}</p>
+<nav>{{range .Profiles}}<a href="#{{.Dataset}}">{{.Dataset}}</a>{{end}}</nav>
+{{range .Profiles}}
+<h2 id="{{.Dataset}}">{{.Dataset}}</h2>
+<p class="summary">
+  <span><b>Rows:</b> {{.Metrics.TotalRows}}</span>
+  <span><b>Columns:</b> {{len .Columns}}</span>
+  {{if .ProfiledAt}}<span><b>Profiled at:</b> {{.ProfiledAt}}</span>{{end}}
+  <span><b>Duration:</b> {{.Duration}}</span>
+</p>
+{{if .Metrics.DbqErrors}}<ul class="errors">{{range .Metrics.DbqErrors}}<li>{{.}}</li>{{end}}</ul>{{end}}
+<table>
+  <thead>
+    <tr><th>#</th><th>Column</th><th>Type</th><th>Nulls</th><th>Null %</th><th>Blanks</th><th>Min</th><th>Max</th><th>Avg</th><th>Stddev</th><th>Most frequent value</th></tr>
+  </thead>
+  <tbody>
+  {{$total := .Metrics.TotalRows}}
+  {{range .Columns}}
+    <tr{{if .NullCount}} class="has-nulls"{{end}}>
+      <td class="num">{{.ColumnPosition}}</td>
+      <td>{{.ColumnName}}{{if .ColumnComment}}<div class="muted">{{.ColumnComment}}</div>{{end}}</td>
+      <td>{{.DataType}}</td>
+      <td class="num nulls">{{.NullCount}}</td>
+      <td class="num nulls">{{nullPct .NullCount $total}}</td>
+      <td class="num">{{int .BlankCount}}</td>
+      <td class="num">{{float .MinValue}}</td>
+      <td class="num">{{float .MaxValue}}</td>
+      <td class="num">{{float .AvgValue}}</td>
+      <td class="num">{{float .StddevValue}}</td>
+      <td>{{string .MostFrequentValue}}</td>
+    </tr>
+  {{end}}
+  </tbody>
+</table>
+{{if .Metrics.RowsSample}}
+<h3>Sample rows</h3>
+<div class="samples">
+<table>
+  <thead><tr>{{range .SampleCols}}<th>{{.}}</th>{{end}}</tr></thead>
+  <tbody>
+  {{$cols := .SampleCols}}
+  {{range .Metrics.RowsSample}}
+    {{$row := .}}
+    <tr>{{range $cols}}<td>{{cell $row .}}</td>{{end}}</tr>
+  {{end}}
+  </tbody>
+</table>
+</div>
+{{end}}
+{{end}}
+</body>
+</html>
+`
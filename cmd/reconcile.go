@@ -0,0 +1,155 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/DataBridgeTech/dbqctl/internal"
+	"github.com/spf13/cobra"
+)
+
+func NewReconcileCommand(app internal.DbqCliApp) *cobra.Command {
+	var source, target string
+	var opts internal.ReconcileOptions
+	var outputFormat string
+
+	cmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Compares a dataset with its copy, possibly on another data source",
+		Long: `The 'reconcile' command proves that a copy of a dataset (e.g. a Postgres table replicated into ClickHouse) matches
+its source. Row counts and per-column aggregates (sum, min, max and null count) are compared first; columns present
+on one side only are reported but don't fail the reconciliation.
+
+With --buckets N the key range is split into N buckets and a checksum of every bucket (a sum of hashes of the key and
+--hash-columns) is compared. Mismatching buckets are split again up to --depth levels, so the output lists the
+narrowest key ranges which differ. The key has to be an integer column, hashed columns must have the same text
+representation on both sides (e.g. integers and strings).
+
+The exit code is 1 if the datasets don't match.
+`,
+		Example: `  dbqctl reconcile --source pg:public.orders --target ch:analytics.orders --key order_id
+  dbqctl reconcile --source pg:public.orders --target ch:analytics.orders --key order_id --buckets 16 --hash-columns status`,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			defer func() { err = configError(cmd, err) }()
+
+			if opts.Source, err = internal.ParseReconcileTarget(source); err != nil {
+				return err
+			}
+			if opts.Target, err = internal.ParseReconcileTarget(target); err != nil {
+				return err
+			}
+			if opts.Buckets < 0 || opts.Depth < 1 {
+				return fmt.Errorf("--buckets must not be negative and --depth must be at least 1")
+			}
+			if len(opts.HashColumns) > 0 && opts.Buckets == 0 {
+				return fmt.Errorf("--hash-columns requires --buckets")
+			}
+			switch strings.ToLower(outputFormat) {
+			case OutputFormatText, OutputFormatJson:
+			default:
+				return fmt.Errorf("unsupported output format '%s' (expected one of: %s, %s)", outputFormat, OutputFormatText, OutputFormatJson)
+			}
+
+			ctx, cancel := withTimeout(cmd.Context(), 0)
+			defer cancel()
+			result, err := app.Reconcile(ctx, opts)
+			if err != nil {
+				return fmt.Errorf("failed to reconcile %s with %s: %w", opts.Source, opts.Target, err)
+			}
+
+			if strings.ToLower(outputFormat) == OutputFormatJson {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				err = encoder.Encode(result)
+			} else {
+				err = writeReconcileText(os.Stdout, result, opts.Buckets > 0)
+			}
+			if err != nil {
+				return fmt.Errorf("error while writing reconciliation results: %w", err)
+			}
+
+			if !result.Match {
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+				return &ExitCodeError{Code: 1}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&source, "source", "", "source dataset as <datasource>:<dataset>, e.g. pg:public.orders")
+	_ = cmd.MarkFlagRequired("source")
+	cmd.Flags().StringVar(&target, "target", "", "target dataset as <datasource>:<dataset>, e.g. ch:analytics.orders")
+	_ = cmd.MarkFlagRequired("target")
+	cmd.Flags().StringVar(&opts.Key, "key", "", "column identifying rows on both sides, an integer column is required for --buckets")
+	_ = cmd.MarkFlagRequired("key")
+	cmd.Flags().StringSliceVar(&opts.Columns, "columns", nil, "compare only these columns (default is all columns present on both sides)")
+	cmd.Flags().StringVar(&opts.Where, "where", "", "filter rows on both sides, e.g. \"created_at < '2025-01-01'\"")
+	cmd.Flags().IntVar(&opts.Buckets, "buckets", 0, "compare checksums of N key ranges and narrow down the mismatching ones, zero disables checksums")
+	cmd.Flags().IntVar(&opts.Depth, "depth", 3, "number of levels mismatching key ranges are split into --buckets smaller ones")
+	cmd.Flags().StringSliceVar(&opts.HashColumns, "hash-columns", nil, "columns hashed along with the key into bucket checksums")
+	cmd.Flags().Float64Var(&opts.Tolerance, "tolerance", 0, "allowed relative difference of numeric aggregates in percent")
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", OutputFormatText, "output format: text or json")
+
+	return cmd
+}
+
+func writeReconcileText(w io.Writer, result *internal.ReconcileResult, checksums bool) error {
+	matchLabel := map[bool]string{true: "ok", false: "MISMATCH"}
+
+	fmt.Fprintf(w, "reconciling %s with %s by '%s'\n\n", result.Source, result.Target, result.Key)
+	fmt.Fprintf(w, "row count: %s (source %s, target %s)\n", matchLabel[result.RowCount.Match], result.RowCount.Source, result.RowCount.Target)
+	if len(result.SourceOnlyColumns) > 0 {
+		fmt.Fprintf(w, "columns only in source: %s\n", strings.Join(result.SourceOnlyColumns, ", "))
+	}
+	if len(result.TargetOnlyColumns) > 0 {
+		fmt.Fprintf(w, "columns only in target: %s\n", strings.Join(result.TargetOnlyColumns, ", "))
+	}
+
+	fmt.Fprintln(w)
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "COLUMN\tAGGREGATE\tSOURCE\tTARGET\tRESULT")
+	for _, value := range result.Aggregates {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", value.Column, value.Aggregate, value.Source, value.Target, matchLabel[value.Match])
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	if checksums {
+		fmt.Fprintf(w, "\nbuckets: %d compared, %d differ\n", result.BucketsCompared, len(result.MismatchedBuckets))
+		for _, bucket := range result.MismatchedBuckets {
+			fmt.Fprintf(w, "  %s %d..%d: source %d rows (checksum %s), target %d rows (checksum %s)\n",
+				result.Key, bucket.From, bucket.To, bucket.SourceRows, reconcileChecksum(bucket.SourceChecksum),
+				bucket.TargetRows, reconcileChecksum(bucket.TargetChecksum))
+		}
+	}
+
+	fmt.Fprintf(w, "\nreconcile result: %s\n", matchLabel[result.Match])
+	return nil
+}
+
+func reconcileChecksum(checksum string) string {
+	if checksum == "" {
+		return "-"
+	}
+	return checksum
+}
@@ -15,42 +15,170 @@
 package cmd
 
 import (
-	"log/slog"
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/DataBridgeTech/dbqctl/internal"
 	"github.com/spf13/cobra"
 )
 
-var verbose bool
+// ConfigFlagUsage describes the --config flag, which is also parsed before the commands are set up
+const ConfigFlagUsage = "config file, repeat to merge several files with later ones overriding earlier ones " +
+	"(default is $DBQ_CONFIG, ./dbq.yaml, $XDG_CONFIG_HOME/dbq/dbq.yaml or $HOME/.dbq.yaml, whichever is found first)"
+
+// EnvFlagUsage describes the --env flag, which is also parsed before the commands are set up
+const EnvFlagUsage = "environment from the 'environments' section of dbq config to apply (default is $DBQ_ENV)"
+
+// LenientFlagUsage describes the --lenient flag, which is also parsed before the commands are set up
+const LenientFlagUsage = "report unknown keys of config and checks files as warnings instead of errors"
+
+// Logging flags, also parsed before the commands are set up
+const (
+	VerboseFlagUsage   = "enables verbose logging, same as --log-level info"
+	LogLevelFlagUsage  = "log level: debug, info, warn or error (default error)"
+	LogFormatFlagUsage = "log format: text or json"
+	LogFileFlagUsage   = "append logs to the file instead of stderr"
+)
+
+// skipConfigAnnotation marks commands which don't need the dbq config to be loaded
+const skipConfigAnnotation = "dbqctl/skip-config"
+
+const (
+	// ExitCodeChecksFailed is returned when checks at or above the --fail-on severity failed
+	ExitCodeChecksFailed = 1
+	// ExitCodeConfigError is returned for config problems and checks which couldn't be evaluated (e.g. connection problems)
+	ExitCodeConfigError = 2
+	// ExitCodeWarnings is returned with --strict when only checks below the --fail-on severity failed
+	ExitCodeWarnings = 3
+	// ExitCodeInterrupted is returned when the run was cancelled by SIGINT/SIGTERM
+	ExitCodeInterrupted = 130
+)
+
+var timeout time.Duration
 
 var rootCmd = &cobra.Command{
 	Use:   "dbqctl",
 	Short: "dbqctl is a CLI tool for profiling data and running quality checks across various data sources",
 }
 
-func Execute() {
-	err := rootCmd.Execute()
+// ExitCodeError is returned by commands that have already reported their outcome
+// and only need the process to terminate with the given exit code, or with Err set to report it first
+type ExitCodeError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitCodeError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("exit code %d", e.Code)
+}
+
+func (e *ExitCodeError) Unwrap() error {
+	return e.Err
+}
+
+// configError reports the error with ExitCodeConfigError, unless it already carries an exit code
+func configError(cmd *cobra.Command, err error) error {
+	var exitCodeErr *ExitCodeError
+	if err == nil || errors.As(err, &exitCodeErr) {
+		return err
+	}
+	cmd.SilenceUsage = true
+	return &ExitCodeError{Code: ExitCodeConfigError, Err: err}
+}
+
+// Execute runs the root command with the given context and returns the process exit code.
+// Commands are expected to stop gracefully and report partial results once the context is cancelled
+func Execute(ctx context.Context) int {
+	err := rootCmd.ExecuteContext(ctx)
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "dbqctl: interrupted")
+		return ExitCodeInterrupted
+	}
+
 	if err != nil {
-		os.Exit(1)
+		var exitCodeErr *ExitCodeError
+		if errors.As(err, &exitCodeErr) {
+			return exitCodeErr.Code
+		}
+		var dataSourceErr *internal.DataSourceConfigError
+		if errors.As(err, &dataSourceErr) {
+			return ExitCodeConfigError
+		}
+		return 1
 	}
+	return 0
 }
 
 func AddCommands(app internal.DbqCliApp) {
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := app.GetConfigError(); err != nil && !skipsConfig(cmd) {
+			return configError(cmd, err)
+		}
+		printEnvironmentHeader(app)
+		return nil
+	}
+
 	rootCmd.AddCommand(NewPingCommand(app))
 	rootCmd.AddCommand(NewImportCommand(app))
 	rootCmd.AddCommand(NewCheckCommand(app))
+	rootCmd.AddCommand(NewExplainCommand(app))
 	rootCmd.AddCommand(NewProfileCommand(app))
+	rootCmd.AddCommand(NewReconcileCommand(app))
+	rootCmd.AddCommand(NewHistoryCommand(app))
+	rootCmd.AddCommand(NewSuggestCommand(app))
+	rootCmd.AddCommand(NewValidateCommand(app))
+	rootCmd.AddCommand(NewConfigCommand(app))
+	rootCmd.AddCommand(NewSchemaCommand())
 	rootCmd.AddCommand(NewVersionCommand())
+}
+
+func init() {
+	// flags parsed by main before the app is set up, registered here for help and validation
+	var dbqConfigFiles []string
+	rootCmd.PersistentFlags().StringArrayVar(&dbqConfigFiles, "config", nil, ConfigFlagUsage)
+	var environment string
+	rootCmd.PersistentFlags().StringVar(&environment, "env", "", EnvFlagUsage)
+	var lenient bool
+	rootCmd.PersistentFlags().BoolVar(&lenient, "lenient", false, LenientFlagUsage)
+	var logOpts internal.LogOptions
+	rootCmd.PersistentFlags().BoolVarP(&logOpts.Verbose, "verbose", "v", false, VerboseFlagUsage)
+	rootCmd.PersistentFlags().StringVar(&logOpts.Level, "log-level", "", LogLevelFlagUsage)
+	rootCmd.PersistentFlags().StringVar(&logOpts.Format, "log-format", internal.LogFormatText, LogFormatFlagUsage)
+	rootCmd.PersistentFlags().StringVar(&logOpts.File, "log-file", "", LogFileFlagUsage)
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "default timeout for every query, check or profile run against a data source (e.g. 30s, 5m), zero means no timeout")
+}
 
-	if verbose {
-		app.SetLogLevel(slog.LevelInfo)
+// skipsConfig reports whether the command (or its parent) can run without a loaded dbq config
+func skipsConfig(cmd *cobra.Command) bool {
+	for ; cmd != nil; cmd = cmd.Parent() {
+		if cmd.Annotations[skipConfigAnnotation] == "true" {
+			return true
+		}
 	}
+	return false
 }
 
-func init() {
-	// workaround for bootstrap config flag & unsupported flag issue
-	var dbqConfigFile string
-	rootCmd.PersistentFlags().StringVar(&dbqConfigFile, "config", "", "config file (default is $HOME/.dbq.yaml or ./dbq.yaml)")
-	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enables verbose logging")
+// printEnvironmentHeader reports the active environment on stderr, so it precedes the output of every command
+// without breaking machine-readable output on stdout
+func printEnvironmentHeader(app internal.DbqCliApp) {
+	if environment := app.GetEnvironment(); environment != "" {
+		fmt.Fprintf(os.Stderr, "dbqctl: environment '%s'\n", environment)
+	}
+}
+
+// withTimeout bounds the context by the given timeout, or by the global --timeout if the given one is zero
+func withTimeout(ctx context.Context, opTimeout time.Duration) (context.Context, context.CancelFunc) {
+	if opTimeout <= 0 {
+		opTimeout = timeout
+	}
+	if opTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, opTimeout)
 }
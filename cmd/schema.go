@@ -0,0 +1,93 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/DataBridgeTech/dbqctl/internal"
+	"github.com/spf13/cobra"
+)
+
+func NewSchemaCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Exports JSON Schemas of dbq config and checks files",
+		Annotations: map[string]string{
+			skipConfigAnnotation: "true",
+		},
+	}
+
+	cmd.AddCommand(newSchemaExportCommand())
+
+	return cmd
+}
+
+func newSchemaExportCommand() *cobra.Command {
+	var outputDir string
+
+	cmd := &cobra.Command{
+		Use:   "export [dbq|checks]",
+		Short: "Prints the JSON Schema of dbq config or checks file",
+		Long: `The 'export' command prints the JSON Schema of dbq config ('dbq') or checks file ('checks') to stdout,
+or writes both as dbq.schema.json and checks.schema.json to the directory given with --output-dir.
+
+The schemas enable autocompletion and validation in editors, e.g. with the YAML language server:
+
+  # yaml-language-server: $schema=./schemas/checks.schema.json
+`,
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: internal.SchemaNames,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names := internal.SchemaNames
+			if len(args) > 0 {
+				names = args
+			} else if outputDir == "" {
+				return fmt.Errorf("schema name is required (one of: dbq, checks) unless --output-dir is given")
+			}
+
+			if outputDir == "" {
+				schema, err := internal.GetJsonSchema(names[0])
+				if err != nil {
+					return err
+				}
+				_, err = os.Stdout.Write(schema)
+				return err
+			}
+
+			if err := os.MkdirAll(outputDir, 0755); err != nil {
+				return err
+			}
+			for _, name := range names {
+				schema, err := internal.GetJsonSchema(name)
+				if err != nil {
+					return err
+				}
+				path := filepath.Join(outputDir, name+".schema.json")
+				if err := os.WriteFile(path, schema, 0644); err != nil {
+					return err
+				}
+				fmt.Printf("JSON Schema has been written to %s\n", path)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputDir, "output-dir", "o", "", "write the schemas to the directory instead of stdout")
+
+	return cmd
+}
@@ -0,0 +1,134 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/DataBridgeTech/dbqctl/internal"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func NewSuggestCommand(app internal.DbqCliApp) *cobra.Command {
+	var dataSource string
+	var dataSets []string
+	var outputFile string
+	var opts internal.SuggestOptions
+	var maxConcurrent int
+
+	cmd := &cobra.Command{
+		Use:   "suggest",
+		Short: "Generates a starter checks file from dataset profiling results",
+		Long: `The 'suggest' command profiles the given datasets and generates a checks file which can be used with 'dbqctl check'.
+For every dataset it suggests an 'expect_columns_ordered' schema check, 'row_count' bounds, 'not_null' for columns without nulls,
+'uniqueness' for columns which look like keys and 'min'/'max'/'avg' ranges for numeric columns, widened by the configured slack.
+
+Suggested checks reflect the data at the moment of profiling, review them before use.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ds := app.FindDataSourceById(dataSource)
+			if ds == nil {
+				return fmt.Errorf("specified data source not found in dbq configuration: %s", dataSource)
+			}
+
+			dataSetsToProfile := dataSets
+			if len(dataSetsToProfile) == 0 {
+				dataSetsToProfile = ds.Datasets
+			}
+			if len(dataSetsToProfile) == 0 {
+				return fmt.Errorf("no datasets to profile, specify them with --dataset or import them with 'dbqctl import'")
+			}
+
+			rules := &yaml.Node{Kind: yaml.SequenceNode}
+			for _, curDataSet := range dataSetsToProfile {
+				if cmd.Context().Err() != nil {
+					break
+				}
+
+				fmt.Fprintf(os.Stderr, "Profiling '%s' (using %d jobs) , this may take some time...\n", curDataSet, maxConcurrent)
+				ctx, cancel := withTimeout(cmd.Context(), 0)
+				metrics, err := app.ProfileDataset(ctx, dataSource, curDataSet, false, maxConcurrent)
+				cancel()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to profile %s: %s\n", curDataSet, err)
+					continue
+				}
+
+				rules.Content = append(rules.Content, internal.SuggestChecksRule(dataSource, curDataSet, metrics, opts))
+			}
+
+			// nothing is written for an interrupted or failed run, so an existing --output-file is kept
+			if err := cmd.Context().Err(); err != nil {
+				return fmt.Errorf("suggest was interrupted, no checks written: %w", err)
+			}
+			if len(rules.Content) == 0 {
+				cmd.SilenceUsage = true
+				return &ExitCodeError{Code: ExitCodeConfigError,
+					Err: fmt.Errorf("no checks suggested, none of %d dataset(s) could be profiled", len(dataSetsToProfile))}
+			}
+
+			doc := &yaml.Node{
+				Kind: yaml.MappingNode,
+				HeadComment: fmt.Sprintf("Generated by 'dbqctl suggest' from profiling results of '%s' on %s.\nReview the suggested checks and thresholds before use.",
+					dataSource, time.Now().Format(time.DateOnly)),
+				Content: []*yaml.Node{
+					{Kind: yaml.ScalarNode, Value: "version"}, {Kind: yaml.ScalarNode, Value: "1", Style: yaml.DoubleQuotedStyle},
+					{Kind: yaml.ScalarNode, Value: "rules"}, rules,
+				},
+			}
+
+			out := io.Writer(os.Stdout)
+			if outputFile != "" {
+				file, err := os.Create(outputFile)
+				if err != nil {
+					return fmt.Errorf("failed to create output file: %w", err)
+				}
+				defer file.Close()
+				out = file
+			}
+
+			encoder := yaml.NewEncoder(out)
+			encoder.SetIndent(2)
+			if err := encoder.Encode(doc); err != nil {
+				return fmt.Errorf("failed to write suggested checks: %w", err)
+			}
+			if err := encoder.Close(); err != nil {
+				return err
+			}
+
+			if outputFile != "" {
+				fmt.Fprintf(os.Stderr, "Suggested checks for %d datasets saved to %s\n", len(rules.Content), outputFile)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&dataSource, "datasource", "d", "", "datasource in which datasets will be profiled")
+	_ = cmd.MarkFlagRequired("datasource")
+
+	cmd.Flags().StringSliceVarP(&dataSets, "dataset", "s", nil, "dataset within specified data source, can be repeated (default: all datasets of the data source)")
+	cmd.Flags().StringVar(&outputFile, "output-file", "", "write suggested checks to the file instead of stdout")
+	cmd.Flags().Float64Var(&opts.StatsSlackPct, "slack", 10, "widen min/max/avg bounds by this percent of the profiled value")
+	cmd.Flags().Float64Var(&opts.RowCountSlackPct, "row-count-slack", 50, "widen row count bounds by this percent of the profiled row count")
+	cmd.Flags().IntVarP(&maxConcurrent, "jobs", "j", runtime.NumCPU(), "set the maximum number of jobs to execute against the datasource during profiling. By default, this is equal to the number of CPUs on the host machine.")
+
+	return cmd
+}
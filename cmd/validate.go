@@ -0,0 +1,82 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/DataBridgeTech/dbqctl/internal"
+	"github.com/spf13/cobra"
+)
+
+func NewValidateCommand(app internal.DbqCliApp) *cobra.Command {
+	var checksFiles []string
+
+	cmd := &cobra.Command{
+		Use:     "validate [checks-file...]",
+		Aliases: []string{"lint"},
+		Short:   "Validates checks files without connecting to any data source",
+		Long: `The 'validate' command statically validates checks files: it parses every rule, makes sure referenced data sources exist in dbq configuration,
+verifies the dataset syntax and every check expression, and reports unknown keys, unknown 'on_fail' values, duplicate checks and empty 'checks' lists.
+Unknown keys are reported as warnings with --lenient.
+
+Every problem is reported as 'file:line:column: severity: message', the command exits with code 1 if any error is found,
+which makes it suitable for pre-commit hooks.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files := append(checksFiles, args...)
+			if len(files) == 0 {
+				return fmt.Errorf("no checks files specified, use --checks or pass files as arguments")
+			}
+
+			dataSourceExists := func(id string) bool {
+				return app.FindDataSourceById(id) != nil
+			}
+
+			errorsCount, warningsCount := 0, 0
+			for _, file := range files {
+				issues, err := internal.ValidateChecksFile(file, dataSourceExists, app.IsLenient())
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s: error: %s\n", file, err)
+					errorsCount += 1
+					continue
+				}
+
+				for _, issue := range issues {
+					fmt.Println(issue.String())
+					if issue.Severity == internal.IssueSeverityError {
+						errorsCount += 1
+					} else {
+						warningsCount += 1
+					}
+				}
+			}
+
+			fmt.Printf("validated %d file(s): %d error(s), %d warning(s)\n", len(files), errorsCount, warningsCount)
+			if errorsCount > 0 {
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+				return &ExitCodeError{Code: 1}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVarP(&checksFiles, "checks", "c", nil, "path to data quality checks file, can be repeated")
+
+	return cmd
+}
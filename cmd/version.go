@@ -29,6 +29,9 @@ func NewVersionCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "version",
 		Short: "Prints dbqctl and core lib version",
+		Annotations: map[string]string{
+			skipConfigAnnotation: "true",
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			fmt.Printf("DataBridge Quality CLI: %s\n", DbqCtlVersion)
 			fmt.Printf("DataBridge dbqcore lib version: %s\n", dbq.GetDbqCoreLibVersion())
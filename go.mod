@@ -4,9 +4,12 @@ go 1.24.5
 
 require (
 	github.com/DataBridgeTech/dbqcore v0.5.2
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/spf13/cobra v1.9.1
 	github.com/spf13/pflag v1.0.7
 	github.com/spf13/viper v1.20.1
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/term v0.34.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -0,0 +1,174 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	AnomalyMethodStddev        = "stddev"
+	AnomalyMethodPercentChange = "percent_change"
+
+	defaultAnomalyStddevThreshold  = 3.0
+	defaultAnomalyPercentThreshold = 20.0
+	defaultAnomalyWindow           = 30
+	defaultAnomalyMinHistory       = 5
+)
+
+// AnomalyConfig enables comparison of the check value against its previous values from the results history
+type AnomalyConfig struct {
+	// Method is either 'stddev' (value deviates from the window mean by more than Threshold standard deviations)
+	// or 'percent_change' (value differs from the window mean by more than Threshold percent)
+	Method     string  `yaml:"method"`
+	Threshold  float64 `yaml:"threshold"`
+	Window     int     `yaml:"window"`
+	MinHistory int     `yaml:"min_history"`
+}
+
+// AnomalyResult describes the computed expected range of the check value
+type AnomalyResult struct {
+	Method      string  `json:"method"`
+	Threshold   float64 `json:"threshold"`
+	HistorySize int     `json:"history_size"`
+	Mean        float64 `json:"mean"`
+	Stddev      float64 `json:"stddev"`
+	ExpectedMin float64 `json:"expected_min"`
+	ExpectedMax float64 `json:"expected_max"`
+	Value       float64 `json:"value"`
+	Detected    bool    `json:"detected"`
+	// Skipped explains why the value wasn't evaluated, e.g. not enough history yet
+	Skipped string `json:"skipped,omitempty"`
+}
+
+func (c *AnomalyConfig) validate() error {
+	c.Method = strings.ToLower(strGetOrDefault(c.Method, AnomalyMethodStddev))
+	switch c.Method {
+	case AnomalyMethodStddev:
+		if c.Threshold == 0 {
+			c.Threshold = defaultAnomalyStddevThreshold
+		}
+	case AnomalyMethodPercentChange:
+		if c.Threshold == 0 {
+			c.Threshold = defaultAnomalyPercentThreshold
+		}
+	default:
+		return fmt.Errorf("unsupported anomaly method '%s' (expected %s or %s)", c.Method, AnomalyMethodStddev, AnomalyMethodPercentChange)
+	}
+
+	if c.Threshold < 0 {
+		return fmt.Errorf("anomaly threshold must be positive: %v", c.Threshold)
+	}
+	if c.Window == 0 {
+		c.Window = defaultAnomalyWindow
+	}
+	if c.MinHistory == 0 {
+		c.MinHistory = min(defaultAnomalyMinHistory, c.Window)
+	}
+	if c.Window < 2 || c.MinHistory < 2 || c.MinHistory > c.Window {
+		return fmt.Errorf("anomaly window (%d) and min_history (%d) must be at least 2 and min_history can't exceed window", c.Window, c.MinHistory)
+	}
+
+	return nil
+}
+
+// EvaluateAnomaly checks the value against previous values of the same check, oldest first.
+// Values which are not numeric (e.g. failed queries) are ignored
+func EvaluateAnomaly(cfg *AnomalyConfig, value string, history []string) *AnomalyResult {
+	result := &AnomalyResult{
+		Method:    cfg.Method,
+		Threshold: cfg.Threshold,
+	}
+
+	current, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		result.Skipped = fmt.Sprintf("value '%s' is not numeric", value)
+		return result
+	}
+	result.Value = current
+
+	var values []float64
+	for _, historyValue := range history {
+		if v, err := strconv.ParseFloat(strings.TrimSpace(historyValue), 64); err == nil {
+			values = append(values, v)
+		}
+	}
+	if len(values) > cfg.Window {
+		values = values[len(values)-cfg.Window:]
+	}
+
+	result.HistorySize = len(values)
+	if len(values) < cfg.MinHistory {
+		result.Skipped = fmt.Sprintf("not enough history (%d of %d values)", len(values), cfg.MinHistory)
+		return result
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	result.Mean = sum / float64(len(values))
+
+	var squares float64
+	for _, v := range values {
+		squares += (v - result.Mean) * (v - result.Mean)
+	}
+	result.Stddev = math.Sqrt(squares / float64(len(values)-1))
+
+	switch cfg.Method {
+	case AnomalyMethodPercentChange:
+		delta := math.Abs(result.Mean) * cfg.Threshold / 100
+		result.ExpectedMin = result.Mean - delta
+		result.ExpectedMax = result.Mean + delta
+	default:
+		result.ExpectedMin = result.Mean - cfg.Threshold*result.Stddev
+		result.ExpectedMax = result.Mean + cfg.Threshold*result.Stddev
+	}
+
+	result.Detected = current < result.ExpectedMin || current > result.ExpectedMax
+	return result
+}
+
+// Describe returns a human-readable explanation of the evaluation
+func (r *AnomalyResult) Describe() string {
+	if r.Skipped != "" {
+		return "not evaluated, " + r.Skipped
+	}
+
+	state := "within"
+	if r.Detected {
+		state = "outside"
+	}
+
+	var thresholdDesc string
+	if r.Method == AnomalyMethodPercentChange {
+		thresholdDesc = fmt.Sprintf("±%g%% of mean", r.Threshold)
+	} else {
+		thresholdDesc = fmt.Sprintf("mean ± %g stddev", r.Threshold)
+	}
+
+	return fmt.Sprintf("value %.6g is %s expected range [%.6g, %.6g] (%s; mean %.6g, stddev %.6g over %d runs)",
+		r.Value, state, r.ExpectedMin, r.ExpectedMax, thresholdDesc, r.Mean, r.Stddev, r.HistorySize)
+}
+
+func strGetOrDefault(original string, defaultVal string) string {
+	if original == "" {
+		return defaultVal
+	}
+	return original
+}
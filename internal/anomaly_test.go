@@ -0,0 +1,211 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateAnomaly(t *testing.T) {
+	stddev := &AnomalyConfig{Method: AnomalyMethodStddev, Threshold: 2, Window: 5, MinHistory: 3}
+	percent := &AnomalyConfig{Method: AnomalyMethodPercentChange, Threshold: 10, Window: 5, MinHistory: 3}
+
+	tests := []struct {
+		name         string
+		cfg          *AnomalyConfig
+		value        string
+		history      []string
+		wantSkipped  string
+		wantDetected bool
+		wantMin      float64
+		wantMax      float64
+		wantHistory  int
+	}{
+		{
+			name:        "value not numeric",
+			cfg:         stddev,
+			value:       "n/a",
+			history:     []string{"1", "2", "3"},
+			wantSkipped: "value 'n/a' is not numeric",
+		},
+		{
+			name:        "no history",
+			cfg:         stddev,
+			value:       "10",
+			wantSkipped: "not enough history (0 of 3 values)",
+		},
+		{
+			name:        "short history after dropping non-numeric values",
+			cfg:         stddev,
+			value:       "10",
+			history:     []string{"10", "", "error", "11"},
+			wantSkipped: "not enough history (2 of 3 values)",
+			wantHistory: 2,
+		},
+		{
+			name:        "stddev within range",
+			cfg:         stddev,
+			value:       "12",
+			history:     []string{"8", "10", "12"},
+			wantMin:     6,
+			wantMax:     14,
+			wantHistory: 3,
+		},
+		{
+			name:         "stddev outside range",
+			cfg:          stddev,
+			value:        "15",
+			history:      []string{"8", "10", "12"},
+			wantDetected: true,
+			wantMin:      6,
+			wantMax:      14,
+			wantHistory:  3,
+		},
+		{
+			name:         "stddev window keeps the most recent values",
+			cfg:          stddev,
+			value:        "12",
+			history:      []string{"1000", "-1000", "8", "10", "12", "8", "12"},
+			wantMin:      10 - 2*2,
+			wantMax:      10 + 2*2,
+			wantHistory:  5,
+			wantDetected: false,
+		},
+		{
+			name:         "stddev constant history detects any change",
+			cfg:          stddev,
+			value:        "101",
+			history:      []string{"100", "100", "100"},
+			wantDetected: true,
+			wantMin:      100,
+			wantMax:      100,
+			wantHistory:  3,
+		},
+		{
+			name:        "percent change within range",
+			cfg:         percent,
+			value:       "105",
+			history:     []string{"100", "100", "100"},
+			wantMin:     90,
+			wantMax:     110,
+			wantHistory: 3,
+		},
+		{
+			name:         "percent change outside range",
+			cfg:          percent,
+			value:        "89",
+			history:      []string{"90", "100", "110"},
+			wantDetected: true,
+			wantMin:      90,
+			wantMax:      110,
+			wantHistory:  3,
+		},
+		{
+			name:        "percent change negative baseline",
+			cfg:         percent,
+			value:       "-95",
+			history:     []string{"-100", "-100", "-100"},
+			wantMin:     -110,
+			wantMax:     -90,
+			wantHistory: 3,
+		},
+		{
+			name:        "percent change zero baseline and zero value",
+			cfg:         percent,
+			value:       "0",
+			history:     []string{"0", "0", "0"},
+			wantHistory: 3,
+		},
+		{
+			name:         "percent change zero baseline and non-zero value",
+			cfg:          percent,
+			value:        "1",
+			history:      []string{"0", "0", "0"},
+			wantDetected: true,
+			wantHistory:  3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := EvaluateAnomaly(tt.cfg, tt.value, tt.history)
+			if result.Skipped != tt.wantSkipped {
+				t.Fatalf("Skipped = %q, want %q", result.Skipped, tt.wantSkipped)
+			}
+			if result.HistorySize != tt.wantHistory {
+				t.Errorf("HistorySize = %d, want %d", result.HistorySize, tt.wantHistory)
+			}
+			if tt.wantSkipped != "" {
+				if result.Detected {
+					t.Error("skipped evaluation detected an anomaly")
+				}
+				if !strings.HasPrefix(result.Describe(), "not evaluated, ") {
+					t.Errorf("Describe() = %q", result.Describe())
+				}
+				return
+			}
+			if result.Detected != tt.wantDetected {
+				t.Errorf("Detected = %t, want %t (%s)", result.Detected, tt.wantDetected, result.Describe())
+			}
+			if math.Abs(result.ExpectedMin-tt.wantMin) > 1e-9 || math.Abs(result.ExpectedMax-tt.wantMax) > 1e-9 {
+				t.Errorf("expected range = [%g, %g], want [%g, %g]", result.ExpectedMin, result.ExpectedMax, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestAnomalyConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     AnomalyConfig
+		want    AnomalyConfig
+		wantErr bool
+	}{
+		{
+			name: "stddev defaults",
+			cfg:  AnomalyConfig{},
+			want: AnomalyConfig{Method: AnomalyMethodStddev, Threshold: 3, Window: 30, MinHistory: 5},
+		},
+		{
+			name: "percent change defaults",
+			cfg:  AnomalyConfig{Method: "Percent_Change"},
+			want: AnomalyConfig{Method: AnomalyMethodPercentChange, Threshold: 20, Window: 30, MinHistory: 5},
+		},
+		{
+			name: "min history capped by a short window",
+			cfg:  AnomalyConfig{Window: 3},
+			want: AnomalyConfig{Method: AnomalyMethodStddev, Threshold: 3, Window: 3, MinHistory: 3},
+		},
+		{name: "unknown method", cfg: AnomalyConfig{Method: "zscore"}, wantErr: true},
+		{name: "negative threshold", cfg: AnomalyConfig{Threshold: -1}, wantErr: true},
+		{name: "window too short", cfg: AnomalyConfig{Window: 1}, wantErr: true},
+		{name: "min history exceeds window", cfg: AnomalyConfig{Window: 5, MinHistory: 6}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			err := cfg.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && cfg != tt.want {
+				t.Errorf("validate() = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+}
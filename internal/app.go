@@ -16,105 +16,295 @@ package internal
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/DataBridgeTech/dbqcore"
-	"github.com/DataBridgeTech/dbqcore/dbq"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"gopkg.in/yaml.v3"
 )
 
 type DbqCliApp interface {
-	PingDataSource(srcId string) (string, error)
-	ImportDatasets(srcId string, filter string) ([]string, error)
-	ProfileDataset(srcId string, dataset string, sample bool, maxConcurrent int) (*dbqcore.TableMetrics, error)
-	RunCheck(check *dbqcore.DataQualityCheck, dataSource *dbqcore.DataSource, dataset string, defaultWhere string) *dbqcore.ValidationResult
+	PingDataSource(ctx context.Context, srcId string) (string, error)
+	ImportDatasets(ctx context.Context, srcId string, filter string) ([]string, error)
+	ProfileDataset(ctx context.Context, srcId string, dataset string, sample bool, maxConcurrent int) (*dbqcore.TableMetrics, error)
+	RunCheck(ctx context.Context, check *dbqcore.DataQualityCheck, dataSource *dbqcore.DataSource, dataset string, defaultWhere string) *dbqcore.ValidationResult
+	RenderCheckQuery(ctx context.Context, check *dbqcore.DataQualityCheck, dataSource *dbqcore.DataSource, dataset string, defaultWhere string) (string, error)
+	ExplainCheck(ctx context.Context, check *dbqcore.DataQualityCheck, dataSource *dbqcore.DataSource, dataset string, defaultWhere string) (query string, plan string, err error)
+	SampleFailedRows(ctx context.Context, check *dbqcore.DataQualityCheck, dataSource *dbqcore.DataSource, dataset string, defaultWhere string, limit int) (*FailedRows, error)
+	ListCheckGroups(ctx context.Context, dataSource *dbqcore.DataSource, dataset string, defaultWhere string, groupBy []string, limit int) ([]CheckGroup, error)
+	Reconcile(ctx context.Context, opts ReconcileOptions) (*ReconcileResult, error)
 	GetDbqConfig() *dbqcore.DbqConfig
+	PrepareDbqConfigUpdates() ([]*ConfigFileUpdate, error)
 	SaveDbqConfig() error
+	GetConfigFiles() []string
+	GetConfigError() error
+	AddDataSource(ds dbqcore.DataSource) error
+	RemoveDataSource(srcId string) bool
+	GetEnvironment() string
+	IsLenient() bool
+	RenderConfig(resolved bool) ([]byte, error)
 	SetLogLevel(level slog.Level)
 	FindDataSourceById(srcId string) *dbqcore.DataSource
+	ResolveDataSource(srcId string) error
+	GetHistoryStore() (*HistoryStore, error)
+	Close() error
+}
+
+// CliConfig holds dbq.yaml settings owned by dbqctl rather than by dbqcore
+type CliConfig struct {
+	History    HistoryConfig    `mapstructure:"history" yaml:"history,omitempty"`
+	FailedRows FailedRowsConfig `mapstructure:"failed_rows" yaml:"failed_rows,omitempty"`
+}
+
+type HistoryConfig struct {
+	// Enabled turns on persisting of check results, history is enabled when not set
+	Enabled *bool `mapstructure:"enabled" yaml:"enabled,omitempty"`
+	// Path of the history store file, relative paths are resolved against the directory of the config file
+	// setting it (the last one of merged config files)
+	Path string `mapstructure:"path" yaml:"path,omitempty"`
+}
+
+// AppOptions are the global settings needed before the config is loaded
+type AppOptions struct {
+	// ConfigFiles are merged in the given order, the config file is discovered if empty (see FindConfigFiles)
+	ConfigFiles []string
+	// Environment selects the environment overrides, $DBQ_ENV is used if empty
+	Environment string
+	// Lenient reports unknown keys of config and checks files as warnings instead of errors
+	Lenient bool
+	Log     LogOptions
 }
 
 type DbqAppImpl struct {
-	dbqConfigPath string
-	dbqConfig     *dbqcore.DbqConfig
-	logLevel      slog.Level
-	logger        *slog.Logger
-	poolSize      int
+	dbqConfigPaths []string
+	environment    string
+	lenient        bool
+	configErr      error
+	dbqConfig      *dbqcore.DbqConfig
+	logLevel       *slog.LevelVar
+	logger         *slog.Logger
+	logCloser      io.Closer
+	poolSize       int
+	connections    *ConnectionRegistry
+	cliConfig      *CliConfig
+	interpolator   *ConfigInterpolator
+	resolveMu      sync.Mutex
+	historyMu      sync.Mutex
+	history        *HistoryStore
 }
 
-func NewDbqCliApp(dbqConfigPath string) DbqCliApp {
-	dbqConfig, dbqConfigUsedPath := initConfig(dbqConfigPath)
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
-	return &DbqAppImpl{
-		dbqConfigPath: dbqConfigUsedPath,
-		dbqConfig:     dbqConfig,
-		logLevel:      slog.LevelError,
-		logger:        logger,           // todo: fix logger init
-		poolSize:      runtime.NumCPU(), // todo: make configurable
+// NewDbqCliApp loads and merges the given config files, or the discovered one if none is given (see FindConfigFiles),
+// and applies overrides of the given environment, $DBQ_ENV is used if the environment is empty
+func NewDbqCliApp(opts AppOptions) DbqCliApp {
+	environment := opts.Environment
+	if environment == "" {
+		environment = os.Getenv(EnvironmentEnvVar)
+	}
+	dbqConfig, cliConfig, interpolator, dbqConfigUsedPaths, configErr := initConfig(opts.ConfigFiles, environment, opts.Lenient)
+	logLevel := new(slog.LevelVar)
+	logger, logCloser, err := newLogger(opts.Log, logLevel, interpolator)
+	cobra.CheckErr(err)
+	// logs of the cli itself (e.g. slog.Debug in commands) go through the same handler
+	slog.SetDefault(logger)
+	poolSize := runtime.NumCPU() // todo: make configurable
+	app := &DbqAppImpl{
+		dbqConfigPaths: dbqConfigUsedPaths,
+		environment:    environment,
+		lenient:        opts.Lenient,
+		configErr:      configErr,
+		dbqConfig:      dbqConfig,
+		logLevel:       logLevel,
+		logger:         logger,
+		logCloser:      logCloser,
+		poolSize:       poolSize,
+		cliConfig:      cliConfig,
+		interpolator:   interpolator,
 	}
+	app.connections = NewConnectionRegistry(poolSize, logger, app.resolveDataSource)
+	return app
 }
 
-func (app *DbqAppImpl) PingDataSource(srcId string) (string, error) {
-	var dataSource = app.FindDataSourceById(srcId)
-
-	cnn, err := dbq.NewDbqConnector(dataSource, app.poolSize, app.logger)
+func (app *DbqAppImpl) PingDataSource(ctx context.Context, srcId string) (string, error) {
+	ctx = WithLogAttrs(ctx, slog.String("datasource", srcId))
+	cnn, err := app.connections.Connector(ctx, app.FindDataSourceById(srcId))
 	if err != nil {
-		return "", err
+		return "", app.redactErr(err)
 	}
 
-	info, err := cnn.Ping(context.Background()) // todo: ctx propagation
+	info, err := cnn.Ping(ctx)
 	if err != nil {
-		return "", err
+		return "", app.redactErr(err)
 	}
 
 	return info, nil
 }
 
-func (app *DbqAppImpl) ImportDatasets(srcId string, filter string) ([]string, error) {
-	var dataSource = app.FindDataSourceById(srcId)
-
-	cnn, err := dbq.NewDbqConnector(dataSource, app.poolSize, app.logger)
+func (app *DbqAppImpl) ImportDatasets(ctx context.Context, srcId string, filter string) ([]string, error) {
+	ctx = WithLogAttrs(ctx, slog.String("datasource", srcId))
+	cnn, err := app.connections.Connector(ctx, app.FindDataSourceById(srcId))
 	if err != nil {
-		return []string{}, err
+		return []string{}, app.redactErr(err)
 	}
 
-	return cnn.ImportDatasets(context.Background(), filter) // todo: ctx propagation
+	datasets, err := cnn.ImportDatasets(ctx, filter)
+	return datasets, app.redactErr(err)
 }
 
-func (app *DbqAppImpl) ProfileDataset(srcId string, dataset string, sample bool, maxConcurrent int) (*dbqcore.TableMetrics, error) {
-	var dataSource = app.FindDataSourceById(srcId)
-
-	dbqProfiler, err := dbq.NewDbqProfiler(dataSource, app.poolSize, app.logger)
+func (app *DbqAppImpl) ProfileDataset(ctx context.Context, srcId string, dataset string, sample bool, maxConcurrent int) (*dbqcore.TableMetrics, error) {
+	ctx = WithLogAttrs(ctx, slog.String("datasource", srcId), slog.String("dataset", dataset))
+	dbqProfiler, err := app.connections.Profiler(ctx, app.FindDataSourceById(srcId))
 	if err != nil {
-		return nil, err
+		return nil, app.redactErr(err)
 	}
 
-	return dbqProfiler.ProfileDataset(context.Background(), dataset, sample, maxConcurrent, true) // todo: ctx propagation
+	metrics, err := dbqProfiler.ProfileDataset(ctx, dataset, sample, maxConcurrent, true)
+	return metrics, app.redactErr(err)
 }
 
 func (app *DbqAppImpl) GetDbqConfig() *dbqcore.DbqConfig {
 	return app.dbqConfig
 }
 
+// PrepareDbqConfigUpdates renders the in-memory datasets lists into the config files without writing them.
+// Only the datasets lists are touched, so comments, formatting and unresolved placeholders (e.g. '${PG_PASSWORD}') are kept
+func (app *DbqAppImpl) PrepareDbqConfigUpdates() ([]*ConfigFileUpdate, error) {
+	return prepareConfigUpdates(app.dbqConfigPaths, app.dbqConfig.DataSources, app.interpolator.RawValues)
+}
+
+// SaveDbqConfig writes the changed datasets lists back to the config files
 func (app *DbqAppImpl) SaveDbqConfig() error {
-	updatedYaml, err := yaml.Marshal(app.dbqConfig)
+	updates, err := app.PrepareDbqConfigUpdates()
 	if err != nil {
 		return err
 	}
+	for _, update := range updates {
+		if err := update.Write(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetConfigFiles returns the loaded config files in merge order
+func (app *DbqAppImpl) GetConfigFiles() []string {
+	return app.dbqConfigPaths
+}
+
+// GetConfigError returns the reason why no config was loaded (e.g. the config file doesn't exist yet)
+func (app *DbqAppImpl) GetConfigError() error {
+	return app.configErr
+}
+
+// AddDataSource adds a new data source to the in-memory config. Its connection settings are interpolated like the
+// ones loaded from the config file, so SaveDbqConfig writes them in their raw form (e.g. '${PG_PASSWORD}')
+func (app *DbqAppImpl) AddDataSource(ds dbqcore.DataSource) error {
+	if ds.ID == "" {
+		return errors.New("data source id is required")
+	}
+	if app.FindDataSourceById(ds.ID) != nil {
+		return fmt.Errorf("data source '%s' already exists", ds.ID)
+	}
+
+	path := fmt.Sprintf("datasources.%d.configuration", len(app.dbqConfig.DataSources))
+	for key, value := range map[string]*string{
+		"host":     &ds.Configuration.Host,
+		"username": &ds.Configuration.Username,
+		"password": &ds.Configuration.Password,
+		"database": &ds.Configuration.Database,
+	} {
+		resolved, err := app.interpolator.interpolateValue(joinPath(path, key), *value)
+		if err != nil {
+			return err
+		}
+		*value = resolved.(string)
+	}
+
+	app.dbqConfig.DataSources = append(app.dbqConfig.DataSources, ds)
+	return nil
+}
+
+// RemoveDataSource removes the data source from the in-memory config, returns false if it doesn't exist
+func (app *DbqAppImpl) RemoveDataSource(srcId string) bool {
+	for i := range app.dbqConfig.DataSources {
+		if app.dbqConfig.DataSources[i].ID == srcId {
+			app.dbqConfig.DataSources = slices.Delete(app.dbqConfig.DataSources, i, i+1)
+			app.interpolator.removeListItem("datasources", i)
+			app.interpolator.forgetDataSourceSettings(srcId)
+			return true
+		}
+	}
+	return false
+}
+
+// GetEnvironment returns the active environment, empty if none is selected
+func (app *DbqAppImpl) GetEnvironment() string {
+	return app.environment
+}
+
+// IsLenient reports whether unknown keys of config and checks files are only warned about
+func (app *DbqAppImpl) IsLenient() bool {
+	return app.lenient
+}
+
+// RenderConfig renders the effective configuration with secrets masked, see RenderConfig
+func (app *DbqAppImpl) RenderConfig(resolved bool) ([]byte, error) {
+	if resolved {
+		for i := range app.dbqConfig.DataSources {
+			if err := app.resolveDataSource(&app.dbqConfig.DataSources[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return RenderConfig(app.dbqConfig, app.cliConfig, app.interpolator, resolved)
+}
+
+// ResolveDataSource resolves placeholders and secret references in the connection settings of the data source,
+// which is done once it's used for the first time. Errors are returned as DataSourceConfigError
+func (app *DbqAppImpl) ResolveDataSource(srcId string) error {
+	dataSource := app.FindDataSourceById(srcId)
+	if dataSource == nil {
+		return fmt.Errorf("data source '%s' not found in dbq configuration", srcId)
+	}
+	return app.resolveDataSource(dataSource)
+}
 
-	err = os.WriteFile(app.dbqConfigPath, updatedYaml, 0644)
+func (app *DbqAppImpl) resolveDataSource(dataSource *dbqcore.DataSource) error {
+	app.resolveMu.Lock()
+	defer app.resolveMu.Unlock()
+
+	index := slices.IndexFunc(app.dbqConfig.DataSources, func(ds dbqcore.DataSource) bool { return ds.ID == dataSource.ID })
+	settings, err := app.interpolator.resolveDataSourceSettings(index, dataSource.ID)
 	if err != nil {
-		return err
+		return &DataSourceConfigError{DataSource: dataSource.ID, Err: err}
+	}
+	if len(settings) == 0 {
+		return nil
 	}
 
+	decoder := viper.New()
+	if err := decoder.MergeConfigMap(settings); err != nil {
+		return &DataSourceConfigError{DataSource: dataSource.ID, Err: err}
+	}
+	if err := decoder.Unmarshal(&dataSource.Configuration); err != nil {
+		return &DataSourceConfigError{DataSource: dataSource.ID, Err: errors.New(app.interpolator.Redact(err.Error()))}
+	}
+	app.interpolator.forgetDataSourceSettings(dataSource.ID)
 	return nil
 }
 
+// FindDataSourceById returns the data source with overrides of the active environment applied
 func (app *DbqAppImpl) FindDataSourceById(srcId string) *dbqcore.DataSource {
 	for i := range app.dbqConfig.DataSources {
 		if app.dbqConfig.DataSources[i].ID == srcId {
@@ -124,42 +314,292 @@ func (app *DbqAppImpl) FindDataSourceById(srcId string) *dbqcore.DataSource {
 	return nil
 }
 
-func (app *DbqAppImpl) RunCheck(check *dbqcore.DataQualityCheck, dataSource *dbqcore.DataSource, dataset string, defaultWhere string) *dbqcore.ValidationResult {
-	validator := dbqcore.NewDbqDataValidator(app.logger)
-	adapter, err := dbq.NewDbqAdapter(dataSource, app.poolSize, app.logger)
+func (app *DbqAppImpl) RunCheck(ctx context.Context, check *dbqcore.DataQualityCheck, dataSource *dbqcore.DataSource, dataset string, defaultWhere string) *dbqcore.ValidationResult {
+	ctx = WithLogAttrs(ctx,
+		slog.String("datasource", dataSource.ID),
+		slog.String("dataset", dataset),
+		slog.String("check", check.Expression))
+	validator := dbqcore.NewDbqDataValidator(loggerFor(ctx, app.logger))
+	adapter, err := app.connections.Adapter(ctx, dataSource)
 	if err != nil {
+		return &dbqcore.ValidationResult{Error: app.interpolator.Redact(err.Error())}
+	}
+
+	if foreignKey := parseForeignKeyCheck(check.Expression); foreignKey != nil {
+		return app.runForeignKeyCheck(ctx, adapter, foreignKey, dataSource, dataset, defaultWhere)
+	}
+
+	result := validator.RunCheck(ctx, adapter, check, dataset, defaultWhere)
+	result.Error = app.interpolator.Redact(result.Error)
+	return result
+}
+
+// runForeignKeyCheck counts rows of the dataset referencing keys missing in the referenced dataset,
+// foreign_key checks are unknown to dbqcore, so the query is built and evaluated by dbqctl
+func (app *DbqAppImpl) runForeignKeyCheck(ctx context.Context, adapter dbqcore.DbqDataSourceAdapter, foreignKey *CheckExpression, dataSource *dbqcore.DataSource, dataset string, defaultWhere string) *dbqcore.ValidationResult {
+	if err := foreignKey.References.CheckDataSource(dataSource.ID); err != nil {
 		return &dbqcore.ValidationResult{Error: err.Error()}
 	}
 
-	return validator.RunCheck(context.Background(), adapter, check, dataset, defaultWhere) // todo: ctx propagation
+	query := foreignKeyQuery(foreignKey, dataset, defaultWhere)
+	app.logger.DebugContext(ctx, "Counting foreign key orphans", "query", query)
+	value, err := adapter.ExecuteQuery(ctx, query)
+	if err != nil {
+		return &dbqcore.ValidationResult{Error: app.interpolator.Redact(err.Error())}
+	}
+
+	orphans, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return &dbqcore.ValidationResult{QueryResultValue: value, Error: fmt.Sprintf("unexpected orphans count '%s'", value)}
+	}
+	return &dbqcore.ValidationResult{Pass: orphans == 0, QueryResultValue: strconv.FormatInt(orphans, 10)}
+}
+
+// RenderCheckQuery returns the SQL the check runs against the dataset in the dialect of the data source,
+// neither connection settings are resolved nor a connection is made
+func (app *DbqAppImpl) RenderCheckQuery(ctx context.Context, check *dbqcore.DataQualityCheck, dataSource *dbqcore.DataSource, dataset string, defaultWhere string) (string, error) {
+	ctx = WithLogAttrs(ctx,
+		slog.String("datasource", dataSource.ID),
+		slog.String("dataset", dataset),
+		slog.String("check", check.Expression))
+	if foreignKey := parseForeignKeyCheck(check.Expression); foreignKey != nil {
+		if err := foreignKey.References.CheckDataSource(dataSource.ID); err != nil {
+			return "", err
+		}
+		return foreignKeyQuery(foreignKey, dataset, defaultWhere), nil
+	}
+
+	// rendering needs neither credentials nor a reachable data source (e.g. check --dry-run in CI)
+	interpreter, err := app.connections.Interpreter(ctx, dataSource)
+	if err != nil {
+		return "", app.redactErr(err)
+	}
+
+	query, err := interpreter.InterpretDataQualityCheck(check, dataset, defaultWhere)
+	if err != nil {
+		return "", app.redactErr(err)
+	}
+	return renderedCheckQuery(query, dataset), nil
+}
+
+// ExplainCheck runs EXPLAIN of the check query and returns the query along with its plan
+func (app *DbqAppImpl) ExplainCheck(ctx context.Context, check *dbqcore.DataQualityCheck, dataSource *dbqcore.DataSource, dataset string, defaultWhere string) (string, string, error) {
+	ctx = WithLogAttrs(ctx,
+		slog.String("datasource", dataSource.ID),
+		slog.String("dataset", dataset),
+		slog.String("check", check.Expression))
+	query, err := app.RenderCheckQuery(ctx, check, dataSource, dataset, defaultWhere)
+	if err != nil {
+		return "", "", err
+	}
+
+	explain, err := explainStatement(dataSource.Type, query)
+	if err != nil {
+		return query, "", err
+	}
+
+	adapter, err := app.connections.Adapter(ctx, dataSource)
+	if err != nil {
+		return query, "", app.redactErr(err)
+	}
+
+	app.logger.DebugContext(ctx, "Explaining check query", "query", explain)
+	plan, err := adapter.ExecuteQuery(ctx, explain)
+	if err != nil {
+		return query, "", app.redactErr(err)
+	}
+	return query, formatQueryPlan(plan), nil
+}
+
+// SampleFailedRows fetches up to limit rows violating the check (see SupportsFailedRows),
+// values of columns matching 'failed_rows.mask_columns' of dbq config are masked
+func (app *DbqAppImpl) SampleFailedRows(ctx context.Context, check *dbqcore.DataQualityCheck, dataSource *dbqcore.DataSource, dataset string, defaultWhere string, limit int) (*FailedRows, error) {
+	ctx = WithLogAttrs(ctx,
+		slog.String("datasource", dataSource.ID),
+		slog.String("dataset", dataset),
+		slog.String("check", check.Expression))
+	adapter, err := app.connections.Adapter(ctx, dataSource)
+	if err != nil {
+		return nil, app.redactErr(err)
+	}
+
+	var tableColumns []string
+	if columnsQuery := tableColumnsQuery(dataSource.Type, check.Expression, dataset); columnsQuery != "" {
+		app.logger.DebugContext(ctx, "Fetching table columns", "query", columnsQuery)
+		value, err := adapter.ExecuteQuery(ctx, columnsQuery)
+		if err != nil {
+			return nil, app.redactErr(err)
+		}
+		if err := json.Unmarshal([]byte(value), &tableColumns); err != nil {
+			return nil, fmt.Errorf("failed to read columns of %s: %w", dataset, err)
+		}
+	}
+
+	query, err := failedRowsQuery(dataSource.Type, check.Expression, dataset, defaultWhere, limit, tableColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	app.logger.DebugContext(ctx, "Sampling failed rows", "query", query)
+	value, err := adapter.ExecuteQuery(ctx, query)
+	if err != nil {
+		return nil, app.redactErr(err)
+	}
+
+	columns, rows, err := parseJsonRows(value)
+	if err != nil {
+		return nil, err
+	}
+	failedRows := &FailedRows{Query: query, Columns: columns, Rows: rows}
+	failedRows.Mask(app.cliConfig.FailedRows.MaskColumns)
+	return failedRows, nil
+}
+
+// GetHistoryStore opens the check results history store on first use, returns nil if history is disabled
+func (app *DbqAppImpl) GetHistoryStore() (*HistoryStore, error) {
+	app.historyMu.Lock()
+	defer app.historyMu.Unlock()
+
+	historyCfg := app.cliConfig.History
+	if historyCfg.Enabled != nil && !*historyCfg.Enabled {
+		return nil, nil
+	}
+
+	if app.history == nil {
+		historyPath := historyCfg.Path
+		if historyPath == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			historyPath = filepath.Join(home, ".dbq", "history.db")
+		} else if !filepath.IsAbs(historyPath) {
+			configFile, err := definingConfigFile(app.dbqConfigPaths, "history.path")
+			if err != nil {
+				return nil, err
+			}
+			historyPath = filepath.Join(filepath.Dir(configFile), historyPath)
+		}
+
+		app.logger.Debug("Opening history store", "path", historyPath)
+		history, err := OpenHistoryStore(historyPath)
+		if err != nil {
+			return nil, err
+		}
+		app.history = history
+	}
+
+	return app.history, nil
+}
+
+func (app *DbqAppImpl) Close() error {
+	var errs []error
+	errs = append(errs, app.connections.Close())
+
+	app.historyMu.Lock()
+	if app.history != nil {
+		errs = append(errs, app.history.Close())
+		app.history = nil
+	}
+	app.historyMu.Unlock()
+
+	errs = append(errs, app.logCloser.Close())
+	return errors.Join(errs...)
 }
 
+// SetLogLevel changes the level of the logger, including loggers already passed to dbqcore
 func (app *DbqAppImpl) SetLogLevel(logLevel slog.Level) {
-	app.logLevel = logLevel
+	app.logLevel.Set(logLevel)
 }
 
-func initConfig(dbqConfigPath string) (*dbqcore.DbqConfig, string) {
-	v := viper.New()
+// initConfig loads the config, a missing config file is returned as error along with an empty config,
+// so commands not depending on the config (e.g. 'config init') can still run
+func initConfig(dbqConfigPaths []string, environment string, lenient bool) (*dbqcore.DbqConfig, *CliConfig, *ConfigInterpolator, []string, error) {
+	interpolator := NewConfigInterpolator()
+	paths, err := FindConfigFiles(dbqConfigPaths)
+	if err == nil {
+		err = checkConfigFilesExist(paths)
+	}
+	if err != nil {
+		return &dbqcore.DbqConfig{}, &CliConfig{}, interpolator, paths, err
+	}
 
-	if dbqConfigPath != "" {
-		v.SetConfigFile(dbqConfigPath)
-	} else {
-		home, err := os.UserHomeDir()
-		cobra.CheckErr(err)
-		v.AddConfigPath(home)
-		v.SetConfigType("yaml")
-		v.SetConfigName(".dbq.yaml")
+	// misspelled keys would be silently dropped by the decoding below
+	unknownKeys, err := findUnknownConfigKeys(paths)
+	if err != nil {
+		return &dbqcore.DbqConfig{}, &CliConfig{}, interpolator, paths, err
+	}
+	if len(unknownKeys) > 0 {
+		unknownErr := &UnknownKeysError{Keys: unknownKeys}
+		if !lenient {
+			return &dbqcore.DbqConfig{}, &CliConfig{}, interpolator, paths, unknownErr
+		}
+		for _, message := range unknownErr.Messages() {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", message)
+		}
 	}
 
-	v.AutomaticEnv()
-	if err := v.ReadInConfig(); err != nil {
-		cobra.CheckErr(err)
+	settings, err := loadConfigSettings(paths)
+	if err == nil {
+		err = applyEnvironment(settings, environment)
+	}
+	if err != nil {
+		return &dbqcore.DbqConfig{}, &CliConfig{}, interpolator, paths, err
+	}
+
+	// connection settings are resolved once a data source is used (see ResolveDataSource),
+	// so an unset variable of one data source doesn't break commands not connecting to it
+	interpolator.deferDataSourceSettings(settings)
+
+	// resolve ${ENV_VAR} placeholders and secret references before the settings are decoded,
+	// so non-string fields (e.g. port) can be interpolated too
+	if err := interpolator.InterpolateSettings(settings); err != nil {
+		return &dbqcore.DbqConfig{}, &CliConfig{}, interpolator, paths, err
+	}
+
+	resolved := viper.New()
+	if err := resolved.MergeConfigMap(settings); err != nil {
+		return &dbqcore.DbqConfig{}, &CliConfig{}, interpolator, paths, err
 	}
 
 	var dbqConfig dbqcore.DbqConfig
-	if err := v.Unmarshal(&dbqConfig); err != nil {
-		cobra.CheckErr(err)
+	if err := resolved.Unmarshal(&dbqConfig); err != nil {
+		return &dbqcore.DbqConfig{}, &CliConfig{}, interpolator, paths, errors.New(interpolator.Redact(err.Error()))
+	}
+
+	var cliConfig CliConfig
+	if err := resolved.Unmarshal(&cliConfig); err != nil {
+		return &dbqcore.DbqConfig{}, &CliConfig{}, interpolator, paths, errors.New(interpolator.Redact(err.Error()))
+	}
+
+	return &dbqConfig, &cliConfig, interpolator, paths, nil
+}
+
+// DataSourceConfigError is returned when the connection settings of a data source can't be resolved
+type DataSourceConfigError struct {
+	DataSource string
+	Err        error
+}
+
+func (e *DataSourceConfigError) Error() string {
+	return fmt.Sprintf("invalid configuration of data source '%s': %s", e.DataSource, e.Err)
+}
+
+func (e *DataSourceConfigError) Unwrap() error { return e.Err }
+
+// redactErr masks resolved secrets in the error message, keeping the original error in the chain
+func (app *DbqAppImpl) redactErr(err error) error {
+	if err == nil {
+		return nil
 	}
+	return &redactedError{msg: app.interpolator.Redact(err.Error()), err: err}
+}
 
-	return &dbqConfig, v.ConfigFileUsed()
+type redactedError struct {
+	msg string
+	err error
 }
+
+func (e *redactedError) Error() string { return e.msg }
+
+func (e *redactedError) Unwrap() error { return e.err }
@@ -0,0 +1,70 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DataBridgeTech/dbqcore"
+)
+
+func TestRenderCheckQueryOffline(t *testing.T) {
+	dir := writeChecksFiles(t, map[string]string{
+		"dbq.yaml": `version: "1"
+datasources:
+  - id: pg
+    type: postgresql
+    configuration:
+      host: unreachable.invalid
+      port: ${DBQ_TEST_UNSET_PORT}
+      password: file:///nonexistent/dbq-secret
+`,
+	})
+	app := NewDbqCliApp(AppOptions{ConfigFiles: []string{filepath.Join(dir, "dbq.yaml")}}).(*DbqAppImpl)
+	t.Cleanup(func() { _ = app.Close() })
+	if err := app.GetConfigError(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	pg := app.FindDataSourceById("pg")
+
+	for _, expression := range []string{"row_count > 0", "foreign_key(customer_id) references customers(id)"} {
+		query, err := app.RenderCheckQuery(ctx, &dbqcore.DataQualityCheck{Expression: expression}, pg, "public.orders", "")
+		if err != nil {
+			t.Fatalf("RenderCheckQuery(%s) = %v", expression, err)
+		}
+		if !strings.Contains(query, "public.orders") {
+			t.Errorf("RenderCheckQuery(%s) = %q, want a query of the dataset", expression, query)
+		}
+	}
+
+	// nothing was resolved or connected
+	if len(app.connections.entries) != 0 {
+		t.Errorf("connections were opened: %v", app.connections.entries)
+	}
+	if _, deferred := app.interpolator.deferred["pg"]; !deferred {
+		t.Error("connection settings were resolved")
+	}
+
+	// running the check needs the secret
+	var configErr *DataSourceConfigError
+	if _, err := app.PingDataSource(ctx, "pg"); !errors.As(err, &configErr) {
+		t.Errorf("PingDataSource() = %v, want DataSourceConfigError", err)
+	}
+}
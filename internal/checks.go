@@ -0,0 +1,408 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataBridgeTech/dbqcore"
+	"gopkg.in/yaml.v3"
+)
+
+// ChecksFile is a checks configuration file as seen by dbqctl: dbqcore rules and checks
+// extended with settings which are handled by the cli itself (e.g. per-check timeout)
+type ChecksFile struct {
+	Path    string
+	Hash    string
+	Version string
+	Rules   []ChecksRule
+	// Warnings lists unknown keys ignored by lenient loading
+	Warnings []string
+}
+
+type ChecksRule struct {
+	// ID is the optional rule id, also used as the prefix of ids of its checks
+	ID      string
+	Tags    []string
+	Dataset string
+	Where   string
+	Checks  []Check
+}
+
+type Check struct {
+	dbqcore.DataQualityCheck
+	// ID identifies the check within the file, unless set explicitly it's derived from the check position,
+	// e.g. '2.5' is the fifth check of the second rule (or 'orders.5' if the rule id is 'orders')
+	ID string
+	// Tags are the check tags along with the tags of its rule
+	Tags []string
+	// Severity of the check failure, set with 'on_fail' ('error' by default)
+	Severity Severity
+	// Timeout limits the check execution time, zero means the global default is used
+	Timeout time.Duration
+	// SampleFailures is the number of failed rows to sample when the check fails, zero means --failed-rows is used
+	SampleFailures int
+	// Anomaly enables comparison of the check value with its history, nil if disabled
+	Anomaly *AnomalyConfig
+	// GroupBy are the expressions the check is evaluated per group of ('group_by' and 'partition_by'
+	// of the check or its rule), empty if the check is evaluated for the whole dataset
+	GroupBy []string
+}
+
+// checkSettings are the dbqctl-only keys of a check, they are stripped before the check is passed to dbqcore
+type checkSettings struct {
+	ID      string         `yaml:"id"`
+	Tags    []string       `yaml:"tags"`
+	OnFail  string         `yaml:"on_fail"`
+	Timeout string         `yaml:"timeout"`
+	Anomaly *AnomalyConfig `yaml:"anomaly"`
+	// SampleFailures is kept as a string, so invalid values are reported by applySettings
+	SampleFailures string `yaml:"sample_failures"`
+	// GroupBy and PartitionBy override the ones of the rule when set, e.g. 'group_by: []' disables grouping
+	GroupBy     *stringList `yaml:"group_by"`
+	PartitionBy *string     `yaml:"partition_by"`
+}
+
+// checkSettingsKeys lists keys of checkSettings, 'on_fail' is handled by dbqctl as it supports more severities than dbqcore
+var checkSettingsKeys = map[string]bool{
+	"id":              true,
+	"tags":            true,
+	"on_fail":         true,
+	"timeout":         true,
+	"anomaly":         true,
+	"sample_failures": true,
+	"group_by":        true,
+	"partition_by":    true,
+}
+
+// ruleSettings are the dbqctl-only keys of a rule, ignored by dbqcore
+type ruleSettings struct {
+	ID   string   `yaml:"id"`
+	Tags []string `yaml:"tags"`
+	// GroupBy and PartitionBy apply to every check of the rule which supports grouping, see SupportsGrouping
+	GroupBy     stringList `yaml:"group_by"`
+	PartitionBy string     `yaml:"partition_by"`
+}
+
+// LoadChecksFile reads the checks file, expands its includes and templates, extracts dbqctl-specific check settings and
+// decodes everything else using dbqcore checks format. Unknown keys are an error unless lenient is set
+func LoadChecksFile(path string, lenient bool) (*ChecksFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	expansion, err := expandChecksFile(path, &root)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	unknown := findUnknownChecksFileKeys(expansion.files, &root, expansion.unknown)
+	if len(unknown) > 0 {
+		unknownErr := &UnknownKeysError{Keys: unknown}
+		if !lenient {
+			return nil, unknownErr
+		}
+		warnings = unknownErr.Messages()
+	}
+
+	settings, err := extractCheckSettings(&root)
+	if err != nil {
+		return nil, err
+	}
+
+	var rulesCfg struct {
+		Rules []ruleSettings `yaml:"rules"`
+	}
+	if err := root.Decode(&rulesCfg); err != nil {
+		return nil, err
+	}
+
+	var coreCfg dbqcore.ChecksFileConfig
+	if err := root.Decode(&coreCfg); err != nil {
+		return nil, err
+	}
+
+	// included files are part of the hash, changing a shared template changes the checks
+	hash := sha256.New()
+	hash.Write(data)
+	for _, includedData := range expansion.included {
+		hash.Write(includedData)
+	}
+	checksFile := &ChecksFile{
+		Path:     path,
+		Hash:     hex.EncodeToString(hash.Sum(nil)),
+		Version:  coreCfg.Version,
+		Rules:    make([]ChecksRule, 0, len(coreCfg.Rules)),
+		Warnings: warnings,
+	}
+
+	checkIds := make(map[string]bool)
+	for ruleIdx, coreRule := range coreCfg.Rules {
+		rule := ChecksRule{
+			ID:      strconv.Itoa(ruleIdx + 1),
+			Dataset: coreRule.Dataset,
+			Where:   coreRule.Where,
+			Checks:  make([]Check, 0, len(coreRule.Checks)),
+		}
+		var ruleGroupBy []string
+		if ruleIdx < len(rulesCfg.Rules) {
+			if id := strings.TrimSpace(rulesCfg.Rules[ruleIdx].ID); id != "" {
+				rule.ID = id
+			}
+			rule.Tags = rulesCfg.Rules[ruleIdx].Tags
+			if ruleGroupBy, err = groupExpressions(rulesCfg.Rules[ruleIdx].GroupBy, rulesCfg.Rules[ruleIdx].PartitionBy); err != nil {
+				return nil, fmt.Errorf("rule %d (%s): %w", ruleIdx+1, rule.Dataset, err)
+			}
+		}
+
+		// an invalid dataset is reported when the rule is run
+		dataSourceId, _, datasetErr := ParseDatasetString(rule.Dataset)
+		for checkIdx, coreCheck := range coreRule.Checks {
+			if foreignKey := parseForeignKeyCheck(coreCheck.Expression); foreignKey != nil && datasetErr == nil {
+				if err := foreignKey.References.CheckDataSource(dataSourceId); err != nil {
+					return nil, fmt.Errorf("rule %d (%s), check '%s': %w", ruleIdx+1, rule.Dataset, coreCheck.Expression, err)
+				}
+			}
+			check := Check{
+				DataQualityCheck: coreCheck,
+				ID:               rule.ID + "." + strconv.Itoa(checkIdx+1),
+				Tags:             slices.Clone(rule.Tags),
+				Severity:         SeverityError,
+			}
+			if len(ruleGroupBy) > 0 && SupportsGrouping(coreCheck.Expression) {
+				check.GroupBy = ruleGroupBy
+			}
+			if ruleIdx < len(settings) && checkIdx < len(settings[ruleIdx]) {
+				if err := check.applySettings(settings[ruleIdx][checkIdx]); err != nil {
+					return nil, fmt.Errorf("rule %d (%s), check '%s': %w", ruleIdx+1, rule.Dataset, check.Expression, err)
+				}
+			}
+			if checkIds[check.ID] {
+				return nil, fmt.Errorf("rule %d (%s), check '%s': duplicate check id '%s'", ruleIdx+1, rule.Dataset, check.Expression, check.ID)
+			}
+			checkIds[check.ID] = true
+			rule.Checks = append(rule.Checks, check)
+		}
+
+		checksFile.Rules = append(checksFile.Rules, rule)
+	}
+
+	return checksFile, nil
+}
+
+// FindCheck returns the check with the given id along with its rule, nil if there is no such check
+func (f *ChecksFile) FindCheck(id string) (*ChecksRule, *Check) {
+	for ruleIdx := range f.Rules {
+		rule := &f.Rules[ruleIdx]
+		for checkIdx := range rule.Checks {
+			if rule.Checks[checkIdx].ID == id {
+				return rule, &rule.Checks[checkIdx]
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (c *Check) applySettings(settings *checkSettings) error {
+	if id := strings.TrimSpace(settings.ID); id != "" {
+		c.ID = id
+	}
+	for _, tag := range settings.Tags {
+		if !slices.Contains(c.Tags, tag) {
+			c.Tags = append(c.Tags, tag)
+		}
+	}
+
+	if settings.OnFail != "" {
+		severity, err := ParseSeverity(settings.OnFail)
+		if err != nil {
+			return fmt.Errorf("invalid on_fail: %w", err)
+		}
+		c.Severity = severity
+	}
+
+	if settings.Timeout != "" {
+		timeout, err := time.ParseDuration(settings.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout '%s': %w", settings.Timeout, err)
+		}
+		c.Timeout = timeout
+	}
+
+	if settings.SampleFailures != "" {
+		sampleFailures, err := strconv.Atoi(settings.SampleFailures)
+		if err != nil || sampleFailures < 0 {
+			return fmt.Errorf("invalid sample_failures '%s': expected a non-negative number of rows", settings.SampleFailures)
+		}
+		if !SupportsFailedRows(c.Expression) {
+			return fmt.Errorf("sample_failures is only supported by not_null, uniqueness and foreign_key checks")
+		}
+		c.SampleFailures = sampleFailures
+	}
+
+	if settings.Anomaly != nil {
+		if err := settings.Anomaly.validate(); err != nil {
+			return err
+		}
+		c.Anomaly = settings.Anomaly
+	}
+
+	if settings.GroupBy != nil || settings.PartitionBy != nil {
+		var partitionBy string
+		if settings.PartitionBy != nil {
+			partitionBy = *settings.PartitionBy
+		}
+		var groupBy []string
+		if settings.GroupBy != nil {
+			groupBy = *settings.GroupBy
+		}
+		groupBy, err := groupExpressions(groupBy, partitionBy)
+		if err != nil {
+			return err
+		}
+		if len(groupBy) > 0 && !SupportsGrouping(c.Expression) {
+			return fmt.Errorf("group_by and partition_by are not supported by schema_check and raw_query checks")
+		}
+		c.GroupBy = groupBy
+	}
+	if c.Anomaly != nil && len(c.GroupBy) > 0 {
+		return fmt.Errorf("anomaly detection is not supported by grouped checks, disable grouping of the check with 'group_by: []'")
+	}
+
+	return nil
+}
+
+// extractCheckSettings removes dbqctl-specific keys from every check of every rule and returns
+// them indexed by rule and check position. Settings are looked up both on the check level mapping
+// (e.g. next to 'schema_check') and inside the check expression body (e.g. next to 'desc')
+func extractCheckSettings(root *yaml.Node) ([][]*checkSettings, error) {
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+
+	rulesNode := mappingValue(doc, "rules")
+	if rulesNode == nil || rulesNode.Kind != yaml.SequenceNode {
+		return nil, nil
+	}
+
+	settings := make([][]*checkSettings, len(rulesNode.Content))
+	for ruleIdx, ruleNode := range rulesNode.Content {
+		checksNode := mappingValue(ruleNode, "checks")
+		if checksNode == nil || checksNode.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		settings[ruleIdx] = make([]*checkSettings, len(checksNode.Content))
+		for checkIdx, checkNode := range checksNode.Content {
+			extracted := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			if checkNode.Kind == yaml.MappingNode && len(checkNode.Content) >= 2 {
+				// the first key is the check expression itself
+				extractKeys(checkNode, extracted, 2)
+				if body := checkNode.Content[1]; body.Kind == yaml.MappingNode {
+					extractKeys(body, extracted, 0)
+					if len(body.Content) == 0 && len(checkNode.Content) == 2 {
+						// nothing left but the expression, use the plain form, e.g. '- not_null(id)'
+						checksNode.Content[checkIdx] = checkNode.Content[0]
+					}
+				}
+			}
+
+			checkSettings := &checkSettings{}
+			if err := extracted.Decode(checkSettings); err != nil {
+				return nil, fmt.Errorf("line %d: invalid check settings: %w", checkNode.Line, err)
+			}
+			settings[ruleIdx][checkIdx] = checkSettings
+		}
+	}
+
+	return settings, nil
+}
+
+// extractKeys moves known check settings keys from the mapping node into the target, starting at the given offset
+func extractKeys(mapping *yaml.Node, target *yaml.Node, offset int) {
+	kept := mapping.Content[:offset:offset]
+	for i := offset; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		if checkSettingsKeys[key.Value] {
+			target.Content = append(target.Content, key, value)
+		} else {
+			kept = append(kept, key, value)
+		}
+	}
+	mapping.Content = kept
+}
+
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// ParseDatasetString parses the rule dataset definition in the form of datasource@[dataset1, dataset2, ...]
+func ParseDatasetString(input string) (datasource string, datasets []string, err error) {
+	atIndex := strings.Index(input, "@")
+	if atIndex == -1 {
+		return "", nil, fmt.Errorf("invalid dataset string format: %s", input)
+	}
+
+	datasource = strings.TrimSpace(input[:atIndex])
+	if datasource == "" {
+		return "", nil, fmt.Errorf("datasource part cannot be empty: %s", input)
+	}
+
+	datasetPart := strings.TrimSpace(input[atIndex+1:])
+	if !strings.HasPrefix(datasetPart, "[") || !strings.HasSuffix(datasetPart, "]") {
+		return "", nil, fmt.Errorf("invalid dataset format (expected '[dataset1, dataset2,...]'): %s", input)
+	}
+
+	// slice off '[' and ']'
+	datasetsContent := datasetPart[1 : len(datasetPart)-1]
+	trimmedContent := strings.TrimSpace(datasetsContent)
+	if trimmedContent == "" {
+		return "", nil, fmt.Errorf("dataset part can't be empty: %s", input)
+	}
+
+	rawDatasets := strings.Split(datasetsContent, ",")
+	datasets = make([]string, 0, len(rawDatasets))
+	for _, ds := range rawDatasets {
+		cleanedDS := strings.TrimSpace(ds)
+		if cleanedDS != "" {
+			datasets = append(datasets, cleanedDS)
+		}
+	}
+
+	return datasource, datasets, nil
+}
@@ -0,0 +1,410 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DataBridgeTech/dbqcore"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ConfigEnvVar holds the config file path, or several paths separated by the OS path list separator
+	ConfigEnvVar = "DBQ_CONFIG"
+	// EnvironmentEnvVar selects the active environment unless given with --env
+	EnvironmentEnvVar = "DBQ_ENV"
+
+	maskedValue = "******"
+)
+
+// FindConfigFiles returns the config files to load in merge order, later files override earlier ones.
+// Explicitly given paths win, then $DBQ_CONFIG, otherwise the first existing file of
+// ./dbq.yaml, $XDG_CONFIG_HOME/dbq/dbq.yaml and $HOME/.dbq.yaml is used
+func FindConfigFiles(explicitPaths []string) ([]string, error) {
+	if len(explicitPaths) > 0 {
+		return explicitPaths, nil
+	}
+
+	if envPaths := os.Getenv(ConfigEnvVar); envPaths != "" {
+		var paths []string
+		for _, path := range filepath.SplitList(envPaths) {
+			if path != "" {
+				paths = append(paths, path)
+			}
+		}
+		return paths, nil
+	}
+
+	candidates := configSearchPaths()
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return []string{candidate}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("dbq config file not found, searched: %s (use --config, $%s or 'dbqctl config init')", strings.Join(candidates, ", "), ConfigEnvVar)
+}
+
+func checkConfigFilesExist(paths []string) error {
+	for _, path := range paths {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("dbq config file %s not found (use 'dbqctl config init' to create one)", path)
+		}
+	}
+	return nil
+}
+
+func configSearchPaths() []string {
+	paths := []string{"dbq.yaml"}
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	home, homeErr := os.UserHomeDir()
+	if configHome == "" && homeErr == nil {
+		configHome = filepath.Join(home, ".config")
+	}
+	if configHome != "" {
+		paths = append(paths, filepath.Join(configHome, "dbq", "dbq.yaml"))
+	}
+
+	if homeErr == nil {
+		paths = append(paths, filepath.Join(home, ".dbq.yaml"))
+	}
+	return paths
+}
+
+// loadConfigSettings reads and merges the settings of the given config files
+func loadConfigSettings(paths []string) (map[string]any, error) {
+	merged := make(map[string]any)
+	for _, path := range paths {
+		v := viper.New()
+		v.SetConfigFile(path)
+		v.SetConfigType("yaml")
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+		mergeSettings(merged, v.AllSettings())
+	}
+	return merged, nil
+}
+
+// mergeSettings deep merges src into dst. Mappings are merged key by key, data sources are merged by id
+// and any other value (including lists) from src replaces the one in dst
+func mergeSettings(dst map[string]any, src map[string]any) {
+	for key, srcValue := range src {
+		dstValue, exists := dst[key]
+		if !exists {
+			dst[key] = srcValue
+			continue
+		}
+
+		if key == "datasources" {
+			dstList, dstOk := dstValue.([]any)
+			srcList, srcOk := srcValue.([]any)
+			if dstOk && srcOk {
+				dst[key] = mergeDataSources(dstList, srcList)
+				continue
+			}
+		}
+
+		dstMap, dstOk := asSettingsMap(dstValue)
+		srcMap, srcOk := asSettingsMap(srcValue)
+		if dstOk && srcOk {
+			mergeSettings(dstMap, srcMap)
+			dst[key] = dstMap
+			continue
+		}
+
+		dst[key] = srcValue
+	}
+}
+
+// mergeDataSources merges data sources with the same id, data sources new to dst are appended in order
+func mergeDataSources(dst []any, src []any) []any {
+	for _, srcItem := range src {
+		srcMap, ok := asSettingsMap(srcItem)
+		id := settingsString(srcMap, "id")
+		if !ok || id == "" {
+			dst = append(dst, srcItem)
+			continue
+		}
+
+		merged := false
+		for i, dstItem := range dst {
+			if dstMap, ok := asSettingsMap(dstItem); ok && settingsString(dstMap, "id") == id {
+				mergeSettings(dstMap, srcMap)
+				dst[i] = dstMap
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			dst = append(dst, srcItem)
+		}
+	}
+	return dst
+}
+
+func asSettingsMap(value any) (map[string]any, bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		return v, true
+	case map[any]any:
+		converted := make(map[string]any, len(v))
+		for key, child := range v {
+			converted[fmt.Sprint(key)] = child
+		}
+		return converted, true
+	default:
+		return nil, false
+	}
+}
+
+// settingsValue returns a value by key, nested keys of lists are not lower-cased by viper
+func settingsValue(settings map[string]any, key string) any {
+	for k, v := range settings {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+func settingsString(settings map[string]any, key string) string {
+	switch v := settingsValue(settings, key).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// applyEnvironment merges the data sources of the selected environment into the top level data sources (by id)
+// and removes the environments section, so settings of other environments are never resolved
+func applyEnvironment(settings map[string]any, environment string) error {
+	environments, _ := asSettingsMap(settings["environments"])
+	delete(settings, "environments")
+	if environment == "" {
+		return nil
+	}
+
+	envSettings, ok := asSettingsMap(settingsValue(environments, environment))
+	if !ok {
+		names := make([]string, 0, len(environments))
+		for name := range environments {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("environment '%s' is not defined, dbq config has no environments", environment)
+		}
+		return fmt.Errorf("environment '%s' is not defined in dbq config (available: %s)", environment, strings.Join(names, ", "))
+	}
+
+	envDataSources, ok := settingsValue(envSettings, "datasources").([]any)
+	if !ok {
+		return nil
+	}
+	dataSources, _ := settings["datasources"].([]any)
+	for _, envDataSource := range envDataSources {
+		dsSettings, _ := asSettingsMap(envDataSource)
+		id := settingsString(dsSettings, "id")
+		if !slices.ContainsFunc(dataSources, func(ds any) bool {
+			dsMap, _ := asSettingsMap(ds)
+			return settingsString(dsMap, "id") == id
+		}) {
+			return fmt.Errorf("environment '%s' overrides unknown data source '%s'", environment, id)
+		}
+	}
+	settings["datasources"] = mergeDataSources(dataSources, envDataSources)
+	return nil
+}
+
+// definingConfigFile returns the last of the config files setting the dot separated key, which is the one
+// whose value wins the merge, empty if none sets it
+func definingConfigFile(paths []string, key string) (string, error) {
+	defining := ""
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		root, err := configRootNode(content)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if root != nil && findNodeByPath(root, strings.Split(key, ".")) != nil {
+			defining = path
+		}
+	}
+	return defining, nil
+}
+
+// RenderConfig renders the effective (merged) configuration as yaml. Unless resolved is set, interpolated values
+// are shown as written in the config files (e.g. '${PG_PASSWORD}'), otherwise their resolved values are shown.
+// Values of sensitive keys (password, secret, token, key) and resolved secrets are always masked
+func RenderConfig(dbqConfig *dbqcore.DbqConfig, cliConfig *CliConfig, interpolator *ConfigInterpolator, resolved bool) ([]byte, error) {
+	var root yaml.Node
+	if err := root.Encode(dbqConfig); err != nil {
+		return nil, err
+	}
+
+	var cliNode yaml.Node
+	if err := cliNode.Encode(cliConfig); err != nil {
+		return nil, err
+	}
+	if root.Kind == yaml.MappingNode && cliNode.Kind == yaml.MappingNode {
+		root.Content = append(root.Content, cliNode.Content...)
+	}
+
+	if !resolved {
+		restoreRawValues(&root, interpolator.RawValues, "")
+	}
+
+	maskSecrets(&root, "", interpolator, resolved)
+	return yaml.Marshal(&root)
+}
+
+// restoreRawValues puts the raw values (e.g. '${PG_PASSWORD}') of interpolated settings under the path back into the node
+func restoreRawValues(node *yaml.Node, rawValues map[string]string, path string) {
+	prefix := ""
+	if path != "" {
+		prefix = path + "."
+	}
+
+	for valuePath, rawValue := range rawValues {
+		if !strings.HasPrefix(valuePath, prefix) {
+			continue
+		}
+		if valueNode := findNodeByPath(node, strings.Split(strings.TrimPrefix(valuePath, prefix), ".")); valueNode != nil {
+			valueNode.Kind = yaml.ScalarNode
+			valueNode.Tag = "!!str"
+			valueNode.Value = rawValue
+			valueNode.Style = 0
+		}
+	}
+}
+
+func maskSecrets(node *yaml.Node, key string, interpolator *ConfigInterpolator, resolved bool) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			maskSecrets(node.Content[i+1], node.Content[i].Value, interpolator, resolved)
+		}
+	case yaml.SequenceNode, yaml.DocumentNode:
+		for _, child := range node.Content {
+			maskSecrets(child, key, interpolator, resolved)
+		}
+	case yaml.ScalarNode:
+		// placeholders and secret references are safe to show as written
+		isRaw := !resolved && (envPlaceholderRegex.MatchString(node.Value) || secretRefRegex.MatchString(node.Value))
+		if node.Value != "" && !isRaw && sensitiveKeyRegex.MatchString(key) {
+			node.Value = maskedValue
+			node.Tag = "!!str"
+			node.Style = 0
+			return
+		}
+		if redacted := interpolator.Redact(node.Value); redacted != node.Value {
+			node.Value = redacted
+			node.Tag = "!!str"
+		}
+	}
+}
+
+// findNodeByPath looks up a node by the dot separated path of mapping keys (case-insensitive) and sequence indexes
+func findNodeByPath(node *yaml.Node, path []string) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		return findNodeByPath(node.Content[0], path)
+	}
+	if len(path) == 0 {
+		return node
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if strings.EqualFold(node.Content[i].Value, path[0]) {
+				return findNodeByPath(node.Content[i+1], path[1:])
+			}
+		}
+	case yaml.SequenceNode:
+		if idx, err := strconv.Atoi(path[0]); err == nil && idx >= 0 && idx < len(node.Content) {
+			return findNodeByPath(node.Content[idx], path[1:])
+		}
+	}
+	return nil
+}
+
+// configTemplate is written by 'dbqctl config init'
+const configTemplate = `# dbqctl configuration
+# docs: https://github.com/DataBridgeTech/dbqctl
+version: "1"
+
+# data sources checks and profiling run against, referenced by id in checks files (e.g. 'pg@[public.orders]').
+# Every value can reference environment variables as ${ENV_VAR} or ${ENV_VAR:-default},
+# or be loaded from a secret with env://ENV_VAR or file:///path/to/secret.
+# Data sources can be added with 'dbqctl config add-datasource' and datasets with 'dbqctl import --update-config'
+datasources: []
+#  - id: pg
+#    type: postgresql # clickhouse, postgresql or mysql
+#    configuration:
+#      host: ${PG_HOST:-localhost}
+#      port: 5432
+#      username: ${PG_USER}
+#      password: ${PG_PASSWORD}
+#      database: analytics
+#    datasets:
+#      - public.orders
+
+# overrides of data sources per environment, selected with --env or $DBQ_ENV
+#environments:
+#  prod:
+#    datasources:
+#      - id: pg
+#        configuration:
+#          host: prod-db.internal
+
+# results of 'dbqctl check' runs, used by 'dbqctl history' and anomaly checks
+#history:
+#  enabled: true
+#  path: ./.dbq/history.db # relative to this file
+
+# masking of PII in failed rows sampled by 'dbqctl check --failed-rows', patterns are case-insensitive globs
+#failed_rows:
+#  mask_columns: [email, phone, "pii_*"]
+`
+
+// WriteConfigTemplate writes a commented config template, an existing file is only overwritten with force
+func WriteConfigTemplate(path string, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists (use --force to overwrite it)", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// the file is meant to hold credentials, so it's not readable by others
+	return writeFileAtomic(path, []byte(configTemplate), 0600)
+}
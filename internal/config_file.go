@@ -0,0 +1,535 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/DataBridgeTech/dbqcore"
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileUpdate is a pending change of the dbq config file
+type ConfigFileUpdate struct {
+	Path     string
+	Original []byte
+	Updated  []byte
+}
+
+// Changed reports whether the update modifies the file content
+func (u *ConfigFileUpdate) Changed() bool {
+	return !bytes.Equal(u.Original, u.Updated)
+}
+
+// Diff renders the update as a unified diff, empty if nothing changes
+func (u *ConfigFileUpdate) Diff() (string, error) {
+	if !u.Changed() {
+		return "", nil
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(u.Original),
+		B:        splitLines(u.Updated),
+		FromFile: u.Path,
+		ToFile:   u.Path,
+		Context:  3,
+	})
+}
+
+// splitLines splits content into lines keeping the line endings, unlike difflib.SplitLines
+// it doesn't produce an extra empty line for content ending with a newline
+func splitLines(content []byte) []string {
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Write replaces the config file with the updated content
+func (u *ConfigFileUpdate) Write() error {
+	if !u.Changed() {
+		return nil
+	}
+	return writeFileAtomic(u.Path, u.Updated, 0644)
+}
+
+// writeFileAtomic writes data to a temp file next to the target and renames it over the target,
+// so readers never observe a partially written file. The target's file mode is preserved, defaultMode is used for new files.
+func writeFileAtomic(path string, data []byte, defaultMode os.FileMode) error {
+	// rename replaces a symlink instead of the file it points to
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	mode := defaultMode
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		// no-op once the rename succeeded
+		_ = os.Remove(tmpName)
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, path)
+}
+
+// configFileChange lists the changes applied to one config file
+type configFileChange struct {
+	// datasets lists to write, by data source id
+	datasets map[string][]string
+	// ids of data sources to remove, together with their environment overrides
+	remove map[string]bool
+	// rendered data sources to append
+	add []*yaml.Node
+}
+
+// prepareConfigUpdates renders the in-memory data sources into the config files:
+//   - datasets lists are written to the last file which defines datasets of the data source,
+//     or to the first file defining the data source if none does
+//   - data sources missing from all files are appended to the first file
+//   - data sources missing in memory are removed from every file defining them
+func prepareConfigUpdates(paths []string, dataSources []dbqcore.DataSource, rawValues map[string]string) ([]*ConfigFileUpdate, error) {
+	contents := make([][]byte, len(paths))
+	owners := make(map[string]int)
+	for i, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		contents[i] = content
+
+		root, err := configRootNode(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		_, sourcesNode := mappingEntry(root, "datasources")
+		for _, dsNode := range sequenceItems(sourcesNode) {
+			_, idNode := mappingEntry(dsNode, "id")
+			if idNode == nil {
+				continue
+			}
+			if _, owned := owners[idNode.Value]; !owned {
+				owners[idNode.Value] = i
+			}
+			if keyNode, _ := mappingEntry(dsNode, "datasets"); keyNode != nil {
+				owners[idNode.Value] = i
+			}
+		}
+	}
+
+	changes := make([]configFileChange, len(paths))
+	for i := range changes {
+		changes[i] = configFileChange{datasets: make(map[string][]string), remove: make(map[string]bool)}
+	}
+
+	inMemory := make(map[string]bool)
+	for i, ds := range dataSources {
+		inMemory[ds.ID] = true
+		if owner, ok := owners[ds.ID]; ok {
+			changes[owner].datasets[ds.ID] = ds.Datasets
+			continue
+		}
+
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no dbq config file to add data source '%s' to", ds.ID)
+		}
+		node, err := renderDataSource(ds, rawValues, fmt.Sprintf("datasources.%d", i))
+		if err != nil {
+			return nil, err
+		}
+		changes[0].add = append(changes[0].add, node)
+	}
+	for id := range owners {
+		if !inMemory[id] {
+			for i := range changes {
+				changes[i].remove[id] = true
+			}
+		}
+	}
+
+	updates := make([]*ConfigFileUpdate, len(paths))
+	for i, path := range paths {
+		updated, err := updateConfigFile(contents[i], changes[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to update %s: %w", path, err)
+		}
+		updates[i] = &ConfigFileUpdate{Path: path, Original: contents[i], Updated: updated}
+	}
+	return updates, nil
+}
+
+// renderDataSource renders the data source as a yaml node, interpolated values are rendered in their raw form
+func renderDataSource(ds dbqcore.DataSource, rawValues map[string]string, path string) (*yaml.Node, error) {
+	var node yaml.Node
+	if err := node.Encode(ds); err != nil {
+		return nil, err
+	}
+	restoreRawValues(&node, rawValues, path)
+	return &node, nil
+}
+
+// updateConfigFile applies the change to the yaml node tree of the config file and encodes it again.
+// yaml.v3 keeps comments, key order, anchors, quoting and the block or flow style of every node,
+// blank lines dropped by the encoder are restored by preserveBlankLines. Unchanged content is returned as is.
+func updateConfigFile(content []byte, change configFileChange) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("dbq config root must be a mapping")
+	}
+
+	changed := false
+	_, sourcesNode := mappingEntry(root, "datasources")
+	var kept []*yaml.Node
+	for i, dsNode := range sequenceItems(sourcesNode) {
+		if dsNode.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("data source #%d must be a mapping", i+1)
+		}
+
+		_, idNode := mappingEntry(dsNode, "id")
+		if idNode != nil && change.remove[idNode.Value] {
+			detachAnchors(root, dsNode)
+			changed = true
+			continue
+		}
+		kept = append(kept, dsNode)
+
+		if idNode == nil {
+			continue
+		}
+		if dsDatasets, ok := change.datasets[idNode.Value]; ok && setDatasets(root, dsNode, dsDatasets) {
+			changed = true
+		}
+	}
+	if sourcesNode != nil && sourcesNode.Kind == yaml.SequenceNode {
+		sourcesNode.Content = kept
+	}
+
+	if len(change.add) > 0 {
+		if err := appendDataSources(root, change.add); err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+
+	// overrides of removed data sources would fail the config loading
+	_, environmentsNode := mappingEntry(root, "environments")
+	if environmentsNode != nil && environmentsNode.Kind == yaml.MappingNode {
+		for i := 1; i < len(environmentsNode.Content); i += 2 {
+			_, envSourcesNode := mappingEntry(environmentsNode.Content[i], "datasources")
+			if envSourcesNode == nil || envSourcesNode.Kind != yaml.SequenceNode {
+				continue
+			}
+			var envKept []*yaml.Node
+			for _, dsNode := range envSourcesNode.Content {
+				if _, idNode := mappingEntry(dsNode, "id"); idNode != nil && change.remove[idNode.Value] {
+					detachAnchors(root, dsNode)
+					changed = true
+					continue
+				}
+				envKept = append(envKept, dsNode)
+			}
+			envSourcesNode.Content = envKept
+		}
+	}
+
+	if !changed {
+		return content, nil
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(configIndent(content))
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return preserveBlankLines(content, out.Bytes()), nil
+}
+
+// appendDataSources appends the data sources to the datasources list, which is created if missing
+func appendDataSources(root *yaml.Node, dataSources []*yaml.Node) error {
+	keyNode, sourcesNode := mappingEntry(root, "datasources")
+	switch {
+	case keyNode == nil:
+		sourcesNode = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "datasources"}, sourcesNode)
+	case sourcesNode.Kind == yaml.ScalarNode && sourcesNode.Tag == "!!null":
+		list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", LineComment: sourcesNode.LineComment}
+		replaceMappingValue(root, sourcesNode, list)
+		sourcesNode = list
+	case sourcesNode.Kind != yaml.SequenceNode:
+		return fmt.Errorf("datasources at line %d must be a list", keyNode.Line)
+	}
+
+	if len(sourcesNode.Content) == 0 {
+		// 'datasources: []' becomes a block list
+		sourcesNode.Style = 0
+	}
+	sourcesNode.Content = append(sourcesNode.Content, dataSources...)
+	moveLineComment(keyNode, sourcesNode)
+	return nil
+}
+
+// moveLineComment moves the line comment of a block list to its key, the encoder drops it otherwise
+func moveLineComment(keyNode *yaml.Node, list *yaml.Node) {
+	if keyNode == nil || list.Style&yaml.FlowStyle != 0 || list.LineComment == "" || keyNode.LineComment != "" {
+		return
+	}
+	keyNode.LineComment, list.LineComment = list.LineComment, ""
+}
+
+// setDatasets replaces the datasets list of the data source and reports whether it changed.
+// Items of kept datasets are reused with their comments and the flow or block style of the list is kept
+func setDatasets(root *yaml.Node, dsNode *yaml.Node, datasets []string) bool {
+	keyNode, valueNode := mappingEntry(dsNode, "datasets")
+	if keyNode == nil {
+		if len(datasets) == 0 {
+			return false
+		}
+		dsNode.Content = append(dsNode.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "datasets"}, datasetsNode(nil, datasets))
+		return true
+	}
+
+	// an aliased list is replaced by its own copy, the anchored one stays as is
+	current := valueNode
+	if current.Kind == yaml.AliasNode {
+		current = current.Alias
+	}
+	if slices.Equal(sequenceValues(current), datasets) {
+		return false
+	}
+
+	list := datasetsNode(current, datasets)
+	list.HeadComment = valueNode.HeadComment
+	list.LineComment = valueNode.LineComment
+	list.FootComment = valueNode.FootComment
+	if valueNode.Kind != yaml.AliasNode {
+		detachAnchors(root, valueNode, list.Content...)
+	}
+	replaceMappingValue(dsNode, valueNode, list)
+	moveLineComment(keyNode, list)
+	return true
+}
+
+func datasetsNode(existing *yaml.Node, datasets []string) *yaml.Node {
+	list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	items := make(map[string]*yaml.Node)
+	if existing != nil && existing.Kind == yaml.SequenceNode {
+		list.Style = existing.Style
+		for _, item := range existing.Content {
+			if item.Kind == yaml.ScalarNode {
+				items[item.Value] = item
+			}
+		}
+	}
+	if len(datasets) == 0 {
+		// rendered as []
+		list.Style = yaml.FlowStyle
+	}
+
+	for _, dataset := range datasets {
+		item, ok := items[dataset]
+		if !ok {
+			item = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: dataset}
+		}
+		list.Content = append(list.Content, item)
+	}
+	return list
+}
+
+func replaceMappingValue(mapping *yaml.Node, value *yaml.Node, replacement *yaml.Node) {
+	for i := 1; i < len(mapping.Content); i += 2 {
+		if mapping.Content[i] == value {
+			mapping.Content[i] = replacement
+		}
+	}
+}
+
+// detachAnchors replaces aliases of anchors defined within the node (except within the kept nodes) by copies
+// of the anchored nodes, so the node can be removed from the document without breaking the aliases
+func detachAnchors(root *yaml.Node, node *yaml.Node, kept ...*yaml.Node) {
+	anchored := make(map[*yaml.Node]bool)
+	collectAnchors(node, anchored)
+	for _, keptNode := range kept {
+		delete(anchored, keptNode)
+	}
+	if len(anchored) > 0 {
+		replaceAliases(root, anchored)
+	}
+}
+
+func collectAnchors(node *yaml.Node, anchored map[*yaml.Node]bool) {
+	if node.Anchor != "" {
+		anchored[node] = true
+	}
+	for _, child := range node.Content {
+		collectAnchors(child, anchored)
+	}
+}
+
+func replaceAliases(node *yaml.Node, anchored map[*yaml.Node]bool) {
+	for i, child := range node.Content {
+		if child.Kind == yaml.AliasNode && anchored[child.Alias] {
+			copied := copyNode(child.Alias)
+			copied.HeadComment = child.HeadComment
+			copied.LineComment = child.LineComment
+			copied.FootComment = child.FootComment
+			node.Content[i] = copied
+			continue
+		}
+		replaceAliases(child, anchored)
+	}
+}
+
+// copyNode deep copies the node without anchors
+func copyNode(node *yaml.Node) *yaml.Node {
+	copied := *node
+	copied.Anchor = ""
+	copied.HeadComment, copied.LineComment, copied.FootComment = "", "", ""
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		copied.Content[i] = copyNode(child)
+	}
+	return &copied
+}
+
+// configIndent returns the indentation of the first indented line, the encoder default of 4 spaces for flat files
+func configIndent(content []byte) int {
+	for _, line := range splitLines(content) {
+		trimmed := strings.TrimLeft(line, " ")
+		if text := strings.TrimSpace(trimmed); text != "" && !strings.HasPrefix(text, "#") && len(trimmed) < len(line) {
+			return min(max(len(line)-len(trimmed), 2), 9)
+		}
+	}
+	return 4
+}
+
+// preserveBlankLines puts blank lines dropped by the yaml encoder back before the lines which
+// follow a blank line in the original content
+func preserveBlankLines(original []byte, updated []byte) []byte {
+	normalize := func(lines []string) []string {
+		normalized := make([]string, len(lines))
+		for i, line := range lines {
+			normalized[i] = strings.TrimRight(line, "\r\n")
+		}
+		return normalized
+	}
+	originalLines := normalize(splitLines(original))
+	updatedLines := splitLines(updated)
+
+	blankBefore := make(map[int]bool)
+	for _, block := range difflib.NewMatcher(originalLines, normalize(updatedLines)).GetMatchingBlocks() {
+		for k := 0; k < block.Size; k++ {
+			if i := block.A + k; i > 0 && strings.TrimSpace(originalLines[i-1]) == "" && block.B+k > 0 {
+				blankBefore[block.B+k] = true
+			}
+		}
+	}
+
+	var out strings.Builder
+	for i, line := range updatedLines {
+		if blankBefore[i] {
+			out.WriteString("\n")
+		}
+		out.WriteString(line)
+	}
+	return []byte(out.String())
+}
+
+// configRootNode returns the root mapping of the config file, nil for an empty file
+func configRootNode(content []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("dbq config root must be a mapping")
+	}
+	return doc.Content[0], nil
+}
+
+func sequenceItems(node *yaml.Node) []*yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return node.Content
+}
+
+func sequenceValues(node *yaml.Node) []string {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	var values []string
+	for _, item := range node.Content {
+		values = append(values, item.Value)
+	}
+	return values
+}
+
+// mappingEntry looks up a key in a mapping node, keys are matched case-insensitively like viper does
+func mappingEntry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if strings.EqualFold(node.Content[i].Value, key) {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DataBridgeTech/dbqcore"
+	"gopkg.in/yaml.v3"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files of config file updates")
+
+func TestUpdateConfigFile(t *testing.T) {
+	added, err := renderDataSource(dbqcore.DataSource{
+		ID:   "pg",
+		Type: "postgresql",
+		Configuration: dbqcore.ConnectionConfig{
+			Host:     "localhost",
+			Password: "s3cret",
+		},
+		Datasets: []string{"public.orders"},
+	}, map[string]string{"datasources.0.configuration.password": "${PG_PASSWORD}"}, "datasources.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		file   string
+		change configFileChange
+	}{
+		{
+			name: "comments",
+			file: "comments.yaml",
+			change: configFileChange{
+				datasets: map[string][]string{
+					"pg": {"public.orders", "public.payments"},
+					"ch": {"events.clicks", "events.views"},
+				},
+			},
+		},
+		{
+			name: "anchors",
+			file: "anchors.yaml",
+			change: configFileChange{
+				datasets: map[string][]string{
+					"pg":         {"public.orders", "public.customers"},
+					"pg_replica": {"public.orders"},
+				},
+				remove: map[string]bool{"pg_archive": true},
+			},
+		},
+		{
+			name: "anchors_remove_anchored",
+			file: "anchors.yaml",
+			change: configFileChange{
+				remove: map[string]bool{"pg": true},
+			},
+		},
+		{
+			name: "flow",
+			file: "flow.yaml",
+			change: configFileChange{
+				datasets: map[string][]string{
+					"pg":    {"public.orders", "public.customers"},
+					"ch":    {"events.views", "123"},
+					"mysql": {},
+				},
+			},
+		},
+		{
+			name: "null_datasets",
+			file: "null_datasets.yaml",
+			change: configFileChange{
+				datasets: map[string][]string{
+					"pg": {"public.orders"},
+					"ch": {"events.clicks"},
+				},
+			},
+		},
+		{
+			name: "empty_list",
+			file: "empty_list.yaml",
+			change: configFileChange{
+				add: []*yaml.Node{added},
+			},
+		},
+		{
+			name: "environments",
+			file: "environments.yaml",
+			change: configFileChange{
+				remove: map[string]bool{"legacy": true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join("testdata", "config_file", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			updated, err := updateConfigFile(content, tt.change)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the result must load like any other config file
+			var parsed map[string]any
+			if err := yaml.Unmarshal(updated, &parsed); err != nil {
+				t.Fatalf("updated config doesn't parse: %v\n%s", err, updated)
+			}
+
+			goldenPath := filepath.Join("testdata", "config_file", tt.name+".golden")
+			if *updateGolden {
+				if err := os.WriteFile(goldenPath, updated, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			golden, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(updated) != string(golden) {
+				t.Errorf("updated config differs from %s:\n%s", goldenPath, updated)
+			}
+		})
+	}
+}
+
+func TestUpdateConfigFileUnchanged(t *testing.T) {
+	content := []byte("version: \"1\"\ndatasources:\n  - id: pg\n    datasets: [a, b] # kept as is\n")
+	updated, err := updateConfigFile(content, configFileChange{datasets: map[string][]string{"pg": {"a", "b"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(updated) != string(content) {
+		t.Errorf("unchanged config was rewritten:\n%s", updated)
+	}
+}
+
+func TestUpdateConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		change  configFileChange
+		wantErr string
+	}{
+		{
+			name:    "datasources not a list",
+			content: "datasources: pg\n",
+			change:  configFileChange{add: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}},
+			wantErr: "datasources at line 1 must be a list",
+		},
+		{
+			name:    "data source not a mapping",
+			content: "datasources:\n  - pg\n",
+			change:  configFileChange{datasets: map[string][]string{"pg": {"a"}}},
+			wantErr: "data source #1 must be a mapping",
+		},
+		{
+			name:    "root not a mapping",
+			content: "- pg\n",
+			wantErr: "dbq config root must be a mapping",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := updateConfigFile([]byte(tt.content), tt.change)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindConfigFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		explicit []string
+		envPaths string
+		files    []string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "explicit paths win",
+			explicit: []string{"a.yaml", "b.yaml"},
+			envPaths: "env.yaml",
+			files:    []string{"dbq.yaml"},
+			want:     []string{"a.yaml", "b.yaml"},
+		},
+		{
+			name:     "env paths before discovery",
+			envPaths: "one.yaml" + string(os.PathListSeparator) + string(os.PathListSeparator) + "two.yaml",
+			files:    []string{"dbq.yaml"},
+			want:     []string{"one.yaml", "two.yaml"},
+		},
+		{
+			name:  "working directory first",
+			files: []string{"dbq.yaml", "xdg/dbq/dbq.yaml", "home/.dbq.yaml"},
+			want:  []string{"dbq.yaml"},
+		},
+		{
+			name:  "xdg config home before home",
+			files: []string{"xdg/dbq/dbq.yaml", "home/.dbq.yaml"},
+			want:  []string{"xdg/dbq/dbq.yaml"},
+		},
+		{
+			name:  "home",
+			files: []string{"home/.dbq.yaml"},
+			want:  []string{"home/.dbq.yaml"},
+		},
+		{
+			name:  "directories are skipped",
+			files: []string{"dbq.yaml/", "home/.dbq.yaml"},
+			want:  []string{"home/.dbq.yaml"},
+		},
+		{
+			name:    "not found",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Chdir(dir)
+			t.Setenv("HOME", filepath.Join(dir, "home"))
+			t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+			t.Setenv(ConfigEnvVar, tt.envPaths)
+
+			for _, file := range tt.files {
+				path := filepath.Join(dir, file)
+				if strings.HasSuffix(file, "/") {
+					if err := os.MkdirAll(path, 0o755); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte("version: \"1\"\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := FindConfigFiles(tt.explicit)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// discovered files outside the working directory are absolute
+			for i, path := range got {
+				if rel, err := filepath.Rel(dir, path); err == nil && filepath.IsAbs(path) {
+					got[i] = rel
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindConfigFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  map[string]any
+		src  map[string]any
+		want map[string]any
+	}{
+		{
+			name: "mappings are merged by key",
+			dst:  map[string]any{"history": map[string]any{"enabled": true, "path": "a.db"}, "version": "1"},
+			src:  map[string]any{"history": map[string]any{"path": "b.db"}},
+			want: map[string]any{"history": map[string]any{"enabled": true, "path": "b.db"}, "version": "1"},
+		},
+		{
+			name: "lists are replaced",
+			dst:  map[string]any{"failed_rows": map[string]any{"mask_columns": []any{"email", "phone"}}},
+			src:  map[string]any{"failed_rows": map[string]any{"mask_columns": []any{"ssn"}}},
+			want: map[string]any{"failed_rows": map[string]any{"mask_columns": []any{"ssn"}}},
+		},
+		{
+			name: "scalar replaces mapping",
+			dst:  map[string]any{"history": map[string]any{"path": "a.db"}},
+			src:  map[string]any{"history": nil},
+			want: map[string]any{"history": nil},
+		},
+		{
+			name: "data sources are merged by id",
+			dst: map[string]any{"datasources": []any{
+				map[string]any{"id": "pg", "type": "postgresql", "configuration": map[string]any{"host": "localhost", "port": 5432}, "datasets": []any{"a", "b"}},
+				map[string]any{"id": "ch", "type": "clickhouse"},
+			}},
+			src: map[string]any{"datasources": []any{
+				map[string]any{"id": "pg", "configuration": map[string]any{"host": "db.internal"}, "datasets": []any{"c"}},
+				map[string]any{"id": "mysql", "type": "mysql"},
+			}},
+			want: map[string]any{"datasources": []any{
+				map[string]any{"id": "pg", "type": "postgresql", "configuration": map[string]any{"host": "db.internal", "port": 5432}, "datasets": []any{"c"}},
+				map[string]any{"id": "ch", "type": "clickhouse"},
+				map[string]any{"id": "mysql", "type": "mysql"},
+			}},
+		},
+		{
+			name: "data sources without id are appended",
+			dst:  map[string]any{"datasources": []any{map[string]any{"id": "pg"}}},
+			src:  map[string]any{"datasources": []any{map[string]any{"type": "mysql"}}},
+			want: map[string]any{"datasources": []any{map[string]any{"id": "pg"}, map[string]any{"type": "mysql"}}},
+		},
+		{
+			name: "yaml mappings with any keys",
+			dst:  map[string]any{"datasources": []any{map[any]any{"id": "pg", "type": "postgresql"}}},
+			src:  map[string]any{"datasources": []any{map[any]any{"id": "pg", "type": "mysql"}}},
+			want: map[string]any{"datasources": []any{map[string]any{"id": "pg", "type": "mysql"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mergeSettings(tt.dst, tt.src)
+			if !reflect.DeepEqual(tt.dst, tt.want) {
+				t.Errorf("mergeSettings() = %v, want %v", tt.dst, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyEnvironment(t *testing.T) {
+	settings := func() map[string]any {
+		return map[string]any{
+			"datasources": []any{
+				map[string]any{"id": "pg", "configuration": map[string]any{"host": "localhost", "port": 5432}},
+				map[string]any{"id": "ch", "configuration": map[string]any{"host": "localhost"}},
+			},
+			"environments": map[string]any{
+				"prod": map[string]any{"datasources": []any{
+					map[string]any{"id": "pg", "configuration": map[string]any{"host": "prod-db"}},
+				}},
+				"broken": map[string]any{"datasources": []any{
+					map[string]any{"id": "nope"},
+				}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		environment string
+		want        []any
+		wantErr     string
+	}{
+		{
+			name: "no environment",
+			want: settings()["datasources"].([]any),
+		},
+		{
+			name:        "overrides by id",
+			environment: "prod",
+			want: []any{
+				map[string]any{"id": "pg", "configuration": map[string]any{"host": "prod-db", "port": 5432}},
+				map[string]any{"id": "ch", "configuration": map[string]any{"host": "localhost"}},
+			},
+		},
+		{
+			name:        "unknown environment",
+			environment: "staging",
+			wantErr:     "environment 'staging' is not defined in dbq config (available: broken, prod)",
+		},
+		{
+			name:        "unknown data source",
+			environment: "broken",
+			wantErr:     "environment 'broken' overrides unknown data source 'nope'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := settings()
+			err := applyEnvironment(s, tt.environment)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := s["environments"]; ok {
+				t.Error("environments section wasn't removed")
+			}
+			if !reflect.DeepEqual(s["datasources"], tt.want) {
+				t.Errorf("datasources = %v, want %v", s["datasources"], tt.want)
+			}
+		})
+	}
+
+	if err := applyEnvironment(map[string]any{}, "prod"); err == nil || err.Error() != "environment 'prod' is not defined, dbq config has no environments" {
+		t.Errorf("error = %v", err)
+	}
+}
+
+// TestLoadConfigLayers checks the precedence of config files given in merge order and the selected environment
+func TestLoadConfigLayers(t *testing.T) {
+	dir := writeChecksFiles(t, map[string]string{
+		"base.yaml": `version: "1"
+datasources:
+  - id: pg
+    type: postgresql
+    configuration:
+      host: localhost
+      port: 5432
+      database: analytics
+    datasets:
+      - public.orders
+environments:
+  prod:
+    datasources:
+      - id: pg
+        configuration:
+          host: prod-db
+history:
+  path: base.db
+`,
+		"local.yaml": `datasources:
+  - id: pg
+    configuration:
+      port: 6432
+    datasets:
+      - public.customers
+environments:
+  prod:
+    datasources:
+      - id: pg
+        configuration:
+          database: analytics_prod
+`,
+	})
+	paths := []string{filepath.Join(dir, "base.yaml"), filepath.Join(dir, "local.yaml")}
+
+	dbqConfig, cliConfig, _, _, err := initConfig(paths, "prod", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dbqConfig.DataSources) != 1 {
+		t.Fatalf("data sources = %+v", dbqConfig.DataSources)
+	}
+
+	pg := dbqConfig.DataSources[0]
+	// the environments section is merged like any other mapping, so overrides of both files apply
+	if pg.Type != "postgresql" || pg.Configuration.Host != "prod-db" || pg.Configuration.Port != 6432 || pg.Configuration.Database != "analytics_prod" {
+		t.Errorf("pg = %+v", pg)
+	}
+	if !reflect.DeepEqual(pg.Datasets, []string{"public.customers"}) {
+		t.Errorf("datasets = %v, want the list of the later file", pg.Datasets)
+	}
+	if cliConfig.History.Path != "base.db" {
+		t.Errorf("history path = %q", cliConfig.History.Path)
+	}
+}
+
+// writeChecksFiles writes the files, keyed by their path relative to the directory, into a temporary directory
+// and returns the directory
+func writeChecksFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
@@ -0,0 +1,217 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataBridgeTech/dbqcore"
+	"github.com/DataBridgeTech/dbqcore/dbq"
+)
+
+type DataSourceConnector interface {
+	Ping(ctx context.Context) (string, error)
+	ImportDatasets(ctx context.Context, filter string) ([]string, error)
+}
+
+type DataSourceProfiler interface {
+	ProfileDataset(ctx context.Context, dataset string, sample bool, maxConcurrent int, collectErrors bool) (*dbqcore.TableMetrics, error)
+}
+
+// ConnectionRegistry lazily creates dbqcore connectors, profilers and adapters once per data source
+// and shares them between all commands and checks. Every dbqcore instance holds its own pool, so a data source
+// has at most one pool per kind, however many checks run against it
+type ConnectionRegistry struct {
+	mu       sync.Mutex
+	poolSize int
+	logger   *slog.Logger
+	resolve  func(dataSource *dbqcore.DataSource) error
+	entries  map[string]*connectionEntry
+	// adapters rendering check queries by data source type, see Interpreter
+	interpreters map[string]dbqcore.DbqDataSourceAdapter
+}
+
+type connectionEntry struct {
+	dataSource *dbqcore.DataSource
+	openedAt   time.Time
+	connector  DataSourceConnector
+	profiler   DataSourceProfiler
+	adapter    dbqcore.DbqDataSourceAdapter
+	requests   atomic.Int64
+}
+
+// NewConnectionRegistry creates a registry calling resolve before the first connection to a data source is made
+func NewConnectionRegistry(poolSize int, logger *slog.Logger, resolve func(dataSource *dbqcore.DataSource) error) *ConnectionRegistry {
+	return &ConnectionRegistry{
+		poolSize:     poolSize,
+		logger:       logger,
+		resolve:      resolve,
+		entries:      make(map[string]*connectionEntry),
+		interpreters: make(map[string]dbqcore.DbqDataSourceAdapter),
+	}
+}
+
+func (r *ConnectionRegistry) Connector(ctx context.Context, dataSource *dbqcore.DataSource) (DataSourceConnector, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, err := r.entry(dataSource)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.connector == nil {
+		cnn, err := dbq.NewDbqConnector(dataSource, r.poolSize, r.logger)
+		if err != nil {
+			return nil, err
+		}
+		entry.connector = cnn
+		r.logger.DebugContext(ctx, "Created data source connector", "datasource", dataSource.ID)
+	}
+
+	entry.requests.Add(1)
+	return entry.connector, nil
+}
+
+func (r *ConnectionRegistry) Profiler(ctx context.Context, dataSource *dbqcore.DataSource) (DataSourceProfiler, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, err := r.entry(dataSource)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.profiler == nil {
+		profiler, err := dbq.NewDbqProfiler(dataSource, r.poolSize, r.logger)
+		if err != nil {
+			return nil, err
+		}
+		entry.profiler = profiler
+		r.logger.DebugContext(ctx, "Created data source profiler", "datasource", dataSource.ID)
+	}
+
+	entry.requests.Add(1)
+	return entry.profiler, nil
+}
+
+func (r *ConnectionRegistry) Adapter(ctx context.Context, dataSource *dbqcore.DataSource) (dbqcore.DbqDataSourceAdapter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, err := r.entry(dataSource)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.adapter == nil {
+		adapter, err := dbq.NewDbqAdapter(dataSource, r.poolSize, r.logger)
+		if err != nil {
+			return nil, err
+		}
+		entry.adapter = adapter
+		r.logger.DebugContext(ctx, "Created data source adapter", "datasource", dataSource.ID)
+	}
+
+	entry.requests.Add(1)
+	return entry.adapter, nil
+}
+
+// Interpreter returns an adapter to render check queries in the dialect of the data source, which must not be
+// used to run them. It's created from the data source type alone, so no connection settings (secrets included)
+// are resolved and no connection is made
+func (r *ConnectionRegistry) Interpreter(ctx context.Context, dataSource *dbqcore.DataSource) (dbqcore.DbqDataSourceAdapter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if dataSource == nil {
+		return nil, fmt.Errorf("data source not found in dbq configuration")
+	}
+
+	interpreter, ok := r.interpreters[dataSource.Type]
+	if !ok {
+		// dbqcore connects lazily, on the first query
+		adapter, err := dbq.NewDbqAdapter(&dbqcore.DataSource{ID: dataSource.ID, Type: dataSource.Type}, 1, r.logger)
+		if err != nil {
+			return nil, err
+		}
+		interpreter = adapter
+		r.interpreters[dataSource.Type] = interpreter
+		r.logger.DebugContext(ctx, "Created check query interpreter", "type", dataSource.Type)
+	}
+	return interpreter, nil
+}
+
+// Close releases every connection opened through the registry, the registry can't be used afterward
+func (r *ConnectionRegistry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for id, entry := range r.entries {
+		r.logger.Debug("Closing data source connections",
+			"datasource", id,
+			"pool_size", r.poolSize,
+			"requests", entry.requests.Load(),
+			"open_duration", time.Since(entry.openedAt).String())
+
+		for _, conn := range []any{entry.connector, entry.profiler, entry.adapter} {
+			if closer, ok := conn.(io.Closer); ok {
+				if err := closer.Close(); err != nil {
+					errs = append(errs, fmt.Errorf("failed to close connection to '%s': %w", id, err))
+				}
+			}
+		}
+	}
+
+	for dsType, interpreter := range r.interpreters {
+		if closer, ok := interpreter.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close %s query interpreter: %w", dsType, err))
+			}
+		}
+	}
+
+	r.entries = make(map[string]*connectionEntry)
+	r.interpreters = make(map[string]dbqcore.DbqDataSourceAdapter)
+	return errors.Join(errs...)
+}
+
+func (r *ConnectionRegistry) entry(dataSource *dbqcore.DataSource) (*connectionEntry, error) {
+	if dataSource == nil {
+		return nil, fmt.Errorf("data source not found in dbq configuration")
+	}
+
+	entry, ok := r.entries[dataSource.ID]
+	if !ok {
+		if err := r.resolve(dataSource); err != nil {
+			return nil, err
+		}
+		entry = &connectionEntry{
+			dataSource: dataSource,
+			openedAt:   time.Now(),
+		}
+		r.entries[dataSource.ID] = entry
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// explainStatement wraps the query into the EXPLAIN statement of the data source dialect. The plan is requested
+// in JSON format, so it's returned as a single value
+func explainStatement(dataSourceType string, query string) (string, error) {
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	switch strings.ToLower(dataSourceType) {
+	case "postgresql":
+		return "EXPLAIN (FORMAT JSON) " + query, nil
+	case "mysql":
+		return "EXPLAIN FORMAT=JSON " + query, nil
+	case "clickhouse":
+		return "EXPLAIN json = 1, indexes = 1, description = 1 " + query, nil
+	default:
+		return "", fmt.Errorf("explain is not supported for data source type '%s'", dataSourceType)
+	}
+}
+
+// formatQueryPlan indents JSON plans, any other output is returned as is
+func formatQueryPlan(plan string) string {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(plan), "", "  "); err != nil {
+		return plan
+	}
+	return indented.String()
+}
+
+// renderedCheckQuery substitutes the dataset placeholder left in the query (e.g. by raw_query checks)
+func renderedCheckQuery(query string, dataset string) string {
+	return strings.ReplaceAll(query, "{{dataset}}", dataset)
+}
@@ -0,0 +1,341 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	CheckFuncSchemaCheck = "schema_check"
+	CheckFuncRawQuery    = "raw_query"
+	CheckFuncRowCount    = "row_count"
+	CheckFuncNotNull     = "not_null"
+	CheckFuncUniqueness  = "uniqueness"
+	CheckFuncFreshness   = "freshness"
+	CheckFuncMin         = "min"
+	CheckFuncMax         = "max"
+	CheckFuncAvg         = "avg"
+	CheckFuncSum         = "sum"
+	CheckFuncStddev      = "stddev"
+	CheckFuncForeignKey  = "foreign_key"
+)
+
+type checkValueKind int
+
+const (
+	valueKindNumber checkValueKind = iota
+	valueKindNumberOrString
+	valueKindDuration
+)
+
+type checkFuncSpec struct {
+	args               int
+	comparisonRequired bool
+	valueKind          checkValueKind
+}
+
+var checkFuncSpecs = map[string]checkFuncSpec{
+	CheckFuncSchemaCheck: {args: 0},
+	CheckFuncRawQuery:    {args: 0},
+	CheckFuncRowCount:    {args: 0, comparisonRequired: true},
+	CheckFuncNotNull:     {args: 1},
+	CheckFuncUniqueness:  {args: 1},
+	CheckFuncFreshness:   {args: 1, comparisonRequired: true, valueKind: valueKindDuration},
+	CheckFuncMin:         {args: 1, comparisonRequired: true, valueKind: valueKindNumberOrString},
+	CheckFuncMax:         {args: 1, comparisonRequired: true, valueKind: valueKindNumberOrString},
+	CheckFuncAvg:         {args: 1, comparisonRequired: true},
+	CheckFuncSum:         {args: 1, comparisonRequired: true},
+	CheckFuncStddev:      {args: 1, comparisonRequired: true},
+	CheckFuncForeignKey:  {args: 1},
+}
+
+var (
+	checkFuncNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	durationRegex      = regexp.MustCompile(`^\d+(\.\d+)?[smhdw]?$`)
+	referencesRegex    = regexp.MustCompile(`(?i)^references\s+(?:([^@\s()]+)@)?([^@\s()]+)\s*\(\s*([^(),]+?)\s*\)$`)
+)
+
+var comparisonOperators = []string{"<=", ">=", "==", "!=", "<>", "<", ">", "="}
+
+type exprTokenKind int
+
+const (
+	// tokenWord is a bare word, e.g. a function name, a number, a duration or 'between'
+	tokenWord exprTokenKind = iota
+	// tokenString is a single-quoted string literal including its quotes, e.g. '2024-01-01 00:00:00'
+	tokenString
+	tokenOperator
+	// tokenGroup is a parenthesized group, its text is the content between the parentheses
+	tokenGroup
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	// pos is the byte offset of the token in the expression
+	pos int
+}
+
+// CheckExpression is a parsed check expression, e.g. 'avg(price) between 10 and 20'
+type CheckExpression struct {
+	Function string
+	Args     []string
+	// Operator is a comparison operator or 'between', empty if the check has no comparison
+	Operator string
+	Values   []string
+	// References is the referenced column of foreign_key checks
+	References *ColumnReference
+}
+
+// ColumnReference is the column referenced by a foreign_key check, e.g. 'departments(dept_no)'
+type ColumnReference struct {
+	// DataSource is set if the reference is qualified, e.g. 'mysql@employees.departments(dept_no)'
+	DataSource string
+	Dataset    string
+	Column     string
+}
+
+// Column returns the first function argument, empty if there are none
+func (e *CheckExpression) Column() string {
+	if len(e.Args) == 0 {
+		return ""
+	}
+	return e.Args[0]
+}
+
+// ParseCheckExpression parses and validates the check expression syntax as supported by dbqcore
+func ParseCheckExpression(expression string) (*CheckExpression, error) {
+	expression = strings.TrimSpace(expression)
+	tokens, err := tokenizeCheckExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 || tokens[0].kind != tokenWord || !checkFuncNameRegex.MatchString(tokens[0].text) {
+		return nil, fmt.Errorf("invalid check expression '%s'", expression)
+	}
+
+	name := tokens[0].text
+	parsed := &CheckExpression{Function: strings.ToLower(name)}
+	spec, ok := checkFuncSpecs[parsed.Function]
+	if !ok {
+		return nil, fmt.Errorf("unknown check function '%s'", name)
+	}
+
+	rest := tokens[1:]
+	if len(rest) > 0 && rest[0].kind == tokenGroup {
+		for _, arg := range splitArgs(rest[0].text) {
+			arg = strings.TrimSpace(arg)
+			if arg == "" {
+				return nil, fmt.Errorf("empty argument in '%s'", expression)
+			}
+			parsed.Args = append(parsed.Args, arg)
+		}
+		rest = rest[1:]
+	}
+	if len(parsed.Args) != spec.args {
+		return nil, fmt.Errorf("'%s' expects %d argument(s), got %d", parsed.Function, spec.args, len(parsed.Args))
+	}
+
+	comparison := ""
+	if len(rest) > 0 {
+		comparison = expression[rest[0].pos:]
+	}
+	if parsed.Function == CheckFuncForeignKey {
+		references := referencesRegex.FindStringSubmatch(comparison)
+		if references == nil {
+			return nil, fmt.Errorf("'%s' requires a referenced column, e.g. '%s(dept_no) references departments(dept_no)'", parsed.Function, name)
+		}
+		parsed.References = &ColumnReference{DataSource: references[1], Dataset: references[2], Column: references[3]}
+		return parsed, nil
+	}
+	if comparison == "" {
+		if spec.comparisonRequired {
+			return nil, fmt.Errorf("'%s' requires a comparison, e.g. '%s between 1 and 10' or '%s > 0'", parsed.Function, name, name)
+		}
+		return parsed, nil
+	}
+
+	if parsed.Function == CheckFuncSchemaCheck {
+		return nil, fmt.Errorf("'%s' doesn't support comparison", parsed.Function)
+	}
+
+	isValue := func(token exprToken) bool {
+		return token.kind == tokenWord || token.kind == tokenString
+	}
+	isWord := func(token exprToken, word string) bool {
+		return token.kind == tokenWord && strings.EqualFold(token.text, word)
+	}
+	var values []exprToken
+	switch {
+	case len(rest) == 4 && isWord(rest[0], "between") && isValue(rest[1]) && isWord(rest[2], "and") && isValue(rest[3]):
+		parsed.Operator = "between"
+		values = []exprToken{rest[1], rest[3]}
+	case len(rest) == 2 && rest[0].kind == tokenOperator && isValue(rest[1]):
+		parsed.Operator = rest[0].text
+		values = []exprToken{rest[1]}
+	default:
+		return nil, fmt.Errorf("invalid comparison '%s' (expected '<op> value' or 'between a and b')", comparison)
+	}
+
+	for _, value := range values {
+		if err := validateCheckValue(value, spec.valueKind); err != nil {
+			return nil, err
+		}
+		parsed.Values = append(parsed.Values, value.text)
+	}
+
+	return parsed, nil
+}
+
+// tokenizeCheckExpression splits the expression into words, quoted strings, comparison operators and parenthesized
+// groups. Quotes (', " and `) are honored inside groups, so nested calls and quoted commas stay within their group
+func tokenizeCheckExpression(expression string) ([]exprToken, error) {
+	var tokens []exprToken
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			end, err := matchingParen(expression, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, exprToken{kind: tokenGroup, text: expression[i+1 : end], pos: i})
+			i = end + 1
+		case c == ')':
+			return nil, fmt.Errorf("unbalanced parentheses in '%s'", expression)
+		case c == '\'':
+			end, err := closingQuote(expression, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, exprToken{kind: tokenString, text: expression[i : end+1], pos: i})
+			i = end + 1
+		case strings.IndexByte("<>=!", c) >= 0:
+			operator := ""
+			for _, op := range comparisonOperators {
+				if strings.HasPrefix(expression[i:], op) {
+					operator = op
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("invalid operator '%c' in '%s'", c, expression)
+			}
+			tokens = append(tokens, exprToken{kind: tokenOperator, text: operator, pos: i})
+			i += len(operator)
+		default:
+			start := i
+			for i < len(expression) && strings.IndexByte(" \t\n\r()'<>=!", expression[i]) < 0 {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokenWord, text: expression[start:i], pos: start})
+		}
+	}
+	return tokens, nil
+}
+
+// matchingParen returns the index of the parenthesis closing the one at open, skipping quoted parts
+func matchingParen(expression string, open int) (int, error) {
+	depth := 0
+	for i := open; i < len(expression); i++ {
+		switch expression[i] {
+		case '\'', '"', '`':
+			end, err := closingQuote(expression, i)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unbalanced parentheses in '%s'", expression)
+}
+
+// closingQuote returns the index of the quote closing the one at open, a doubled quote is an escaped one
+func closingQuote(expression string, open int) (int, error) {
+	quote := expression[open]
+	for i := open + 1; i < len(expression); i++ {
+		if expression[i] != quote {
+			continue
+		}
+		if i+1 < len(expression) && expression[i+1] == quote {
+			i++
+			continue
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("unterminated quote in '%s'", expression)
+}
+
+// splitArgs splits arguments by commas outside of quotes and parentheses, e.g. "col=coalesce(a, b), desc='x, y'"
+func splitArgs(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	var args []string
+	var quote rune
+	depth, start := 0, 0
+	for i, c := range value {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			args = append(args, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(args, value[start:])
+}
+
+func validateCheckValue(token exprToken, kind checkValueKind) error {
+	value := token.text
+	_, numErr := strconv.ParseFloat(value, 64)
+	switch kind {
+	case valueKindDuration:
+		if !durationRegex.MatchString(value) {
+			return fmt.Errorf("invalid duration '%s' (expected e.g. 30m, 12h or 7d)", value)
+		}
+	case valueKindNumberOrString:
+		if numErr != nil && token.kind != tokenString {
+			return fmt.Errorf("invalid value '%s' (expected a number or a quoted string)", value)
+		}
+	default:
+		if numErr != nil {
+			return fmt.Errorf("invalid value '%s' (expected a number)", value)
+		}
+	}
+	return nil
+}
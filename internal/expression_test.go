@@ -0,0 +1,139 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCheckExpression(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       *CheckExpression
+		wantErr    string
+	}{
+		{
+			expression: "row_count > 0",
+			want:       &CheckExpression{Function: "row_count", Operator: ">", Values: []string{"0"}},
+		},
+		{
+			expression: "ROW_COUNT between 1 AND 10",
+			want:       &CheckExpression{Function: "row_count", Operator: "between", Values: []string{"1", "10"}},
+		},
+		{
+			expression: "not_null(email)",
+			want:       &CheckExpression{Function: "not_null", Args: []string{"email"}},
+		},
+		{
+			expression: "not_null(coalesce(a, b))",
+			want:       &CheckExpression{Function: "not_null", Args: []string{"coalesce(a, b)"}},
+		},
+		{
+			expression: "uniqueness(concat(a, ',', b))",
+			want:       &CheckExpression{Function: "uniqueness", Args: []string{"concat(a, ',', b)"}},
+		},
+		{
+			expression: "max(created) < '2024-01-01 00:00:00'",
+			want:       &CheckExpression{Function: "max", Args: []string{"created"}, Operator: "<", Values: []string{"'2024-01-01 00:00:00'"}},
+		},
+		{
+			expression: "min(name)>='O''Hare'",
+			want:       &CheckExpression{Function: "min", Args: []string{"name"}, Operator: ">=", Values: []string{"'O''Hare'"}},
+		},
+		{
+			expression: "min(created) between '2024-01-01 00:00:00' and '2024-12-31 23:59:59'",
+			want: &CheckExpression{Function: "min", Args: []string{"created"}, Operator: "between",
+				Values: []string{"'2024-01-01 00:00:00'", "'2024-12-31 23:59:59'"}},
+		},
+		{
+			expression: "avg(price) <> -1.5",
+			want:       &CheckExpression{Function: "avg", Args: []string{"price"}, Operator: "<>", Values: []string{"-1.5"}},
+		},
+		{
+			expression: "freshness(updated_at) < 12h",
+			want:       &CheckExpression{Function: "freshness", Args: []string{"updated_at"}, Operator: "<", Values: []string{"12h"}},
+		},
+		{
+			expression: "foreign_key(dept_no) references mysql@employees.departments(dept_no)",
+			want: &CheckExpression{Function: "foreign_key", Args: []string{"dept_no"},
+				References: &ColumnReference{DataSource: "mysql", Dataset: "employees.departments", Column: "dept_no"}},
+		},
+		{
+			expression: "schema_check",
+			want:       &CheckExpression{Function: "schema_check"},
+		},
+		{expression: "", wantErr: "invalid check expression ''"},
+		{expression: "'row_count' > 0", wantErr: "invalid check expression"},
+		{expression: "bogus(a)", wantErr: "unknown check function 'bogus'"},
+		{expression: "not_null(a, b)", wantErr: "'not_null' expects 1 argument(s), got 2"},
+		{expression: "not_null()", wantErr: "'not_null' expects 1 argument(s), got 0"},
+		{expression: "not_null(a, )", wantErr: "empty argument in 'not_null(a, )'"},
+		{expression: "not_null(coalesce(a, b)", wantErr: "unbalanced parentheses"},
+		{expression: "not_null(a))", wantErr: "unbalanced parentheses"},
+		{expression: "max(a) < '2024", wantErr: "unterminated quote"},
+		{expression: "not_null(concat(a, ')'", wantErr: "unbalanced parentheses"},
+		{expression: "not_null(concat(a, ')))", wantErr: "unterminated quote"},
+		{expression: "row_count", wantErr: "'row_count' requires a comparison"},
+		{expression: "row_count > 0 and < 10", wantErr: "invalid comparison '> 0 and < 10'"},
+		{expression: "row_count between 1", wantErr: "invalid comparison 'between 1'"},
+		{expression: "row_count ! 1", wantErr: "invalid operator '!'"},
+		{expression: "avg(price) > 'x'", wantErr: "invalid value ''x'' (expected a number)"},
+		{expression: "max(price) > abc", wantErr: "invalid value 'abc' (expected a number or a quoted string)"},
+		{expression: "freshness(created) < 1 day", wantErr: "invalid comparison '< 1 day'"},
+		{expression: "freshness(created) < '1d'", wantErr: "invalid duration ''1d''"},
+		{expression: "schema_check > 1", wantErr: "'schema_check' doesn't support comparison"},
+		{expression: "foreign_key(dept_no) > 0", wantErr: "'foreign_key' requires a referenced column"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, err := ParseCheckExpression(tt.expression)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseCheckExpression() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCheckExpression() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCheckExpression() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"a", []string{"a"}},
+		{"a, b", []string{"a", " b"}},
+		{"coalesce(a, b), c", []string{"coalesce(a, b)", " c"}},
+		{"'x, y', \"a,b\", `c,d`", []string{"'x, y'", " \"a,b\"", " `c,d`"}},
+		{"'it''s, ok', b", []string{"'it''s, ok'", " b"}},
+	}
+
+	for _, tt := range tests {
+		if got := splitArgs(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MaskedValue replaces values of masked columns in failed rows samples
+	MaskedValue = "****"
+	// DuplicateCountColumn holds the number of rows sharing the key in uniqueness samples
+	DuplicateCountColumn = "duplicate_count"
+)
+
+// FailedRowsConfig holds dbq.yaml settings of failed rows samples collected by 'dbqctl check --failed-rows'
+type FailedRowsConfig struct {
+	// MaskColumns are case-insensitive glob patterns of columns (e.g. 'email' or 'pii_*') whose values are masked in samples
+	MaskColumns []string `mapstructure:"mask_columns" yaml:"mask_columns,omitempty"`
+}
+
+// FailedRows is a sample of rows violating a check, e.g. rows with nulls for not_null
+// or duplicated keys with their counts for uniqueness
+type FailedRows struct {
+	// Query fetched the sample, it's in the dialect of the data source
+	Query   string   `json:"query"`
+	Columns []string `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+var failedRowsCheckRegex = regexp.MustCompile(`^(not_null|uniqueness)\s*\(\s*([^()]+?)\s*\)$`)
+
+// failedRowsCheck parses checks failed rows can be sampled for, ok is false for any other check
+func failedRowsCheck(expression string) (function string, columns []string, ok bool) {
+	if foreignKey := parseForeignKeyCheck(expression); foreignKey != nil {
+		return CheckFuncForeignKey, foreignKey.Args, true
+	}
+	match := failedRowsCheckRegex.FindStringSubmatch(strings.TrimSpace(expression))
+	if match == nil {
+		return "", nil, false
+	}
+	for _, column := range strings.Split(match[2], ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+	return match[1], columns, len(columns) > 0
+}
+
+// SupportsFailedRows reports whether failed rows can be sampled for the check expression
+func SupportsFailedRows(expression string) bool {
+	_, _, ok := failedRowsCheck(expression)
+	return ok
+}
+
+// failedRowsQuery builds the query selecting up to limit rows violating the check, tableColumns are only needed
+// for not_null checks of mysql data sources (see tableColumnsQuery). The rows are returned as a single JSON array
+func failedRowsQuery(dataSourceType string, expression string, dataset string, where string, limit int, tableColumns []string) (string, error) {
+	function, columns, ok := failedRowsCheck(expression)
+	if !ok {
+		return "", fmt.Errorf("failed rows can't be sampled for check '%s'", expression)
+	}
+
+	var query, whereSql string
+	var resultColumns []string
+	switch function {
+	case CheckFuncNotNull:
+		var conditions []string
+		for _, column := range columns {
+			conditions = append(conditions, column+" IS NULL")
+		}
+		whereSql = "(" + strings.Join(conditions, " OR ") + ")"
+		if strings.TrimSpace(where) != "" {
+			whereSql += " AND (" + where + ")"
+		}
+		query = fmt.Sprintf("SELECT * FROM %s WHERE %s LIMIT %d", dataset, whereSql, limit)
+		resultColumns = tableColumns
+	case CheckFuncForeignKey:
+		query = foreignKeyOrphansQuery(parseForeignKeyCheck(expression), dataset, where, limit)
+		resultColumns = []string{columns[0], OrphanCountColumn}
+	case CheckFuncUniqueness:
+		if strings.TrimSpace(where) != "" {
+			whereSql = " WHERE " + where
+		}
+		keys := strings.Join(columns, ", ")
+		query = fmt.Sprintf("SELECT %s, COUNT(*) AS %s FROM %s%s GROUP BY %s HAVING COUNT(*) > 1 ORDER BY %s DESC LIMIT %d",
+			keys, DuplicateCountColumn, dataset, whereSql, keys, DuplicateCountColumn, limit)
+		resultColumns = append(append([]string{}, columns...), DuplicateCountColumn)
+	}
+
+	if strings.ToLower(dataSourceType) == "mysql" && len(resultColumns) == 0 {
+		return "", fmt.Errorf("columns of %s are unknown", dataset)
+	}
+	return jsonRowsQuery(dataSourceType, query, resultColumns)
+}
+
+// jsonRowsQuery wraps the query, so its rows are returned as a single JSON array of objects (see parseJsonRows).
+// The columns of the query are only needed for mysql, which can't convert rows to JSON objects as a whole
+func jsonRowsQuery(dataSourceType string, query string, columns []string) (string, error) {
+	switch strings.ToLower(dataSourceType) {
+	case "postgresql":
+		return fmt.Sprintf("SELECT COALESCE(json_agg(t), '[]'::json) FROM (%s) t", query), nil
+	case "clickhouse":
+		return fmt.Sprintf("SELECT concat('[', arrayStringConcat(groupArray(formatRowNoNewline('JSONEachRow', *)), ','), ']') FROM (%s)", query), nil
+	case "mysql":
+		var fields []string
+		for _, column := range columns {
+			// the key has to be the bare column name, e.g. 'id' for 't.id' or '`id`'
+			name := column[strings.LastIndex(column, ".")+1:]
+			fields = append(fields, fmt.Sprintf("'%s', t.%s", sqlString(strings.Trim(name, "`")), name))
+		}
+		return fmt.Sprintf("SELECT COALESCE(JSON_ARRAYAGG(JSON_OBJECT(%s)), JSON_ARRAY()) FROM (%s) t", strings.Join(fields, ", "), query), nil
+	default:
+		return "", fmt.Errorf("data source type '%s' is not supported", dataSourceType)
+	}
+}
+
+// tableColumnsQuery returns the query listing columns of the mysql table as a JSON array, or an empty query if
+// the failed rows query of the check doesn't need them
+func tableColumnsQuery(dataSourceType string, expression string, dataset string) string {
+	function, _, _ := failedRowsCheck(expression)
+	if strings.ToLower(dataSourceType) != "mysql" || function != CheckFuncNotNull {
+		return ""
+	}
+
+	schema := "DATABASE()"
+	table := dataset
+	if idx := strings.LastIndex(dataset, "."); idx >= 0 {
+		schema = "'" + sqlString(strings.Trim(dataset[:idx], "`")) + "'"
+		table = dataset[idx+1:]
+	}
+	return fmt.Sprintf("SELECT JSON_ARRAYAGG(column_name) FROM (SELECT column_name FROM information_schema.columns "+
+		"WHERE table_schema = %s AND table_name = '%s' ORDER BY ordinal_position) c", schema, sqlString(strings.Trim(table, "`")))
+}
+
+// parseJsonRows decodes the JSON array of row objects, columns keep the order of the first row
+func parseJsonRows(value string) (columns []string, rows [][]any, err error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+	decoder.UseNumber()
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, nil, fmt.Errorf("unexpected rows value: %s", truncate(value, 64))
+	}
+
+	columnIdx := make(map[string]int)
+	for decoder.More() {
+		if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+			return nil, nil, fmt.Errorf("unexpected rows value: %s", truncate(value, 64))
+		}
+		row := make([]any, len(columns))
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, nil, err
+			}
+			column, _ := token.(string)
+			var cell any
+			if err := decoder.Decode(&cell); err != nil {
+				return nil, nil, err
+			}
+
+			idx, ok := columnIdx[column]
+			if !ok {
+				idx = len(columns)
+				columnIdx[column] = idx
+				columns = append(columns, column)
+			}
+			for len(row) <= idx {
+				row = append(row, nil)
+			}
+			row[idx] = cell
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, nil, err
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, nil
+}
+
+// Mask replaces non-null values of columns matching any of the patterns with MaskedValue
+func (f *FailedRows) Mask(patterns []string) {
+	for colIdx, column := range f.Columns {
+		if !matchesAnyColumnPattern(column, patterns) {
+			continue
+		}
+		for _, row := range f.Rows {
+			if colIdx < len(row) && row[colIdx] != nil {
+				row[colIdx] = MaskedValue
+			}
+		}
+	}
+}
+
+func matchesAnyColumnPattern(column string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(column)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatJsonValue formats a value decoded by parseJsonRows for text outputs
+func FormatJsonValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
+
+func sqlString(value string) string {
+	return strings.ReplaceAll(value, "'", "''")
+}
+
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+	return value[:length] + "..."
+}
@@ -0,0 +1,224 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFailedRowsQuery(t *testing.T) {
+	tests := []struct {
+		name           string
+		dataSourceType string
+		expression     string
+		where          string
+		tableColumns   []string
+		want           string
+		wantErr        bool
+	}{
+		{
+			name:           "postgresql not_null",
+			dataSourceType: "postgresql",
+			expression:     "not_null(id, email)",
+			where:          "created_at > now() - interval '1 day'",
+			want: "SELECT COALESCE(json_agg(t), '[]'::json) FROM (SELECT * FROM public.users " +
+				"WHERE (id IS NULL OR email IS NULL) AND (created_at > now() - interval '1 day') LIMIT 5) t",
+		},
+		{
+			name:           "clickhouse uniqueness",
+			dataSourceType: "clickhouse",
+			expression:     "uniqueness( id , email )",
+			want: "SELECT concat('[', arrayStringConcat(groupArray(formatRowNoNewline('JSONEachRow', *)), ','), ']') FROM (" +
+				"SELECT id, email, COUNT(*) AS duplicate_count FROM public.users GROUP BY id, email " +
+				"HAVING COUNT(*) > 1 ORDER BY duplicate_count DESC LIMIT 5)",
+		},
+		{
+			name:           "mysql uniqueness",
+			dataSourceType: "MySQL",
+			expression:     "uniqueness(`id`)",
+			where:          "active = 1",
+			want: "SELECT COALESCE(JSON_ARRAYAGG(JSON_OBJECT('id', t.`id`, 'duplicate_count', t.duplicate_count)), JSON_ARRAY()) FROM (" +
+				"SELECT `id`, COUNT(*) AS duplicate_count FROM public.users WHERE active = 1 GROUP BY `id` " +
+				"HAVING COUNT(*) > 1 ORDER BY duplicate_count DESC LIMIT 5) t",
+		},
+		{
+			name:           "mysql not_null with table columns",
+			dataSourceType: "mysql",
+			expression:     "not_null(email)",
+			tableColumns:   []string{"id", "email"},
+			want: "SELECT COALESCE(JSON_ARRAYAGG(JSON_OBJECT('id', t.id, 'email', t.email)), JSON_ARRAY()) FROM (" +
+				"SELECT * FROM public.users WHERE (email IS NULL) LIMIT 5) t",
+		},
+		{
+			name:           "mysql not_null without table columns",
+			dataSourceType: "mysql",
+			expression:     "not_null(email)",
+			wantErr:        true,
+		},
+		{
+			name:           "unsupported check",
+			dataSourceType: "postgresql",
+			expression:     "row_count > 0",
+			wantErr:        true,
+		},
+		{
+			name:           "unsupported data source type",
+			dataSourceType: "sqlite",
+			expression:     "not_null(id)",
+			wantErr:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := failedRowsQuery(tt.dataSourceType, tt.expression, "public.users", tt.where, 5, tt.tableColumns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("failedRowsQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("failedRowsQuery() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJsonRowsQueryMysqlColumnNames(t *testing.T) {
+	got, err := jsonRowsQuery("mysql", "SELECT 1", []string{"t.id", "`it's`", "u.`name`"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SELECT COALESCE(JSON_ARRAYAGG(JSON_OBJECT('id', t.id, 'it''s', t.`it's`, 'name', t.`name`)), JSON_ARRAY()) FROM (SELECT 1) t"
+	if got != want {
+		t.Errorf("jsonRowsQuery() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTableColumnsQuery(t *testing.T) {
+	tests := []struct {
+		name           string
+		dataSourceType string
+		expression     string
+		dataset        string
+		want           string
+	}{
+		{
+			name:           "qualified table",
+			dataSourceType: "mysql",
+			expression:     "not_null(id)",
+			dataset:        "`shop`.`orders`",
+			want: "SELECT JSON_ARRAYAGG(column_name) FROM (SELECT column_name FROM information_schema.columns " +
+				"WHERE table_schema = 'shop' AND table_name = 'orders' ORDER BY ordinal_position) c",
+		},
+		{
+			name:           "unqualified table",
+			dataSourceType: "mysql",
+			expression:     "not_null(id)",
+			dataset:        "orders",
+			want: "SELECT JSON_ARRAYAGG(column_name) FROM (SELECT column_name FROM information_schema.columns " +
+				"WHERE table_schema = DATABASE() AND table_name = 'orders' ORDER BY ordinal_position) c",
+		},
+		{name: "uniqueness", dataSourceType: "mysql", expression: "uniqueness(id)", dataset: "orders"},
+		{name: "postgresql", dataSourceType: "postgresql", expression: "not_null(id)", dataset: "orders"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tableColumnsQuery(tt.dataSourceType, tt.expression, tt.dataset); got != tt.want {
+				t.Errorf("tableColumnsQuery() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJsonRows(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantColumns []string
+		wantRows    [][]any
+		wantErr     bool
+	}{
+		{name: "empty", value: "[]"},
+		{
+			name:        "same columns",
+			value:       `[{"id": 1, "email": null}, {"id": 2, "email": "a@b.c"}]`,
+			wantColumns: []string{"id", "email"},
+			wantRows:    [][]any{{json.Number("1"), nil}, {json.Number("2"), "a@b.c"}},
+		},
+		{
+			name:        "reordered columns",
+			value:       `[{"id": 1, "email": "x"}, {"email": "y", "id": 2}]`,
+			wantColumns: []string{"id", "email"},
+			wantRows:    [][]any{{json.Number("1"), "x"}, {json.Number("2"), "y"}},
+		},
+		{
+			name:        "missing and new columns",
+			value:       `[{"id": 1}, {"email": "y"}, {"id": 3, "tags": ["a"]}]`,
+			wantColumns: []string{"id", "email", "tags"},
+			wantRows:    [][]any{{json.Number("1")}, {nil, "y"}, {json.Number("3"), nil, []any{"a"}}},
+		},
+		{name: "not an array", value: `{"id": 1}`, wantErr: true},
+		{name: "not an object", value: `[1]`, wantErr: true},
+		{name: "truncated", value: `[{"id": 1}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, rows, err := parseJsonRows(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseJsonRows() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(columns, tt.wantColumns) {
+				t.Errorf("columns = %v, want %v", columns, tt.wantColumns)
+			}
+			if !reflect.DeepEqual(rows, tt.wantRows) {
+				t.Errorf("rows = %v, want %v", rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func TestFailedRowsMask(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		want     [][]any
+	}{
+		{name: "no patterns", want: [][]any{{"1", "a@b.c", "x", "Ann"}, {"2", nil, "y"}}},
+		{name: "exact column, case-insensitive", patterns: []string{"EMAIL"}, want: [][]any{{"1", MaskedValue, "x", "Ann"}, {"2", nil, "y"}}},
+		{name: "glob", patterns: []string{"pii_*"}, want: [][]any{{"1", "a@b.c", MaskedValue, MaskedValue}, {"2", nil, MaskedValue}}},
+		{name: "several patterns", patterns: []string{"id", "pii_?ame"}, want: [][]any{{MaskedValue, "a@b.c", "x", MaskedValue}, {MaskedValue, nil, "y"}}},
+		{name: "no match", patterns: []string{"pii"}, want: [][]any{{"1", "a@b.c", "x", "Ann"}, {"2", nil, "y"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the second row misses the trailing column, as parseJsonRows returns rows missing later columns
+			rows := &FailedRows{
+				Columns: []string{"id", "email", "pii_phone", "pii_name"},
+				Rows:    [][]any{{"1", "a@b.c", "x", "Ann"}, {"2", nil, "y"}},
+			}
+			rows.Mask(tt.patterns)
+			if !reflect.DeepEqual(rows.Rows, tt.want) {
+				t.Errorf("Mask() rows = %v, want %v", rows.Rows, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// OrphanCountColumn holds the number of rows sharing the orphaned key in foreign_key samples
+	OrphanCountColumn = "orphan_count"
+	// DefaultOrphansSample is the number of orphaned keys sampled for failed foreign_key checks,
+	// unless --failed-rows or 'sample_failures' is set
+	DefaultOrphansSample = 10
+)
+
+// parseForeignKeyCheck returns the parsed foreign_key check, nil for any other (or invalid) check
+func parseForeignKeyCheck(expression string) *CheckExpression {
+	parsed, err := ParseCheckExpression(expression)
+	if err != nil || parsed.Function != CheckFuncForeignKey {
+		return nil
+	}
+	return parsed
+}
+
+// IsForeignKeyCheck reports whether the expression is a foreign_key check, which is run by dbqctl rather than dbqcore
+func IsForeignKeyCheck(expression string) bool {
+	return parseForeignKeyCheck(expression) != nil
+}
+
+// CheckDataSource verifies the referenced dataset is on the given data source, as both datasets are queried together
+func (r *ColumnReference) CheckDataSource(dataSourceId string) error {
+	if r.DataSource != "" && r.DataSource != dataSourceId {
+		return fmt.Errorf("foreign_key references '%s@%s' on another data source than '%s', both datasets must be on the same data source",
+			r.DataSource, r.Dataset, dataSourceId)
+	}
+	return nil
+}
+
+// orphansClause is the FROM clause selecting rows of the dataset whose key is missing in the referenced dataset.
+// NOT IN is used as it's supported by all data sources, nulls are excluded on both sides to keep it exact
+func orphansClause(foreignKey *CheckExpression, dataset string, where string) string {
+	column, ref := foreignKey.Column(), foreignKey.References
+	clause := fmt.Sprintf("FROM %s WHERE %s IS NOT NULL AND %s NOT IN (SELECT %s FROM %s WHERE %s IS NOT NULL)",
+		dataset, column, column, ref.Column, ref.Dataset, ref.Column)
+	if strings.TrimSpace(where) != "" {
+		clause += " AND (" + where + ")"
+	}
+	return clause
+}
+
+// foreignKeyQuery counts the orphaned rows of the dataset, the check passes if there are none
+func foreignKeyQuery(foreignKey *CheckExpression, dataset string, where string) string {
+	return "SELECT COUNT(*) " + orphansClause(foreignKey, dataset, where)
+}
+
+// foreignKeyOrphansQuery selects up to limit orphaned keys with the number of rows referencing them
+func foreignKeyOrphansQuery(foreignKey *CheckExpression, dataset string, where string, limit int) string {
+	column := foreignKey.Column()
+	return fmt.Sprintf("SELECT %s, COUNT(*) AS %s %s GROUP BY %s ORDER BY %s DESC LIMIT %d",
+		column, OrphanCountColumn, orphansClause(foreignKey, dataset, where), column, OrphanCountColumn, limit)
+}
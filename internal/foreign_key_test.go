@@ -0,0 +1,133 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DataBridgeTech/dbqcore"
+)
+
+func TestForeignKeyQueries(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		where      string
+		wantCount  string
+		wantSample string
+	}{
+		{
+			name:       "unqualified reference",
+			expression: "foreign_key(dept_no) references employees.departments(dept_no)",
+			wantCount: "SELECT COUNT(*) FROM employees.dept_emp WHERE dept_no IS NOT NULL AND dept_no NOT IN " +
+				"(SELECT dept_no FROM employees.departments WHERE dept_no IS NOT NULL)",
+			wantSample: "SELECT dept_no, COUNT(*) AS orphan_count FROM employees.dept_emp WHERE dept_no IS NOT NULL AND dept_no NOT IN " +
+				"(SELECT dept_no FROM employees.departments WHERE dept_no IS NOT NULL) GROUP BY dept_no ORDER BY orphan_count DESC LIMIT 10",
+		},
+		{
+			name:       "qualified reference with where",
+			expression: "foreign_key(manager) references pg@employees.employees(emp_no)",
+			where:      "to_date > '2000-01-01'",
+			wantCount: "SELECT COUNT(*) FROM employees.dept_emp WHERE manager IS NOT NULL AND manager NOT IN " +
+				"(SELECT emp_no FROM employees.employees WHERE emp_no IS NOT NULL) AND (to_date > '2000-01-01')",
+			wantSample: "SELECT manager, COUNT(*) AS orphan_count FROM employees.dept_emp WHERE manager IS NOT NULL AND manager NOT IN " +
+				"(SELECT emp_no FROM employees.employees WHERE emp_no IS NOT NULL) AND (to_date > '2000-01-01') " +
+				"GROUP BY manager ORDER BY orphan_count DESC LIMIT 10",
+		},
+		{
+			name:       "blank where",
+			expression: "foreign_key(dept_no) references employees.departments(dept_no)",
+			where:      "  ",
+			wantCount: "SELECT COUNT(*) FROM employees.dept_emp WHERE dept_no IS NOT NULL AND dept_no NOT IN " +
+				"(SELECT dept_no FROM employees.departments WHERE dept_no IS NOT NULL)",
+			wantSample: "SELECT dept_no, COUNT(*) AS orphan_count FROM employees.dept_emp WHERE dept_no IS NOT NULL AND dept_no NOT IN " +
+				"(SELECT dept_no FROM employees.departments WHERE dept_no IS NOT NULL) GROUP BY dept_no ORDER BY orphan_count DESC LIMIT 10",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			foreignKey := parseForeignKeyCheck(tt.expression)
+			if foreignKey == nil {
+				t.Fatalf("parseForeignKeyCheck(%s) = nil", tt.expression)
+			}
+			if got := foreignKeyQuery(foreignKey, "employees.dept_emp", tt.where); got != tt.wantCount {
+				t.Errorf("foreignKeyQuery() =\n%s\nwant\n%s", got, tt.wantCount)
+			}
+			if got := foreignKeyOrphansQuery(foreignKey, "employees.dept_emp", tt.where, DefaultOrphansSample); got != tt.wantSample {
+				t.Errorf("foreignKeyOrphansQuery() =\n%s\nwant\n%s", got, tt.wantSample)
+			}
+		})
+	}
+}
+
+func TestIsForeignKeyCheck(t *testing.T) {
+	tests := map[string]bool{
+		"foreign_key(dept_no) references employees.departments(dept_no)": true,
+		"foreign_key(dept_no) > 0":                                       false,
+		"not_null(dept_no)":                                              false,
+		"row_count > 0":                                                  false,
+	}
+	for expression, want := range tests {
+		if got := IsForeignKeyCheck(expression); got != want {
+			t.Errorf("IsForeignKeyCheck(%s) = %v, want %v", expression, got, want)
+		}
+	}
+}
+
+func TestForeignKeyOtherDataSource(t *testing.T) {
+	tests := []struct {
+		expression string
+		wantErr    bool
+	}{
+		{expression: "foreign_key(dept_no) references employees.departments(dept_no)"},
+		{expression: "foreign_key(dept_no) references pg@employees.departments(dept_no)"},
+		{expression: "foreign_key(dept_no) references mysql@employees.departments(dept_no)", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			err := parseForeignKeyCheck(tt.expression).References.CheckDataSource("pg")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckDataSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// the check is rejected when loading the checks file and when running or rendering it
+			dir := writeChecksFiles(t, map[string]string{
+				"checks.yaml": "version: \"1\"\nrules:\n  - dataset: pg@[employees.dept_emp]\n    checks:\n      - " + tt.expression + "\n",
+			})
+			if _, err := LoadChecksFile(filepath.Join(dir, "checks.yaml"), false); (err != nil) != tt.wantErr {
+				t.Errorf("LoadChecksFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+
+			app := &DbqAppImpl{}
+			dataSource := &dbqcore.DataSource{ID: "pg", Type: "postgresql"}
+			check := &dbqcore.DataQualityCheck{Expression: tt.expression}
+			if _, err := app.RenderCheckQuery(context.Background(), check, dataSource, "employees.dept_emp", ""); err == nil {
+				t.Error("RenderCheckQuery() didn't fail")
+			}
+			result := app.runForeignKeyCheck(context.Background(), nil, parseForeignKeyCheck(tt.expression), dataSource, "employees.dept_emp", "")
+			if result.Pass || !strings.Contains(result.Error, "another data source") {
+				t.Errorf("runForeignKeyCheck() = %+v, want the reference rejected", result)
+			}
+		})
+	}
+}
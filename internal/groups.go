@@ -0,0 +1,187 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/DataBridgeTech/dbqcore"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultMaxGroups is the number of groups a grouped check is evaluated for unless --max-groups is set
+const DefaultMaxGroups = 100
+
+var integerRegex = regexp.MustCompile(`^-?[0-9]+$`)
+
+// stringList is a list of strings which can also be written as a single string, e.g. 'group_by: county'
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(value *yaml.Node) error {
+	switch {
+	case value.Tag == "!!null":
+		*l = stringList{}
+		return nil
+	case value.Kind == yaml.ScalarNode:
+		*l = stringList{value.Value}
+		return nil
+	default:
+		var items []string
+		if err := value.Decode(&items); err != nil {
+			return err
+		}
+		*l = items
+		return nil
+	}
+}
+
+// groupExpressions combines 'group_by' expressions with the 'partition_by' one
+func groupExpressions(groupBy []string, partitionBy string) ([]string, error) {
+	var expressions []string
+	for _, expression := range groupBy {
+		if strings.TrimSpace(expression) == "" {
+			return nil, fmt.Errorf("group_by expressions can't be empty")
+		}
+		expressions = append(expressions, strings.TrimSpace(expression))
+	}
+	if partitionBy = strings.TrimSpace(partitionBy); partitionBy != "" {
+		expressions = append(expressions, partitionBy)
+	}
+	return expressions, nil
+}
+
+// SupportsGrouping reports whether the check can be evaluated per group, schema_check and raw_query can't
+// as they don't apply the rule 'where'
+func SupportsGrouping(expression string) bool {
+	expr, err := ParseCheckExpression(expression)
+	return err == nil && expr.Function != CheckFuncSchemaCheck && expr.Function != CheckFuncRawQuery
+}
+
+// CheckGroup is a group of dataset rows sharing the values of the group expressions of a grouped check
+type CheckGroup struct {
+	// Key maps every group expression to its value in the group
+	Key map[string]string
+	// Where selects the rows of the group, it's in the dialect of the data source
+	Where string
+}
+
+// Label formats the group key in the order of the group expressions, e.g. 'county=Kent, year=2024'
+func (g *CheckGroup) Label(groupBy []string) string {
+	parts := make([]string, len(groupBy))
+	for i, expression := range groupBy {
+		parts[i] = expression + "=" + g.Key[expression]
+	}
+	return strings.Join(parts, ", ")
+}
+
+// CombineWhere restricts the rule 'where' with the condition of a group
+func CombineWhere(where string, condition string) string {
+	if strings.TrimSpace(where) == "" {
+		return condition
+	}
+	return fmt.Sprintf("(%s) AND (%s)", where, condition)
+}
+
+// ListCheckGroups returns up to limit distinct groups of the dataset rows matching defaultWhere, ordered by their keys
+func (app *DbqAppImpl) ListCheckGroups(ctx context.Context, dataSource *dbqcore.DataSource, dataset string, defaultWhere string, groupBy []string, limit int) ([]CheckGroup, error) {
+	ctx = WithLogAttrs(ctx,
+		slog.String("datasource", dataSource.ID),
+		slog.String("dataset", dataset))
+	adapter, err := app.connections.Adapter(ctx, dataSource)
+	if err != nil {
+		return nil, app.redactErr(err)
+	}
+
+	query, aliases := checkGroupsQuery(dataset, defaultWhere, groupBy, limit)
+	jsonQuery, err := jsonRowsQuery(dataSource.Type, query, aliases)
+	if err != nil {
+		return nil, err
+	}
+
+	app.logger.DebugContext(ctx, "Listing check groups", "query", jsonQuery)
+	value, err := adapter.ExecuteQuery(ctx, jsonQuery)
+	if err != nil {
+		return nil, app.redactErr(err)
+	}
+	columns, rows, err := parseJsonRows(value)
+	if err != nil {
+		return nil, err
+	}
+
+	columnIdx := make(map[string]int, len(columns))
+	for i, column := range columns {
+		columnIdx[column] = i
+	}
+	groups := make([]CheckGroup, 0, len(rows))
+	for _, row := range rows {
+		group := CheckGroup{Key: make(map[string]string, len(groupBy))}
+		conditions := make([]string, len(groupBy))
+		for i, expression := range groupBy {
+			var groupValue any
+			if idx, ok := columnIdx[aliases[i]]; ok && idx < len(row) {
+				groupValue = row[idx]
+			}
+			condition, err := groupCondition(expression, groupValue)
+			if err != nil {
+				return nil, err
+			}
+			conditions[i] = condition
+			group.Key[expression] = FormatJsonValue(groupValue)
+		}
+		group.Where = strings.Join(conditions, " AND ")
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// checkGroupsQuery lists distinct values of the group expressions, aliased as g0, g1, ...
+func checkGroupsQuery(dataset string, where string, groupBy []string, limit int) (string, []string) {
+	aliases := make([]string, len(groupBy))
+	selects := make([]string, len(groupBy))
+	for i, expression := range groupBy {
+		aliases[i] = fmt.Sprintf("g%d", i)
+		selects[i] = fmt.Sprintf("%s AS %s", expression, aliases[i])
+	}
+	query := fmt.Sprintf("SELECT DISTINCT %s FROM %s%s ORDER BY %s LIMIT %d",
+		strings.Join(selects, ", "), dataset, whereClause(where), strings.Join(aliases, ", "), limit)
+	return query, aliases
+}
+
+// groupCondition selects rows where the group expression has the given value, strings are compared
+// as literals, so dates and timestamps returned as strings are converted by the database. Non-integer
+// numbers are rejected, they may not compare equal once formatted and the group would silently match no rows
+func groupCondition(expression string, value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return fmt.Sprintf("(%s) IS NULL", expression), nil
+	case json.Number:
+		if !integerRegex.MatchString(v.String()) {
+			return "", fmt.Errorf("group_by expression '%s' has non-integer numeric values, e.g. %s, which can't be compared exactly, "+
+				"group by a rounded or text expression instead", expression, v.String())
+		}
+		return fmt.Sprintf("(%s) = %s", expression, v.String()), nil
+	case string:
+		return fmt.Sprintf("(%s) = '%s'", expression, sqlString(v)), nil
+	case bool:
+		return fmt.Sprintf("(%s) = %t", expression, v), nil
+	default:
+		return "", fmt.Errorf("group_by expression '%s' has unsupported values, e.g. %s", expression, truncate(FormatJsonValue(v), 64))
+	}
+}
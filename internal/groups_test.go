@@ -0,0 +1,149 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestCheckGroupsQuery(t *testing.T) {
+	tests := []struct {
+		dataSourceType string
+		dataset        string
+		where          string
+		groupBy        []string
+		want           string
+	}{
+		{
+			dataSourceType: "postgresql",
+			dataset:        "public.orders",
+			groupBy:        []string{"county"},
+			want:           "SELECT COALESCE(json_agg(t), '[]'::json) FROM (SELECT DISTINCT county AS g0 FROM public.orders ORDER BY g0 LIMIT 11) t",
+		},
+		{
+			dataSourceType: "clickhouse",
+			dataset:        "nyc.trips",
+			where:          "fare > 0",
+			groupBy:        []string{"vendor_id", "toDate(pickup_datetime)"},
+			want: "SELECT concat('[', arrayStringConcat(groupArray(formatRowNoNewline('JSONEachRow', *)), ','), ']') FROM " +
+				"(SELECT DISTINCT vendor_id AS g0, toDate(pickup_datetime) AS g1 FROM nyc.trips WHERE fare > 0 ORDER BY g0, g1 LIMIT 11)",
+		},
+		{
+			dataSourceType: "mysql",
+			dataset:        "employees.salaries",
+			where:          "to_date > now()",
+			groupBy:        []string{"emp_no", "YEAR(from_date)"},
+			want: "SELECT COALESCE(JSON_ARRAYAGG(JSON_OBJECT('g0', t.g0, 'g1', t.g1)), JSON_ARRAY()) FROM " +
+				"(SELECT DISTINCT emp_no AS g0, YEAR(from_date) AS g1 FROM employees.salaries WHERE to_date > now() ORDER BY g0, g1 LIMIT 11) t",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dataSourceType, func(t *testing.T) {
+			query, aliases := checkGroupsQuery(tt.dataset, tt.where, tt.groupBy, 11)
+			if len(aliases) != len(tt.groupBy) {
+				t.Fatalf("got %d aliases, want %d", len(aliases), len(tt.groupBy))
+			}
+			got, err := jsonRowsQuery(tt.dataSourceType, query, aliases)
+			if err != nil {
+				t.Fatalf("jsonRowsQuery() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("query mismatch\n got: %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCombineWhere(t *testing.T) {
+	tests := []struct {
+		where     string
+		condition string
+		want      string
+	}{
+		{"", "(county) = 'Kent'", "(county) = 'Kent'"},
+		{"  ", "(county) IS NULL", "(county) IS NULL"},
+		{"a = 1 OR b = 2", "(county) = 'Kent'", "(a = 1 OR b = 2) AND ((county) = 'Kent')"},
+	}
+
+	for _, tt := range tests {
+		if got := CombineWhere(tt.where, tt.condition); got != tt.want {
+			t.Errorf("CombineWhere(%q, %q) = %q, want %q", tt.where, tt.condition, got, tt.want)
+		}
+	}
+}
+
+func TestGroupCondition(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   any
+		want    string
+		wantErr bool
+	}{
+		{"null", nil, "(county) IS NULL", false},
+		{"number", json.Number("12"), "(county) = 12", false},
+		{"negative number", json.Number("-7"), "(county) = -7", false},
+		{"float", json.Number("12.5"), "", true},
+		{"decimal with zero fraction", json.Number("12.00"), "", true},
+		{"exponent", json.Number("1e3"), "", true},
+		{"string", "Kent", "(county) = 'Kent'", false},
+		{"quoted string", "O'Hare", "(county) = 'O''Hare'", false},
+		{"bool", true, "(county) = true", false},
+		{"array", []any{"a"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := groupCondition("county", tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("groupCondition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("groupCondition() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringListUnmarshal(t *testing.T) {
+	tests := []struct {
+		yaml string
+		want []string
+	}{
+		{"group_by: county", []string{"county"}},
+		{"group_by: [county, year]", []string{"county", "year"}},
+		{"group_by:", []string{}},
+	}
+
+	for _, tt := range tests {
+		var value struct {
+			GroupBy stringList `yaml:"group_by"`
+		}
+		if err := yaml.Unmarshal([]byte(tt.yaml), &value); err != nil {
+			t.Fatalf("%q: %v", tt.yaml, err)
+		}
+		if len(value.GroupBy) != len(tt.want) {
+			t.Fatalf("%q: got %v, want %v", tt.yaml, value.GroupBy, tt.want)
+		}
+		for i := range tt.want {
+			if value.GroupBy[i] != tt.want[i] {
+				t.Errorf("%q: got %v, want %v", tt.yaml, value.GroupBy, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	historyRunsBucket    = []byte("runs")
+	historyResultsBucket = []byte("results")
+	historyChecksBucket  = []byte("checks")
+)
+
+// HistoryRun is a single 'check' run persisted in the history store
+type HistoryRun struct {
+	ID             string    `json:"id"`
+	StartedAt      time.Time `json:"started_at"`
+	ChecksFile     string    `json:"checks_file"`
+	ChecksFileHash string    `json:"checks_file_hash"`
+	Environment    string    `json:"environment,omitempty"`
+	Passed         int       `json:"passed"`
+	Failed         int       `json:"failed"`
+	Errored        int       `json:"errored,omitempty"`
+	Skipped        int       `json:"skipped,omitempty"`
+	Interrupted    bool      `json:"interrupted,omitempty"`
+	DurationMs     int64     `json:"duration_ms"`
+}
+
+// HistoryCheckResult is the persisted outcome of one check within a run
+type HistoryCheckResult struct {
+	RunID       string    `json:"run_id"`
+	Timestamp   time.Time `json:"timestamp"`
+	CheckKey    string    `json:"check_key"`
+	DataSource  string    `json:"datasource"`
+	Dataset     string    `json:"dataset"`
+	Expression  string    `json:"expression"`
+	Description string    `json:"description,omitempty"`
+	OnFail      string    `json:"on_fail"`
+	Pass        bool      `json:"pass"`
+	ActualValue string    `json:"actual_value,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int64     `json:"duration_ms"`
+}
+
+// HistoryStore keeps check results of every run in a local bbolt database file.
+// Runs are keyed by time-ordered ids, results are additionally indexed by check key to query trends
+type HistoryStore struct {
+	db *bolt.DB
+}
+
+func OpenHistoryStore(path string) (*HistoryStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history store '%s': %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{historyRunsBucket, historyResultsBucket, historyChecksBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &HistoryStore{db: db}, nil
+}
+
+func (h *HistoryStore) Close() error {
+	return h.db.Close()
+}
+
+// NewHistoryRunId generates a run id which sorts in chronological order
+func NewHistoryRunId(startedAt time.Time) string {
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+	return startedAt.UTC().Format("20060102T150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// HistoryCheckKey identifies the same check across runs, checks run in different environments have different keys
+func HistoryCheckKey(environment string, dataSource string, dataset string, expression string, description string) string {
+	parts := []string{dataSource, dataset, expression, description}
+	if environment != "" {
+		// keeps keys of checks run without an environment unchanged
+		parts = append(parts, environment)
+	}
+
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+func (h *HistoryStore) SaveRun(run *HistoryRun, results []HistoryCheckResult) error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		runData, err := json.Marshal(run)
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(historyRunsBucket).Put([]byte(run.ID), runData); err != nil {
+			return err
+		}
+
+		resultsBucket := tx.Bucket(historyResultsBucket)
+		checksBucket := tx.Bucket(historyChecksBucket)
+		for i := range results {
+			result := &results[i]
+			result.RunID = run.ID
+
+			resultData, err := json.Marshal(result)
+			if err != nil {
+				return err
+			}
+
+			resultKey := []byte(fmt.Sprintf("%s/%06d", run.ID, i))
+			if err := resultsBucket.Put(resultKey, resultData); err != nil {
+				return err
+			}
+
+			if err := checksBucket.Put([]byte(result.CheckKey+"/"+string(resultKey)), resultKey); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ListRuns returns up to limit most recent runs, newest first
+func (h *HistoryStore) ListRuns(limit int) ([]HistoryRun, error) {
+	var runs []HistoryRun
+	err := h.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(historyRunsBucket).Cursor()
+		for k, v := cursor.Last(); k != nil && (limit <= 0 || len(runs) < limit); k, v = cursor.Prev() {
+			var run HistoryRun
+			if err := json.Unmarshal(v, &run); err != nil {
+				return err
+			}
+			runs = append(runs, run)
+		}
+		return nil
+	})
+	return runs, err
+}
+
+// GetRun returns the run with all its check results, 'latest' can be used instead of the run id
+func (h *HistoryStore) GetRun(runId string) (*HistoryRun, []HistoryCheckResult, error) {
+	var run *HistoryRun
+	var results []HistoryCheckResult
+
+	err := h.db.View(func(tx *bolt.Tx) error {
+		var runData []byte
+		if runId == "latest" {
+			_, runData = tx.Bucket(historyRunsBucket).Cursor().Last()
+		} else {
+			runData = tx.Bucket(historyRunsBucket).Get([]byte(runId))
+		}
+		if runData == nil {
+			return fmt.Errorf("run not found: %s", runId)
+		}
+
+		run = &HistoryRun{}
+		if err := json.Unmarshal(runData, run); err != nil {
+			return err
+		}
+
+		prefix := []byte(run.ID + "/")
+		cursor := tx.Bucket(historyResultsBucket).Cursor()
+		for k, v := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+			var result HistoryCheckResult
+			if err := json.Unmarshal(v, &result); err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return run, results, nil
+}
+
+// CheckTrend returns up to limit most recent results of the check, oldest first
+func (h *HistoryStore) CheckTrend(checkKey string, limit int) ([]HistoryCheckResult, error) {
+	var results []HistoryCheckResult
+	err := h.db.View(func(tx *bolt.Tx) error {
+		resultsBucket := tx.Bucket(historyResultsBucket)
+		prefix := []byte(checkKey + "/")
+
+		var resultKeys [][]byte
+		cursor := tx.Bucket(historyChecksBucket).Cursor()
+		for k, v := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+			resultKeys = append(resultKeys, v)
+		}
+		if limit > 0 && len(resultKeys) > limit {
+			resultKeys = resultKeys[len(resultKeys)-limit:]
+		}
+
+		for _, resultKey := range resultKeys {
+			resultData := resultsBucket.Get(resultKey)
+			if resultData == nil {
+				continue
+			}
+
+			var result HistoryCheckResult
+			if err := json.Unmarshal(resultData, &result); err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	return results, err
+}
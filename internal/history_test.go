@@ -0,0 +1,142 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryStore(t *testing.T) {
+	store, err := OpenHistoryStore(filepath.Join(t.TempDir(), "nested", "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	rowCount := HistoryCheckKey("", "pg", "public.orders", "row_count > 0", "")
+	nulls := HistoryCheckKey("", "pg", "public.orders", "not_null(id)", "")
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var runIds []string
+	for i := 0; i < 3; i++ {
+		startedAt := start.Add(time.Duration(i) * time.Hour)
+		run := &HistoryRun{ID: NewHistoryRunId(startedAt), StartedAt: startedAt, ChecksFile: "checks.yaml", Passed: 2}
+		results := []HistoryCheckResult{
+			{Timestamp: startedAt, CheckKey: rowCount, Expression: "row_count > 0", Pass: true, ActualValue: string(rune('1' + i))},
+			{Timestamp: startedAt, CheckKey: nulls, Expression: "not_null(id)", Pass: true},
+		}
+		if err := store.SaveRun(run, results); err != nil {
+			t.Fatal(err)
+		}
+		runIds = append(runIds, run.ID)
+	}
+
+	runs, err := store.ListRuns(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].ID != runIds[2] || runs[1].ID != runIds[1] {
+		t.Errorf("ListRuns(2) = %+v, want the two newest runs, newest first", runs)
+	}
+	if all, _ := store.ListRuns(0); len(all) != 3 {
+		t.Errorf("ListRuns(0) returned %d runs, want all 3", len(all))
+	}
+
+	for _, runId := range []string{runIds[2], "latest"} {
+		run, results, err := store.GetRun(runId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if run.ID != runIds[2] || len(results) != 2 || results[0].RunID != runIds[2] || results[1].CheckKey != nulls {
+			t.Errorf("GetRun(%s) = %+v, %+v", runId, run, results)
+		}
+	}
+	if _, _, err := store.GetRun("20990101T000000-000000"); err == nil {
+		t.Error("GetRun() of an unknown run didn't fail")
+	}
+
+	trend, err := store.CheckTrend(rowCount, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trend) != 2 || trend[0].ActualValue != "2" || trend[1].ActualValue != "3" {
+		t.Errorf("CheckTrend() = %+v, want the 2 latest results oldest first", trend)
+	}
+	if other, _ := store.CheckTrend(HistoryCheckKey("prod", "pg", "public.orders", "row_count > 0", ""), 0); len(other) != 0 {
+		t.Errorf("results of other environments leaked into the trend: %+v", other)
+	}
+}
+
+func TestHistoryStorePath(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "single file",
+			files: map[string]string{
+				"base/dbq.yaml": "version: \"1\"\nhistory:\n  path: ./h.db\n",
+			},
+			want: "base/h.db",
+		},
+		{
+			name: "set by the later file",
+			files: map[string]string{
+				"base/dbq.yaml":    "version: \"1\"\n",
+				"local/local.yaml": "history:\n  path: ./h.db\n",
+			},
+			want: "local/h.db",
+		},
+		{
+			name: "set by the earlier file only",
+			files: map[string]string{
+				"base/dbq.yaml":    "version: \"1\"\nhistory:\n  path: ./h.db\n",
+				"local/local.yaml": "history:\n  enabled: true\n",
+			},
+			want: "base/h.db",
+		},
+		{
+			name: "overridden by the later file",
+			files: map[string]string{
+				"base/dbq.yaml":    "version: \"1\"\nhistory:\n  path: ./base.db\n",
+				"local/local.yaml": "history:\n  path: ./h.db\n",
+			},
+			want: "local/h.db",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeChecksFiles(t, tt.files)
+			paths := []string{filepath.Join(dir, "base", "dbq.yaml")}
+			if _, ok := tt.files["local/local.yaml"]; ok {
+				paths = append(paths, filepath.Join(dir, "local", "local.yaml"))
+			}
+
+			app := NewDbqCliApp(AppOptions{ConfigFiles: paths}).(*DbqAppImpl)
+			t.Cleanup(func() { _ = app.Close() })
+			if _, err := app.GetHistoryStore(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, tt.want)); err != nil {
+				t.Errorf("history store wasn't created at %s: %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SecretResolver resolves a secret reference (without the scheme prefix) to its value
+type SecretResolver interface {
+	Resolve(ref string) (string, error)
+}
+
+type SecretResolverFunc func(ref string) (string, error)
+
+func (f SecretResolverFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+var (
+	secretResolversMu sync.RWMutex
+	secretResolvers   = map[string]SecretResolver{
+		"env": SecretResolverFunc(func(ref string) (string, error) {
+			value, ok := os.LookupEnv(ref)
+			if !ok {
+				return "", fmt.Errorf("environment variable '%s' is not set", ref)
+			}
+			return value, nil
+		}),
+		"file": SecretResolverFunc(func(ref string) (string, error) {
+			data, err := os.ReadFile(ref)
+			if err != nil {
+				return "", fmt.Errorf("failed to read secret file: %w", err)
+			}
+			// mounted secrets usually end with a new line
+			return strings.TrimRight(string(data), "\r\n"), nil
+		}),
+	}
+)
+
+// RegisterSecretResolver makes values of the form '<scheme>://<ref>' resolvable by the given resolver
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	secretResolversMu.Lock()
+	defer secretResolversMu.Unlock()
+	secretResolvers[scheme] = resolver
+}
+
+var (
+	envPlaceholderRegex = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+	secretRefRegex      = regexp.MustCompile(`^([a-z][a-z0-9+.-]*)://(.+)$`)
+	sensitiveKeyRegex   = regexp.MustCompile(`(?i)(password|secret|token|key)`)
+)
+
+// ConfigInterpolator resolves ${ENV_VAR}, ${ENV_VAR:-default} placeholders and secret references
+// in configuration values, keeps raw values to write them back unresolved and collects secrets for redaction
+type ConfigInterpolator struct {
+	// RawValues maps the path of every interpolated value (e.g. datasources.0.configuration.password) to its raw value
+	RawValues map[string]string
+	// deferred holds the raw connection settings by data source id until the data source is used
+	deferred  map[string]map[string]string
+	secretsMu sync.RWMutex
+	secrets   map[string]bool
+}
+
+func NewConfigInterpolator() *ConfigInterpolator {
+	return &ConfigInterpolator{
+		RawValues: make(map[string]string),
+		deferred:  make(map[string]map[string]string),
+		secrets:   make(map[string]bool),
+	}
+}
+
+// InterpolateSettings resolves all string values of the settings tree in place
+func (ci *ConfigInterpolator) InterpolateSettings(settings map[string]any) error {
+	_, err := ci.interpolateValue("", settings)
+	return err
+}
+
+func (ci *ConfigInterpolator) interpolateValue(path string, value any) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			resolved, err := ci.interpolateValue(joinPath(path, key), child)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = resolved
+		}
+		return v, nil
+	case []any:
+		for i, child := range v {
+			resolved, err := ci.interpolateValue(joinPath(path, strconv.Itoa(i)), child)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+		return v, nil
+	case string:
+		resolved, isSecret, err := ci.interpolateString(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve '%s': %w", path, err)
+		}
+		if resolved != v {
+			ci.RawValues[path] = v
+			if isSecret || sensitiveKeyRegex.MatchString(path[strings.LastIndex(path, ".")+1:]) {
+				// data sources are resolved while other goroutines log
+				ci.secretsMu.Lock()
+				ci.secrets[resolved] = true
+				ci.secretsMu.Unlock()
+			}
+		}
+		return resolved, nil
+	default:
+		return value, nil
+	}
+}
+
+func (ci *ConfigInterpolator) interpolateString(value string) (string, bool, error) {
+	if resolver, ref := findSecretResolver(value); resolver != nil {
+		resolved, err := resolver.Resolve(ref)
+		return resolved, true, err
+	}
+
+	var resolveErr error
+	resolved := envPlaceholderRegex.ReplaceAllStringFunc(value, func(placeholder string) string {
+		if placeholder == "$$" {
+			return "$"
+		}
+
+		parts := envPlaceholderRegex.FindStringSubmatch(placeholder)
+		if envValue, ok := os.LookupEnv(parts[1]); ok && (envValue != "" || parts[2] == "") {
+			return envValue
+		}
+		if parts[2] != "" {
+			return parts[3]
+		}
+
+		resolveErr = fmt.Errorf("environment variable '%s' is not set", parts[1])
+		return placeholder
+	})
+
+	return resolved, false, resolveErr
+}
+
+// findSecretResolver returns the resolver and the reference of a secret reference, nil if the value isn't one
+func findSecretResolver(value string) (SecretResolver, string) {
+	matches := secretRefRegex.FindStringSubmatch(value)
+	if matches == nil {
+		return nil, ""
+	}
+	secretResolversMu.RLock()
+	defer secretResolversMu.RUnlock()
+	return secretResolvers[matches[1]], matches[2]
+}
+
+// deferDataSourceSettings takes the connection settings with placeholders or secret references out of the
+// data sources, they're resolved by resolveDataSourceSettings once a data source is used
+func (ci *ConfigInterpolator) deferDataSourceSettings(settings map[string]any) {
+	dataSources, _ := settings["datasources"].([]any)
+	for i, item := range dataSources {
+		dsSettings, ok := asSettingsMap(item)
+		id := settingsString(dsSettings, "id")
+		if !ok || id == "" {
+			continue
+		}
+
+		for key, value := range dsSettings {
+			configuration, ok := asSettingsMap(value)
+			if !ok || !strings.EqualFold(key, "configuration") {
+				continue
+			}
+			path := joinPath(joinPath("datasources", strconv.Itoa(i)), key)
+			for name, setting := range configuration {
+				raw, ok := setting.(string)
+				if !ok || !needsInterpolation(raw) {
+					continue
+				}
+				if ci.deferred[id] == nil {
+					ci.deferred[id] = make(map[string]string)
+				}
+				ci.deferred[id][name] = raw
+				ci.RawValues[joinPath(path, name)] = raw
+				delete(configuration, name)
+			}
+			dsSettings[key] = configuration
+		}
+		dataSources[i] = dsSettings
+	}
+}
+
+// resolveDataSourceSettings resolves the deferred connection settings of the data source at the given index,
+// they stay deferred until forgetDataSourceSettings is called
+func (ci *ConfigInterpolator) resolveDataSourceSettings(index int, id string) (map[string]any, error) {
+	path := joinPath(joinPath("datasources", strconv.Itoa(index)), "configuration")
+	resolved := make(map[string]any, len(ci.deferred[id]))
+	for name, raw := range ci.deferred[id] {
+		value, err := ci.interpolateValue(joinPath(path, name), raw)
+		if err != nil {
+			return nil, err
+		}
+		resolved[name] = value
+	}
+	return resolved, nil
+}
+
+func (ci *ConfigInterpolator) forgetDataSourceSettings(id string) {
+	delete(ci.deferred, id)
+}
+
+func needsInterpolation(value string) bool {
+	if resolver, _ := findSecretResolver(value); resolver != nil {
+		return true
+	}
+	return envPlaceholderRegex.MatchString(value)
+}
+
+// minRedactedSecretLength is the length below which secrets are only redacted as whole values, replacing every
+// occurrence of a one or two character secret would make logs and errors unreadable
+const minRedactedSecretLength = 3
+
+// Redact replaces every resolved secret in the given text
+func (ci *ConfigInterpolator) Redact(text string) string {
+	if ci == nil {
+		return text
+	}
+
+	// replace longer secrets first, in case one contains another
+	ci.secretsMu.RLock()
+	if _, ok := ci.secrets[text]; ok && text != "" {
+		ci.secretsMu.RUnlock()
+		return "******"
+	}
+	secrets := make([]string, 0, len(ci.secrets))
+	for secret := range ci.secrets {
+		if len(secret) >= minRedactedSecretLength {
+			secrets = append(secrets, secret)
+		}
+	}
+	ci.secretsMu.RUnlock()
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, "******")
+	}
+	return text
+}
+
+// removeListItem forgets raw values of the removed list item and shifts raw values of the following items
+func (ci *ConfigInterpolator) removeListItem(listPath string, index int) {
+	prefix := listPath + "."
+	shifted := make(map[string]string, len(ci.RawValues))
+	for path, rawValue := range ci.RawValues {
+		idx, rest, found := strings.Cut(strings.TrimPrefix(path, prefix), ".")
+		itemIndex, err := strconv.Atoi(idx)
+		if !strings.HasPrefix(path, prefix) || !found || err != nil || itemIndex < index {
+			shifted[path] = rawValue
+			continue
+		}
+		if itemIndex > index {
+			shifted[joinPath(listPath, strconv.Itoa(itemIndex-1))+"."+rest] = rawValue
+		}
+	}
+	ci.RawValues = shifted
+}
+
+func joinPath(parent string, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+// redactingHandler masks resolved secrets in log messages and string attributes
+type redactingHandler struct {
+	slog.Handler
+	redactor *ConfigInterpolator
+}
+
+func newRedactingHandler(handler slog.Handler, redactor *ConfigInterpolator) slog.Handler {
+	return &redactingHandler{Handler: handler, redactor: redactor}
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, h.redactor.Redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.redactAttr(attr))
+		return true
+	})
+	return h.Handler.Handle(ctx, redacted)
+}
+
+func (h *redactingHandler) redactAttr(attr slog.Attr) slog.Attr {
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, h.redactor.Redact(attr.Value.String()))
+	case slog.KindGroup:
+		attrs := attr.Value.Group()
+		redacted := make([]any, 0, len(attrs))
+		for _, groupAttr := range attrs {
+			redacted = append(redacted, h.redactAttr(groupAttr))
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, h.redactor.Redact(err.Error()))
+		}
+	}
+	return attr
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		redacted = append(redacted, h.redactAttr(attr))
+	}
+	return &redactingHandler{Handler: h.Handler.WithAttrs(redacted), redactor: h.redactor}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{Handler: h.Handler.WithGroup(name), redactor: h.redactor}
+}
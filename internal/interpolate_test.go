@@ -0,0 +1,274 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInterpolateString(t *testing.T) {
+	t.Setenv("DBQ_TEST_HOST", "db.local")
+	t.Setenv("DBQ_TEST_EMPTY", "")
+
+	secretFile := filepath.Join(t.TempDir(), "pg")
+	if err := os.WriteFile(secretFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		value      string
+		want       string
+		wantSecret bool
+		wantErr    string
+	}{
+		{name: "plain", value: "localhost", want: "localhost"},
+		{name: "placeholder", value: "${DBQ_TEST_HOST}", want: "db.local"},
+		{name: "placeholder in text", value: "tcp://${DBQ_TEST_HOST}:9000", want: "tcp://db.local:9000"},
+		{name: "default of unset", value: "${DBQ_TEST_UNSET:-fallback}", want: "fallback"},
+		{name: "default of empty", value: "${DBQ_TEST_EMPTY:-fallback}", want: "fallback"},
+		{name: "empty default", value: "${DBQ_TEST_UNSET:-}", want: ""},
+		{name: "set value wins over default", value: "${DBQ_TEST_HOST:-fallback}", want: "db.local"},
+		{name: "empty without default", value: "${DBQ_TEST_EMPTY}", want: ""},
+		{name: "escaped dollar", value: "pa$$word", want: "pa$word"},
+		{name: "escaped placeholder", value: "$${DBQ_TEST_HOST}", want: "${DBQ_TEST_HOST}"},
+		{name: "unset", value: "${DBQ_TEST_UNSET}", wantErr: "environment variable 'DBQ_TEST_UNSET' is not set"},
+		{name: "file reference", value: "file://" + secretFile, want: "s3cret", wantSecret: true},
+		{name: "missing file", value: "file://" + secretFile + ".missing", wantErr: "failed to read secret file"},
+		{name: "env reference", value: "env://DBQ_TEST_HOST", want: "db.local", wantSecret: true},
+		{name: "unset env reference", value: "env://DBQ_TEST_UNSET", wantErr: "environment variable 'DBQ_TEST_UNSET' is not set"},
+		{name: "unknown scheme", value: "https://example.com", want: "https://example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, isSecret, err := NewConfigInterpolator().interpolateString(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want || isSecret != tt.wantSecret {
+				t.Errorf("interpolateString(%q) = %q, %v, want %q, %v", tt.value, got, isSecret, tt.want, tt.wantSecret)
+			}
+		})
+	}
+}
+
+func TestInterpolateSettings(t *testing.T) {
+	t.Setenv("DBQ_TEST_USER", "admin")
+	t.Setenv("DBQ_TEST_PASSWORD", "s3cret")
+
+	settings := map[string]any{
+		"history": map[string]any{"path": "${DBQ_TEST_UNSET:-history.db}"},
+		"datasources": []any{
+			map[string]any{
+				"id": "pg",
+				"configuration": map[string]any{
+					"host":     "localhost",
+					"port":     5432,
+					"username": "${DBQ_TEST_USER}",
+					"password": "${DBQ_TEST_PASSWORD}",
+				},
+			},
+		},
+	}
+
+	ci := NewConfigInterpolator()
+	if err := ci.InterpolateSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	configuration := settings["datasources"].([]any)[0].(map[string]any)["configuration"].(map[string]any)
+	if configuration["username"] != "admin" || configuration["password"] != "s3cret" || configuration["port"] != 5432 {
+		t.Errorf("configuration = %v", configuration)
+	}
+
+	wantRaw := map[string]string{
+		"history.path":                         "${DBQ_TEST_UNSET:-history.db}",
+		"datasources.0.configuration.username": "${DBQ_TEST_USER}",
+		"datasources.0.configuration.password": "${DBQ_TEST_PASSWORD}",
+	}
+	if len(ci.RawValues) != len(wantRaw) {
+		t.Errorf("RawValues = %v, want %v", ci.RawValues, wantRaw)
+	}
+	for path, raw := range wantRaw {
+		if ci.RawValues[path] != raw {
+			t.Errorf("RawValues[%s] = %q, want %q", path, ci.RawValues[path], raw)
+		}
+	}
+
+	// only values of sensitive keys and secret references are redacted
+	if got := ci.Redact("user admin, password s3cret"); got != "user admin, password ******" {
+		t.Errorf("Redact() = %q", got)
+	}
+}
+
+func TestDeferDataSourceSettings(t *testing.T) {
+	t.Setenv("DBQ_TEST_PORT", "5433")
+
+	settings := map[string]any{
+		"datasources": []any{
+			map[string]any{
+				"id": "pg",
+				"configuration": map[string]any{
+					"host":     "localhost",
+					"port":     "${DBQ_TEST_PORT}",
+					"password": "${DBQ_TEST_UNSET}",
+				},
+			},
+		},
+	}
+
+	ci := NewConfigInterpolator()
+	ci.deferDataSourceSettings(settings)
+	// unresolvable settings of data sources don't fail the config loading
+	if err := ci.InterpolateSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	configuration := settings["datasources"].([]any)[0].(map[string]any)["configuration"].(map[string]any)
+	if len(configuration) != 1 || configuration["host"] != "localhost" {
+		t.Errorf("configuration = %v, want only the host", configuration)
+	}
+	if ci.RawValues["datasources.0.configuration.password"] != "${DBQ_TEST_UNSET}" {
+		t.Errorf("raw password = %q", ci.RawValues["datasources.0.configuration.password"])
+	}
+
+	_, err := ci.resolveDataSourceSettings(0, "pg")
+	if err == nil || !strings.Contains(err.Error(), "failed to resolve 'datasources.0.configuration.password'") {
+		t.Fatalf("error = %v", err)
+	}
+
+	t.Setenv("DBQ_TEST_UNSET", "x")
+	resolved, err := ci.resolveDataSourceSettings(0, "pg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved["port"] != "5433" || resolved["password"] != "x" {
+		t.Errorf("resolved = %v", resolved)
+	}
+}
+
+func TestResolveDataSource(t *testing.T) {
+	dir := writeChecksFiles(t, map[string]string{
+		"dbq.yaml": `version: "1"
+datasources:
+  - id: pg
+    type: postgresql
+    configuration:
+      host: localhost
+      port: ${DBQ_TEST_PORT:-5432}
+      password: ${DBQ_TEST_PASSWORD}
+  - id: ch
+    type: clickhouse
+    configuration:
+      host: localhost
+      port: 9000
+`,
+	})
+
+	app := NewDbqCliApp(AppOptions{ConfigFiles: []string{filepath.Join(dir, "dbq.yaml")}}).(*DbqAppImpl)
+	t.Cleanup(func() { _ = app.Close() })
+	if err := app.GetConfigError(); err != nil {
+		t.Fatalf("unset variable of a data source failed the config loading: %v", err)
+	}
+	if err := app.ResolveDataSource("ch"); err != nil {
+		t.Errorf("ResolveDataSource(ch) = %v", err)
+	}
+
+	var configErr *DataSourceConfigError
+	if err := app.ResolveDataSource("pg"); !errors.As(err, &configErr) || configErr.DataSource != "pg" {
+		t.Fatalf("ResolveDataSource(pg) = %v, want DataSourceConfigError", err)
+	}
+
+	t.Setenv("DBQ_TEST_PASSWORD", "p")
+	if err := app.ResolveDataSource("pg"); err != nil {
+		t.Fatal(err)
+	}
+	pg := app.FindDataSourceById("pg")
+	if pg.Configuration.Port != 5432 || pg.Configuration.Password != "p" {
+		t.Errorf("configuration = %+v", pg.Configuration)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	ci := NewConfigInterpolator()
+	for _, secret := range []string{"", "x", "ab", "abcdef", "abcdef-long"} {
+		ci.secrets[secret] = true
+	}
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "no secrets here", want: "no secrets here"},
+		{text: "x", want: "******"},
+		{text: "ab", want: "******"},
+		{text: "password x", want: "password x"},
+		{text: "dsn u:ab@host", want: "dsn u:ab@host"},
+		{text: "dsn u:abcdef@host", want: "dsn u:******@host"},
+		{text: "token abcdef-long", want: "token ******"},
+		{text: "token abcdef", want: "token ******"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := ci.Redact(tt.text); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+
+	var nilInterpolator *ConfigInterpolator
+	if got := nilInterpolator.Redact("abc"); got != "abc" {
+		t.Errorf("nil Redact() = %q", got)
+	}
+}
+
+func TestRedactingHandler(t *testing.T) {
+	ci := NewConfigInterpolator()
+	ci.secrets["hunter2"] = true
+
+	var out bytes.Buffer
+	logger := slog.New(newRedactingHandler(slog.NewTextHandler(&out, nil), ci))
+	logger.With("dsn", "postgres://u:hunter2@db").Info("connecting with hunter2",
+		"err", errors.New("auth failed for hunter2"),
+		slog.Group("conn", "password", "hunter2"))
+
+	if strings.Contains(out.String(), "hunter2") {
+		t.Errorf("log output contains the secret: %s", out.String())
+	}
+	if strings.Count(out.String(), "******") != 4 {
+		t.Errorf("log output = %s", out.String())
+	}
+
+	ci.secrets["1"] = true
+	out.Reset()
+	logger.Info("connected to db1 in 1ms", "port", "5431", "password", "1")
+	if want := `msg="connected to db1 in 1ms" port=5431 password=******`; !strings.Contains(out.String(), want) {
+		t.Errorf("log output = %s, want it to contain %s", out.String(), want)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	LogFormatText = "text"
+	LogFormatJson = "json"
+)
+
+// LogOptions configure the logger used by dbqctl and passed to dbqcore
+type LogOptions struct {
+	// Level is one of debug, info, warn or error, error is used if empty (info if Verbose is set)
+	Level   string
+	Verbose bool
+	// Format is either text or json
+	Format string
+	// File receives the logs instead of stderr if set, the file is appended to
+	File string
+}
+
+type logAttrsKey struct{}
+
+// WithLogAttrs returns a context whose attributes are added to every log record made with it,
+// e.g. the run id or the data source a check is running against. Attributes replace the ones with the same key
+func WithLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	parent := logAttrs(ctx)
+	merged := make([]slog.Attr, 0, len(parent)+len(attrs))
+	for _, attr := range parent {
+		if !slices.ContainsFunc(attrs, func(a slog.Attr) bool { return a.Key == attr.Key }) {
+			merged = append(merged, attr)
+		}
+	}
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, logAttrsKey{}, merged)
+}
+
+func logAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+// newLogger builds the logger from the options, the returned closer releases the log file (if any)
+func newLogger(opts LogOptions, levelVar *slog.LevelVar, redactor *ConfigInterpolator) (*slog.Logger, io.Closer, error) {
+	level := slog.LevelError
+	if opts.Verbose {
+		level = slog.LevelInfo
+	}
+	if opts.Level != "" {
+		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+			return nil, nil, fmt.Errorf("invalid log level '%s' (expected one of: debug, info, warn, error)", opts.Level)
+		}
+	}
+	levelVar.Set(level)
+
+	var out io.Writer = os.Stderr
+	var closer io.Closer = io.NopCloser(nil)
+	if opts.File != "" {
+		file, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		out, closer = file, file
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: levelVar}
+	var handler slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", LogFormatText:
+		handler = slog.NewTextHandler(out, handlerOpts)
+	case LogFormatJson:
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	default:
+		_ = closer.Close()
+		return nil, nil, fmt.Errorf("invalid log format '%s' (expected one of: text, json)", opts.Format)
+	}
+
+	return slog.New(&contextHandler{Handler: newRedactingHandler(handler, redactor)}), closer, nil
+}
+
+// loggerFor returns the logger with attributes of the context bound, for libraries logging without the context
+func loggerFor(ctx context.Context, logger *slog.Logger) *slog.Logger {
+	attrs := logAttrs(ctx)
+	if len(attrs) == 0 {
+		return logger
+	}
+	args := make([]any, len(attrs))
+	for i, attr := range attrs {
+		args[i] = attr
+	}
+	return logger.With(args...)
+}
+
+// contextHandler adds attributes of the context (see WithLogAttrs) to every record,
+// unless the same keys are already bound to the logger or set on the record
+type contextHandler struct {
+	slog.Handler
+	bound []string
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	attrs := logAttrs(ctx)
+	if len(attrs) == 0 {
+		return h.Handler.Handle(ctx, record)
+	}
+
+	present := slices.Clone(h.bound)
+	record.Attrs(func(attr slog.Attr) bool {
+		present = append(present, attr.Key)
+		return true
+	})
+
+	record = record.Clone()
+	for _, attr := range attrs {
+		if !slices.Contains(present, attr.Key) {
+			record.AddAttrs(attr)
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	bound := slices.Clone(h.bound)
+	for _, attr := range attrs {
+		bound = append(bound, attr.Key)
+	}
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs), bound: bound}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name), bound: h.bound}
+}
@@ -0,0 +1,219 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"math"
+	"sort"
+
+	"github.com/DataBridgeTech/dbqcore"
+)
+
+// ProfileDiffTolerances define how much profile statistics may change before it's reported as a drift
+type ProfileDiffTolerances struct {
+	// RowCountPct is the allowed relative row count change, in percent
+	RowCountPct float64
+	// NullRatioPts is the allowed change of the null ratio of a column, in percentage points
+	NullRatioPts float64
+	// StatsPct is the allowed relative change of min/max/avg/stddev of a column, in percent
+	StatsPct float64
+}
+
+type ProfileDiff struct {
+	Datasets []DatasetProfileDiff `json:"datasets"`
+}
+
+type DatasetProfileDiff struct {
+	Dataset string `json:"dataset"`
+	// Status is one of: added, removed, changed, unchanged
+	Status         string             `json:"status"`
+	OldRowCount    uint64             `json:"old_row_count"`
+	NewRowCount    uint64             `json:"new_row_count"`
+	RowCountChange *float64           `json:"row_count_change_pct,omitempty"`
+	RowCountDrift  bool               `json:"row_count_drift"`
+	AddedColumns   []ColumnTypeChange `json:"added_columns,omitempty"`
+	RemovedColumns []ColumnTypeChange `json:"removed_columns,omitempty"`
+	RetypedColumns []ColumnTypeChange `json:"retyped_columns,omitempty"`
+	StatChanges    []ColumnStatChange `json:"stat_changes,omitempty"`
+}
+
+type ColumnTypeChange struct {
+	Column  string `json:"column"`
+	OldType string `json:"old_type,omitempty"`
+	NewType string `json:"new_type,omitempty"`
+}
+
+// ColumnStatChange is a change of a column statistic beyond the configured tolerance
+type ColumnStatChange struct {
+	Column string  `json:"column"`
+	Metric string  `json:"metric"`
+	Old    float64 `json:"old"`
+	New    float64 `json:"new"`
+	// Change is relative change in percent (or percentage points for null_ratio), nil if the old value is zero
+	Change *float64 `json:"change,omitempty"`
+}
+
+const (
+	ProfileDiffAdded     = "added"
+	ProfileDiffRemoved   = "removed"
+	ProfileDiffChanged   = "changed"
+	ProfileDiffUnchanged = "unchanged"
+)
+
+// HasDrift reports whether any dataset changed beyond tolerances
+func (d *ProfileDiff) HasDrift() bool {
+	for _, dataset := range d.Datasets {
+		if dataset.Status != ProfileDiffUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffProfiles compares two sets of profiles keyed by dataset name
+func DiffProfiles(oldProfiles map[string]*dbqcore.TableMetrics, newProfiles map[string]*dbqcore.TableMetrics, tolerances ProfileDiffTolerances) *ProfileDiff {
+	datasets := make(map[string]bool)
+	for dataset := range oldProfiles {
+		datasets[dataset] = true
+	}
+	for dataset := range newProfiles {
+		datasets[dataset] = true
+	}
+
+	sortedDatasets := make([]string, 0, len(datasets))
+	for dataset := range datasets {
+		sortedDatasets = append(sortedDatasets, dataset)
+	}
+	sort.Strings(sortedDatasets)
+
+	diff := &ProfileDiff{}
+	for _, dataset := range sortedDatasets {
+		oldMetrics, newMetrics := oldProfiles[dataset], newProfiles[dataset]
+		switch {
+		case oldMetrics == nil:
+			diff.Datasets = append(diff.Datasets, DatasetProfileDiff{Dataset: dataset, Status: ProfileDiffAdded, NewRowCount: newMetrics.TotalRows})
+		case newMetrics == nil:
+			diff.Datasets = append(diff.Datasets, DatasetProfileDiff{Dataset: dataset, Status: ProfileDiffRemoved, OldRowCount: oldMetrics.TotalRows})
+		default:
+			diff.Datasets = append(diff.Datasets, diffTableMetrics(dataset, oldMetrics, newMetrics, tolerances))
+		}
+	}
+
+	return diff
+}
+
+func diffTableMetrics(dataset string, oldMetrics *dbqcore.TableMetrics, newMetrics *dbqcore.TableMetrics, tolerances ProfileDiffTolerances) DatasetProfileDiff {
+	diff := DatasetProfileDiff{
+		Dataset:     dataset,
+		OldRowCount: oldMetrics.TotalRows,
+		NewRowCount: newMetrics.TotalRows,
+	}
+
+	diff.RowCountChange = relativeChange(float64(oldMetrics.TotalRows), float64(newMetrics.TotalRows))
+	diff.RowCountDrift = exceedsTolerance(diff.RowCountChange, float64(oldMetrics.TotalRows), float64(newMetrics.TotalRows), tolerances.RowCountPct)
+
+	for _, name := range sortedColumnNames(oldMetrics, newMetrics) {
+		oldColumn, newColumn := oldMetrics.ColumnsMetrics[name], newMetrics.ColumnsMetrics[name]
+		switch {
+		case oldColumn == nil:
+			diff.AddedColumns = append(diff.AddedColumns, ColumnTypeChange{Column: name, NewType: newColumn.DataType})
+		case newColumn == nil:
+			diff.RemovedColumns = append(diff.RemovedColumns, ColumnTypeChange{Column: name, OldType: oldColumn.DataType})
+		default:
+			if oldColumn.DataType != newColumn.DataType {
+				diff.RetypedColumns = append(diff.RetypedColumns, ColumnTypeChange{Column: name, OldType: oldColumn.DataType, NewType: newColumn.DataType})
+			}
+
+			oldNullRatio := nullRatioPct(oldColumn.NullCount, oldMetrics.TotalRows)
+			newNullRatio := nullRatioPct(newColumn.NullCount, newMetrics.TotalRows)
+			if pts := newNullRatio - oldNullRatio; math.Abs(pts) > tolerances.NullRatioPts {
+				diff.StatChanges = append(diff.StatChanges, ColumnStatChange{Column: name, Metric: "null_ratio", Old: oldNullRatio, New: newNullRatio, Change: &pts})
+			}
+
+			stats := []struct {
+				metric   string
+				old, new *float64
+			}{
+				{"min", oldColumn.MinValue, newColumn.MinValue},
+				{"max", oldColumn.MaxValue, newColumn.MaxValue},
+				{"avg", oldColumn.AvgValue, newColumn.AvgValue},
+				{"stddev", oldColumn.StddevValue, newColumn.StddevValue},
+			}
+			for _, stat := range stats {
+				if stat.old == nil || stat.new == nil {
+					continue
+				}
+				change := relativeChange(*stat.old, *stat.new)
+				if exceedsTolerance(change, *stat.old, *stat.new, tolerances.StatsPct) {
+					diff.StatChanges = append(diff.StatChanges, ColumnStatChange{Column: name, Metric: stat.metric, Old: *stat.old, New: *stat.new, Change: change})
+				}
+			}
+		}
+	}
+
+	diff.Status = ProfileDiffUnchanged
+	if diff.RowCountDrift || len(diff.AddedColumns) > 0 || len(diff.RemovedColumns) > 0 || len(diff.RetypedColumns) > 0 || len(diff.StatChanges) > 0 {
+		diff.Status = ProfileDiffChanged
+	}
+
+	return diff
+}
+
+func sortedColumnNames(oldMetrics *dbqcore.TableMetrics, newMetrics *dbqcore.TableMetrics) []string {
+	positions := make(map[string]uint)
+	for name, column := range oldMetrics.ColumnsMetrics {
+		positions[name] = column.ColumnPosition
+	}
+	for name, column := range newMetrics.ColumnsMetrics {
+		if _, ok := positions[name]; !ok {
+			positions[name] = column.ColumnPosition
+		}
+	}
+
+	names := make([]string, 0, len(positions))
+	for name := range positions {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if positions[names[i]] != positions[names[j]] {
+			return positions[names[i]] < positions[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// relativeChange returns change from old to new in percent, nil when old is zero
+func relativeChange(oldVal float64, newVal float64) *float64 {
+	if oldVal == 0 {
+		return nil
+	}
+	change := (newVal - oldVal) / math.Abs(oldVal) * 100
+	return &change
+}
+
+func exceedsTolerance(change *float64, oldVal float64, newVal float64, tolerancePct float64) bool {
+	if change == nil {
+		return oldVal != newVal
+	}
+	return math.Abs(*change) > tolerancePct
+}
+
+func nullRatioPct(nulls uint64, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(nulls) * 100 / float64(total)
+}
@@ -0,0 +1,185 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/DataBridgeTech/dbqcore"
+)
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func tableMetrics(rows uint64, columns ...*dbqcore.ColumnMetrics) *dbqcore.TableMetrics {
+	metrics := &dbqcore.TableMetrics{TotalRows: rows, ColumnsMetrics: make(map[string]*dbqcore.ColumnMetrics)}
+	for i, column := range columns {
+		column.ColumnPosition = uint(i + 1)
+		metrics.ColumnsMetrics[column.ColumnName] = column
+	}
+	return metrics
+}
+
+func TestDiffProfiles(t *testing.T) {
+	tolerances := ProfileDiffTolerances{RowCountPct: 10, NullRatioPts: 5, StatsPct: 20}
+	price := func(avg float64, nulls uint64) *dbqcore.ColumnMetrics {
+		return &dbqcore.ColumnMetrics{ColumnName: "price", DataType: "Float64", NullCount: nulls, AvgValue: float64Ptr(avg)}
+	}
+
+	tests := []struct {
+		name          string
+		old           *dbqcore.TableMetrics
+		new           *dbqcore.TableMetrics
+		wantStatus    string
+		wantRowDrift  bool
+		wantRowChange *float64
+		wantStats     []string
+	}{
+		{
+			name:          "within tolerances",
+			old:           tableMetrics(1000, price(10, 10)),
+			new:           tableMetrics(1100, price(12, 50)),
+			wantStatus:    ProfileDiffUnchanged,
+			wantRowChange: float64Ptr(10),
+		},
+		{
+			name:          "row count beyond tolerance",
+			old:           tableMetrics(1000, price(10, 0)),
+			new:           tableMetrics(850, price(10, 0)),
+			wantStatus:    ProfileDiffChanged,
+			wantRowDrift:  true,
+			wantRowChange: float64Ptr(-15),
+		},
+		{
+			name:          "null ratio and avg beyond tolerances",
+			old:           tableMetrics(1000, price(10, 0)),
+			new:           tableMetrics(1000, price(12.5, 60)),
+			wantStatus:    ProfileDiffChanged,
+			wantRowChange: float64Ptr(0),
+			wantStats:     []string{"price.null_ratio", "price.avg"},
+		},
+		{
+			name:       "zero baseline row count unchanged",
+			old:        tableMetrics(0),
+			new:        tableMetrics(0),
+			wantStatus: ProfileDiffUnchanged,
+		},
+		{
+			name:         "zero baseline row count changed",
+			old:          tableMetrics(0),
+			new:          tableMetrics(1),
+			wantStatus:   ProfileDiffChanged,
+			wantRowDrift: true,
+		},
+		{
+			name:          "zero baseline stat changed",
+			old:           tableMetrics(1000, price(0, 0)),
+			new:           tableMetrics(1000, price(0.01, 0)),
+			wantStatus:    ProfileDiffChanged,
+			wantRowChange: float64Ptr(0),
+			wantStats:     []string{"price.avg"},
+		},
+		{
+			name:          "zero baseline stat unchanged",
+			old:           tableMetrics(1000, price(0, 0)),
+			new:           tableMetrics(1000, price(0, 0)),
+			wantStatus:    ProfileDiffUnchanged,
+			wantRowChange: float64Ptr(0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffProfiles(map[string]*dbqcore.TableMetrics{"orders": tt.old}, map[string]*dbqcore.TableMetrics{"orders": tt.new}, tolerances)
+			if len(diff.Datasets) != 1 {
+				t.Fatalf("got %d datasets, want 1", len(diff.Datasets))
+			}
+			dataset := diff.Datasets[0]
+			if dataset.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s", dataset.Status, tt.wantStatus)
+			}
+			if diff.HasDrift() != (tt.wantStatus != ProfileDiffUnchanged) {
+				t.Errorf("HasDrift() = %t", diff.HasDrift())
+			}
+			if dataset.RowCountDrift != tt.wantRowDrift {
+				t.Errorf("RowCountDrift = %t, want %t", dataset.RowCountDrift, tt.wantRowDrift)
+			}
+			switch {
+			case tt.wantRowChange == nil && dataset.RowCountChange != nil:
+				t.Errorf("RowCountChange = %g, want nil", *dataset.RowCountChange)
+			case tt.wantRowChange != nil && (dataset.RowCountChange == nil || *dataset.RowCountChange != *tt.wantRowChange):
+				t.Errorf("RowCountChange = %v, want %g", dataset.RowCountChange, *tt.wantRowChange)
+			}
+
+			var stats []string
+			for _, change := range dataset.StatChanges {
+				stats = append(stats, change.Column+"."+change.Metric)
+			}
+			if len(stats) != len(tt.wantStats) {
+				t.Fatalf("stat changes = %v, want %v", stats, tt.wantStats)
+			}
+			for i := range stats {
+				if stats[i] != tt.wantStats[i] {
+					t.Errorf("stat changes = %v, want %v", stats, tt.wantStats)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffProfilesDatasetsAndColumns(t *testing.T) {
+	oldProfiles := map[string]*dbqcore.TableMetrics{
+		"orders": tableMetrics(10,
+			&dbqcore.ColumnMetrics{ColumnName: "id", DataType: "UInt64"},
+			&dbqcore.ColumnMetrics{ColumnName: "created_at", DataType: "DateTime"},
+			&dbqcore.ColumnMetrics{ColumnName: "note", DataType: "String"}),
+		"legacy": tableMetrics(5),
+	}
+	newProfiles := map[string]*dbqcore.TableMetrics{
+		"orders": tableMetrics(10,
+			&dbqcore.ColumnMetrics{ColumnName: "id", DataType: "UInt64"},
+			&dbqcore.ColumnMetrics{ColumnName: "created_at", DataType: "Date"},
+			&dbqcore.ColumnMetrics{ColumnName: "amount", DataType: "Decimal(10, 2)"}),
+		"customers": tableMetrics(3),
+	}
+
+	diff := DiffProfiles(oldProfiles, newProfiles, ProfileDiffTolerances{})
+
+	statuses := make(map[string]string)
+	for _, dataset := range diff.Datasets {
+		statuses[dataset.Dataset] = dataset.Status
+	}
+	want := map[string]string{"customers": ProfileDiffAdded, "legacy": ProfileDiffRemoved, "orders": ProfileDiffChanged}
+	for dataset, status := range want {
+		if statuses[dataset] != status {
+			t.Errorf("%s: status = %s, want %s", dataset, statuses[dataset], status)
+		}
+	}
+	if diff.Datasets[0].Dataset != "customers" || diff.Datasets[2].Dataset != "orders" {
+		t.Errorf("datasets are not sorted: %+v", diff.Datasets)
+	}
+
+	orders := diff.Datasets[2]
+	if len(orders.AddedColumns) != 1 || orders.AddedColumns[0].Column != "amount" {
+		t.Errorf("AddedColumns = %+v", orders.AddedColumns)
+	}
+	if len(orders.RemovedColumns) != 1 || orders.RemovedColumns[0].Column != "note" {
+		t.Errorf("RemovedColumns = %+v", orders.RemovedColumns)
+	}
+	if len(orders.RetypedColumns) != 1 || orders.RetypedColumns[0] != (ColumnTypeChange{Column: "created_at", OldType: "DateTime", NewType: "Date"}) {
+		t.Errorf("RetypedColumns = %+v", orders.RetypedColumns)
+	}
+}
@@ -0,0 +1,660 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataBridgeTech/dbqcore"
+)
+
+// ReconcileTarget is one side of a reconciliation, e.g. 'pg:public.orders'
+type ReconcileTarget struct {
+	DataSource string `json:"datasource"`
+	Dataset    string `json:"dataset"`
+}
+
+func (t ReconcileTarget) String() string {
+	return t.DataSource + ":" + t.Dataset
+}
+
+// ParseReconcileTarget parses '<datasource>:<dataset>', e.g. 'ch:analytics.orders'
+func ParseReconcileTarget(value string) (ReconcileTarget, error) {
+	dataSource, dataset, ok := strings.Cut(strings.TrimSpace(value), ":")
+	dataSource, dataset = strings.TrimSpace(dataSource), strings.TrimSpace(dataset)
+	if !ok || dataSource == "" || dataset == "" {
+		return ReconcileTarget{}, fmt.Errorf("invalid dataset '%s' (expected <datasource>:<dataset>, e.g. pg:public.orders)", value)
+	}
+	return ReconcileTarget{DataSource: dataSource, Dataset: dataset}, nil
+}
+
+// ReconcileOptions describes what is compared between the source and target datasets
+type ReconcileOptions struct {
+	Source ReconcileTarget
+	Target ReconcileTarget
+	// Key is the integer column identifying rows, key ranges are split into buckets by it
+	Key string
+	// Columns limits compared columns, all columns present on both sides are compared if empty
+	Columns []string
+	// Where filters rows on both sides
+	Where string
+	// Buckets is the number of key ranges checksums are compared for, zero disables checksums
+	Buckets int
+	// Depth is the number of times mismatching buckets are split into Buckets smaller ranges
+	Depth int
+	// HashColumns are hashed along with the key, their text representation has to match on both sides
+	HashColumns []string
+	// Tolerance is the allowed relative difference of numeric aggregates in percent
+	Tolerance float64
+}
+
+// ReconcileValue is a metric compared between the source and target datasets
+type ReconcileValue struct {
+	Column    string `json:"column,omitempty"`
+	Aggregate string `json:"aggregate"`
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+	Match     bool   `json:"match"`
+}
+
+// ReconcileBucket is a key range whose row count or checksum differs between the source and target datasets
+type ReconcileBucket struct {
+	// From and To are the inclusive bounds of the key range
+	From           int64  `json:"from"`
+	To             int64  `json:"to"`
+	Level          int    `json:"level"`
+	SourceRows     int64  `json:"source_rows"`
+	TargetRows     int64  `json:"target_rows"`
+	SourceChecksum string `json:"source_checksum"`
+	TargetChecksum string `json:"target_checksum"`
+}
+
+// ReconcileResult is the outcome of comparing the source and target datasets
+type ReconcileResult struct {
+	Source            ReconcileTarget  `json:"source"`
+	Target            ReconcileTarget  `json:"target"`
+	Key               string           `json:"key"`
+	Match             bool             `json:"match"`
+	RowCount          ReconcileValue   `json:"row_count"`
+	Aggregates        []ReconcileValue `json:"aggregates"`
+	SourceOnlyColumns []string         `json:"source_only_columns,omitempty"`
+	TargetOnlyColumns []string         `json:"target_only_columns,omitempty"`
+	// BucketsCompared is the number of key ranges checksums were compared for, across all levels
+	BucketsCompared int `json:"buckets_compared,omitempty"`
+	// MismatchedBuckets are the narrowest key ranges found to differ
+	MismatchedBuckets []ReconcileBucket `json:"mismatched_buckets,omitempty"`
+}
+
+const (
+	aggregateRowCount = "row_count"
+	aggregateSum      = "sum"
+	aggregateMin      = "min"
+	aggregateMax      = "max"
+	aggregateNulls    = "null_count"
+)
+
+// reconcileSide runs queries against one of the reconciled datasets
+type reconcileSide struct {
+	app        *DbqAppImpl
+	target     ReconcileTarget
+	dataSource *dbqcore.DataSource
+	adapter    dbqcore.DbqDataSourceAdapter
+	// columns maps lowercased column names to the names as defined in the dataset, set by columnTypes
+	columns map[string]string
+}
+
+type reconcileColumn struct {
+	name       string
+	sourceType string
+	targetType string
+}
+
+// Reconcile compares row counts and per-column aggregates of two datasets, possibly on different data sources,
+// and with Buckets set narrows down the key ranges whose checksums differ
+func (app *DbqAppImpl) Reconcile(ctx context.Context, opts ReconcileOptions) (*ReconcileResult, error) {
+	opts.Depth = max(opts.Depth, 1)
+	source, err := app.reconcileSide(ctx, opts.Source)
+	if err != nil {
+		return nil, err
+	}
+	target, err := app.reconcileSide(ctx, opts.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReconcileResult{Source: opts.Source, Target: opts.Target, Key: opts.Key}
+	columns, err := reconcileColumns(ctx, source, target, opts, result)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceAggregates, err := source.aggregates(ctx, columns, opts)
+	if err != nil {
+		return nil, err
+	}
+	targetAggregates, err := target.aggregates(ctx, columns, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Match = true
+	for i := range sourceAggregates {
+		value := sourceAggregates[i]
+		value.Target = targetAggregates[i].Source
+		value.Match = reconcileValuesMatch(value.Source, value.Target, opts.Tolerance)
+		if value.Aggregate == aggregateRowCount {
+			value.Match = value.Source == value.Target
+			result.RowCount = value
+		} else {
+			result.Aggregates = append(result.Aggregates, value)
+		}
+		result.Match = result.Match && value.Match
+	}
+
+	if opts.Buckets > 0 {
+		keyRange, err := reconcileKeyRange(result.Aggregates, opts.Key)
+		if err != nil {
+			return nil, err
+		}
+		if keyRange != nil {
+			if err := reconcileBuckets(ctx, source, target, opts, keyRange[0], keyRange[1], 0, result); err != nil {
+				return nil, err
+			}
+		}
+		result.Match = result.Match && len(result.MismatchedBuckets) == 0
+	}
+	return result, nil
+}
+
+func (app *DbqAppImpl) reconcileSide(ctx context.Context, target ReconcileTarget) (*reconcileSide, error) {
+	dataSource := app.FindDataSourceById(target.DataSource)
+	if dataSource == nil {
+		return nil, fmt.Errorf("specified data source not found in dbq configuration: %s", target.DataSource)
+	}
+	adapter, err := app.connections.Adapter(ctx, dataSource)
+	if err != nil {
+		return nil, app.redactErr(err)
+	}
+	return &reconcileSide{app: app, target: target, dataSource: dataSource, adapter: adapter}, nil
+}
+
+// queryRows runs the query returning the given columns and decodes its rows
+func (s *reconcileSide) queryRows(ctx context.Context, query string, columns []string) ([]string, [][]any, error) {
+	ctx = WithLogAttrs(ctx, slog.String("datasource", s.dataSource.ID), slog.String("dataset", s.target.Dataset))
+	jsonQuery, err := jsonRowsQuery(s.dataSource.Type, query, columns)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", s.target, err)
+	}
+
+	s.app.logger.DebugContext(ctx, "Running reconciliation query", "query", jsonQuery)
+	value, err := s.adapter.ExecuteQuery(ctx, jsonQuery)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", s.target, s.app.redactErr(err))
+	}
+	resultColumns, rows, err := parseJsonRows(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", s.target, err)
+	}
+	return resultColumns, rows, nil
+}
+
+// columnTypes lists the columns of the dataset with their types
+func (s *reconcileSide) columnTypes(ctx context.Context) ([]string, map[string]string, error) {
+	schema, table := "", s.target.Dataset
+	if idx := strings.LastIndex(table, "."); idx >= 0 {
+		schema, table = table[:idx], table[idx+1:]
+	}
+
+	var query string
+	switch strings.ToLower(s.dataSource.Type) {
+	case "clickhouse":
+		database := "currentDatabase()"
+		if schema != "" {
+			database = "'" + sqlString(schema) + "'"
+		}
+		query = fmt.Sprintf("SELECT name, type FROM system.columns WHERE database = %s AND table = '%s' ORDER BY position",
+			database, sqlString(table))
+	default:
+		tableSchema := "current_schema()"
+		if strings.ToLower(s.dataSource.Type) == "mysql" {
+			tableSchema = "DATABASE()"
+		}
+		if schema != "" {
+			tableSchema = "'" + sqlString(schema) + "'"
+		}
+		query = fmt.Sprintf("SELECT column_name AS name, data_type AS type FROM information_schema.columns "+
+			"WHERE table_schema = %s AND table_name = '%s' ORDER BY ordinal_position", tableSchema, sqlString(table))
+	}
+
+	_, rows, err := s.queryRows(ctx, query, []string{"name", "type"})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil, fmt.Errorf("%s: table not found or has no columns", s.target)
+	}
+
+	var names []string
+	types := make(map[string]string)
+	s.columns = make(map[string]string)
+	for _, row := range rows {
+		if len(row) < 2 {
+			continue
+		}
+		name := FormatJsonValue(row[0])
+		names = append(names, name)
+		types[strings.ToLower(name)] = FormatJsonValue(row[1])
+		s.columns[strings.ToLower(name)] = name
+	}
+	return names, types, nil
+}
+
+// column returns the quoted identifier of the column as defined in the dataset, names are matched
+// case-insensitively as the other side or the user may spell them differently
+func (s *reconcileSide) column(name string) string {
+	if defined, ok := s.columns[strings.ToLower(name)]; ok {
+		name = defined
+	}
+	return quoteIdentifier(s.dataSource.Type, name)
+}
+
+// reconcileColumns resolves the compared columns present on both sides, columns present on one side only
+// are reported in the result
+func reconcileColumns(ctx context.Context, source, target *reconcileSide, opts ReconcileOptions, result *ReconcileResult) ([]reconcileColumn, error) {
+	sourceNames, sourceTypes, err := source.columnTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	targetNames, targetTypes, err := target.columnTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range sourceNames {
+		if _, ok := targetTypes[strings.ToLower(name)]; !ok {
+			result.SourceOnlyColumns = append(result.SourceOnlyColumns, name)
+		}
+	}
+	for _, name := range targetNames {
+		if _, ok := sourceTypes[strings.ToLower(name)]; !ok {
+			result.TargetOnlyColumns = append(result.TargetOnlyColumns, name)
+		}
+	}
+
+	names := opts.Columns
+	if len(names) == 0 {
+		names = sourceNames
+	}
+	if !slices.ContainsFunc(names, func(name string) bool { return strings.EqualFold(name, opts.Key) }) {
+		names = append([]string{opts.Key}, names...)
+	}
+
+	var columns []reconcileColumn
+	for _, name := range names {
+		sourceType, inSource := sourceTypes[strings.ToLower(name)]
+		targetType, inTarget := targetTypes[strings.ToLower(name)]
+		if !inSource || !inTarget {
+			// explicitly requested columns have to be present on both sides
+			if len(opts.Columns) > 0 || strings.EqualFold(name, opts.Key) {
+				missingIn := opts.Source
+				if inSource {
+					missingIn = opts.Target
+				}
+				return nil, fmt.Errorf("column '%s' is missing in %s", name, missingIn)
+			}
+			continue
+		}
+		columns = append(columns, reconcileColumn{name: name, sourceType: sourceType, targetType: targetType})
+	}
+	return columns, nil
+}
+
+// aggregates computes the row count and aggregates of every column, values are returned in the Source field.
+// Both sides compute the same aggregates in the same order
+func (s *reconcileSide) aggregates(ctx context.Context, columns []reconcileColumn, opts ReconcileOptions) ([]ReconcileValue, error) {
+	values := []ReconcileValue{{Aggregate: aggregateRowCount}}
+	expressions := []string{"COUNT(*)"}
+	for _, column := range columns {
+		// aggregates are computed only if they are meaningful for the column types of both sides
+		numeric := isNumericColumnType(column.sourceType) && isNumericColumnType(column.targetType)
+		ordered := numeric || (isTemporalColumnType(column.sourceType) && isTemporalColumnType(column.targetType))
+		identifier := s.column(column.name)
+		if numeric {
+			values = append(values, ReconcileValue{Column: column.name, Aggregate: aggregateSum})
+			expressions = append(expressions, fmt.Sprintf("SUM(%s)", identifier))
+		}
+		if ordered {
+			values = append(values, ReconcileValue{Column: column.name, Aggregate: aggregateMin}, ReconcileValue{Column: column.name, Aggregate: aggregateMax})
+			expressions = append(expressions, fmt.Sprintf("MIN(%s)", identifier), fmt.Sprintf("MAX(%s)", identifier))
+		}
+		values = append(values, ReconcileValue{Column: column.name, Aggregate: aggregateNulls})
+		expressions = append(expressions, fmt.Sprintf("COUNT(*) - COUNT(%s)", identifier))
+	}
+
+	aliases := make([]string, len(expressions))
+	for i := range expressions {
+		aliases[i] = "a" + strconv.Itoa(i)
+		expressions[i] += " AS " + aliases[i]
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s%s", strings.Join(expressions, ", "), s.target.Dataset, whereClause(opts.Where))
+
+	resultColumns, rows, err := s.queryRows(ctx, query, aliases)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) != 1 {
+		return nil, fmt.Errorf("%s: expected a single row of aggregates, got %d", s.target, len(rows))
+	}
+	for i, alias := range aliases {
+		idx := slices.Index(resultColumns, alias)
+		if idx < 0 || idx >= len(rows[0]) {
+			return nil, fmt.Errorf("%s: aggregate '%s' is missing in the result", s.target, alias)
+		}
+		values[i].Source = FormatJsonValue(rows[0][idx])
+	}
+	return values, nil
+}
+
+// bucketStats are the row count and checksum of a key range
+type bucketStats struct {
+	rows     int64
+	checksum string
+}
+
+// bucketStats computes row counts and checksums of width-wide key ranges starting from 'from'
+func (s *reconcileSide) bucketStats(ctx context.Context, opts ReconcileOptions, from, to, width int64) (map[int64]bucketStats, error) {
+	dialect := strings.ToLower(s.dataSource.Type)
+	query, err := bucketStatsQuery(dialect, s.target.Dataset, s.column(opts.Key), s.hashColumns(opts), opts.Where, from, to, width)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.target, err)
+	}
+
+	resultColumns, rows, err := s.queryRows(ctx, query, []string{"bucket", "row_count", "checksum"})
+	if err != nil {
+		return nil, err
+	}
+	bucketIdx, rowsIdx, checksumIdx := slices.Index(resultColumns, "bucket"), slices.Index(resultColumns, "row_count"), slices.Index(resultColumns, "checksum")
+	if bucketIdx < 0 || rowsIdx < 0 || checksumIdx < 0 {
+		return nil, fmt.Errorf("%s: unexpected columns of bucket checksums: %s", s.target, strings.Join(resultColumns, ", "))
+	}
+
+	stats := make(map[int64]bucketStats)
+	for _, row := range rows {
+		if len(row) <= max(bucketIdx, rowsIdx, checksumIdx) {
+			continue
+		}
+		bucket, err := strconv.ParseFloat(FormatJsonValue(row[bucketIdx]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unexpected bucket '%v'", s.target, row[bucketIdx])
+		}
+		count, err := strconv.ParseInt(FormatJsonValue(row[rowsIdx]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unexpected row count '%v'", s.target, row[rowsIdx])
+		}
+		stats[int64(bucket)] = bucketStats{rows: count, checksum: FormatJsonValue(row[checksumIdx])}
+	}
+	return stats, nil
+}
+
+// hashColumns returns the quoted key and hash columns
+func (s *reconcileSide) hashColumns(opts ReconcileOptions) []string {
+	columns := []string{s.column(opts.Key)}
+	for _, column := range opts.HashColumns {
+		columns = append(columns, s.column(column))
+	}
+	return columns
+}
+
+// bucketStatsQuery groups rows with keys within [from, to] into width-wide key ranges starting from 'from', the key
+// and the hash columns have to be quoted identifiers
+func bucketStatsQuery(dialect string, dataset string, key string, hashColumns []string, filter string, from, to, width int64) (string, error) {
+	bucket := fmt.Sprintf("FLOOR((%s - %d) / %d)", key, from, width)
+	if dialect == "clickhouse" {
+		bucket = fmt.Sprintf("intDiv(toInt64(%s) - %d, %d)", key, from, width)
+	}
+	checksum, err := checksumExpression(dialect, hashColumns)
+	if err != nil {
+		return "", err
+	}
+
+	where := fmt.Sprintf(" WHERE %s >= %d AND %s <= %d", key, from, key, to)
+	if strings.TrimSpace(filter) != "" {
+		where += " AND (" + filter + ")"
+	}
+	return fmt.Sprintf("SELECT %s AS bucket, COUNT(*) AS row_count, %s AS checksum FROM %s%s GROUP BY %s",
+		bucket, checksum, dataset, where, bucket), nil
+}
+
+// checksumExpression sums the first 32 bits of MD5 of the values joined by '|' over the rows, so the checksum
+// doesn't depend on the order of rows and matches across data sources as long as the text of the values does
+func checksumExpression(dialect string, columns []string) (string, error) {
+	var values []string
+	switch dialect {
+	case "postgresql":
+		for _, column := range columns {
+			values = append(values, fmt.Sprintf("COALESCE(%s::text, '')", column))
+		}
+		return fmt.Sprintf("SUM(('x' || SUBSTR(MD5(CONCAT_WS('|', %s)), 1, 8))::bit(32)::bigint)", strings.Join(values, ", ")), nil
+	case "mysql":
+		for _, column := range columns {
+			values = append(values, fmt.Sprintf("COALESCE(CAST(%s AS CHAR), '')", column))
+		}
+		return fmt.Sprintf("SUM(CAST(CONV(SUBSTRING(MD5(CONCAT_WS('|', %s)), 1, 8), 16, 10) AS UNSIGNED))", strings.Join(values, ", ")), nil
+	case "clickhouse":
+		for _, column := range columns {
+			values = append(values, fmt.Sprintf("ifNull(toString(%s), '')", column))
+		}
+		return fmt.Sprintf("sum(reinterpretAsUInt32(reverse(substring(MD5(concatWithSeparator('|', %s)), 1, 4))))", strings.Join(values, ", ")), nil
+	default:
+		return "", fmt.Errorf("checksums are not supported for data source type '%s'", dialect)
+	}
+}
+
+// reconcileKeyRange returns the inclusive range of keys of both sides, nil if both datasets are empty
+func reconcileKeyRange(aggregates []ReconcileValue, key string) (*[2]int64, error) {
+	var bounds []int64
+	for _, value := range aggregates {
+		if !strings.EqualFold(value.Column, key) || (value.Aggregate != aggregateMin && value.Aggregate != aggregateMax) {
+			continue
+		}
+		for _, bound := range []string{value.Source, value.Target} {
+			if bound == "" || bound == "NULL" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(bound, 64)
+			if err != nil || parsed != math.Trunc(parsed) {
+				return nil, fmt.Errorf("key '%s' must be an integer column to compare checksums of key ranges", key)
+			}
+			bounds = append(bounds, int64(parsed))
+		}
+	}
+	if len(bounds) == 0 {
+		if !slices.ContainsFunc(aggregates, func(value ReconcileValue) bool {
+			return strings.EqualFold(value.Column, key) && value.Aggregate == aggregateMin
+		}) {
+			return nil, fmt.Errorf("key '%s' must be an integer column to compare checksums of key ranges", key)
+		}
+		return nil, nil
+	}
+	return &[2]int64{slices.Min(bounds), slices.Max(bounds)}, nil
+}
+
+// reconcileBuckets compares checksums of opts.Buckets key ranges within [from, to] and recursively splits
+// the mismatching ones until opts.Depth levels are compared or a range holds a single key
+func reconcileBuckets(ctx context.Context, source, target *reconcileSide, opts ReconcileOptions, from, to int64, level int, result *ReconcileResult) error {
+	width := bucketWidth(from, to, opts.Buckets)
+	sourceStats, err := source.bucketStats(ctx, opts, from, to, width)
+	if err != nil {
+		return err
+	}
+	targetStats, err := target.bucketStats(ctx, opts, from, to, width)
+	if err != nil {
+		return err
+	}
+
+	var buckets []int64
+	for bucket := range sourceStats {
+		buckets = append(buckets, bucket)
+	}
+	for bucket := range targetStats {
+		if _, ok := sourceStats[bucket]; !ok {
+			buckets = append(buckets, bucket)
+		}
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+	result.BucketsCompared += len(buckets)
+
+	for _, bucket := range buckets {
+		sourceBucket, targetBucket := sourceStats[bucket], targetStats[bucket]
+		if sourceBucket.rows == targetBucket.rows && sourceBucket.checksum == targetBucket.checksum {
+			continue
+		}
+
+		bucketFrom, bucketTo := bucketBounds(from, to, width, bucket)
+		if level+1 < opts.Depth && bucketTo > bucketFrom {
+			if err := reconcileBuckets(ctx, source, target, opts, bucketFrom, bucketTo, level+1, result); err != nil {
+				return err
+			}
+			continue
+		}
+		result.MismatchedBuckets = append(result.MismatchedBuckets, ReconcileBucket{
+			From:           bucketFrom,
+			To:             bucketTo,
+			Level:          level,
+			SourceRows:     sourceBucket.rows,
+			TargetRows:     targetBucket.rows,
+			SourceChecksum: sourceBucket.checksum,
+			TargetChecksum: targetBucket.checksum,
+		})
+	}
+	return nil
+}
+
+// bucketWidth splits [from, to] into at most buckets key ranges of the same width, the last one may be narrower
+func bucketWidth(from, to int64, buckets int) int64 {
+	return (to - from + int64(buckets)) / int64(buckets)
+}
+
+// bucketBounds returns the inclusive key range of the bucket
+func bucketBounds(from, to, width, bucket int64) (int64, int64) {
+	bucketFrom := from + bucket*width
+	return bucketFrom, min(to, bucketFrom+width-1)
+}
+
+// reconcileValuesMatch compares aggregates as numbers (within the tolerance in percent) or timestamps if possible,
+// as data sources format them differently, e.g. '12.50' and '12.5'
+func reconcileValuesMatch(source, target string, tolerance float64) bool {
+	if source == target {
+		return true
+	}
+
+	sourceNum, sourceErr := strconv.ParseFloat(source, 64)
+	targetNum, targetErr := strconv.ParseFloat(target, 64)
+	if sourceErr == nil && targetErr == nil {
+		diff := math.Abs(sourceNum - targetNum)
+		scale := max(math.Abs(sourceNum), math.Abs(targetNum))
+		// floating point sums are allowed to differ in the last digits
+		return diff <= scale*max(tolerance/100, 1e-9)
+	}
+
+	sourceTime, sourceErr := parseReconcileTime(source)
+	targetTime, targetErr := parseReconcileTime(target)
+	return sourceErr == nil && targetErr == nil && sourceTime.Equal(targetTime)
+}
+
+var reconcileTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.DateOnly,
+}
+
+func parseReconcileTime(value string) (time.Time, error) {
+	for _, layout := range reconcileTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s'", value)
+}
+
+var numericColumnTypes = map[string]bool{
+	"smallint": true, "integer": true, "bigint": true, "tinyint": true, "mediumint": true,
+	"real": true, "float": true, "double": true, "numeric": true, "decimal": true,
+}
+
+// isNumericColumnType recognizes numeric types of all data sources, e.g. 'double precision', 'Nullable(UInt32)' or 'decimal(10,2)'
+func isNumericColumnType(columnType string) bool {
+	base := baseColumnType(columnType)
+	for _, prefix := range []string{"int", "uint", "float", "decimal"} {
+		if rest, ok := strings.CutPrefix(base, prefix); ok && strings.Trim(rest, "0123456789") == "" {
+			return true
+		}
+	}
+	return numericColumnTypes[base]
+}
+
+func isTemporalColumnType(columnType string) bool {
+	base := baseColumnType(columnType)
+	return strings.HasPrefix(base, "date") || strings.HasPrefix(base, "timestamp")
+}
+
+// baseColumnType lowercases the type name without its parameters and ClickHouse modifiers,
+// e.g. 'LowCardinality(Nullable(Decimal(10, 2)))' is 'decimal' and 'double precision' is 'double'
+func baseColumnType(columnType string) string {
+	columnType = strings.ToLower(strings.TrimSpace(columnType))
+	for {
+		unwrapped := false
+		for _, modifier := range []string{"nullable(", "lowcardinality("} {
+			if strings.HasPrefix(columnType, modifier) && strings.HasSuffix(columnType, ")") {
+				columnType = columnType[len(modifier) : len(columnType)-1]
+				unwrapped = true
+			}
+		}
+		if !unwrapped {
+			break
+		}
+	}
+	columnType, _, _ = strings.Cut(columnType, "(")
+	columnType, _, _ = strings.Cut(columnType, " ")
+	return columnType
+}
+
+// quoteIdentifier quotes the column name for the data source type, so names which are keywords (e.g. 'user')
+// or mixed-case ones are used as is
+func quoteIdentifier(dataSourceType string, name string) string {
+	switch strings.ToLower(dataSourceType) {
+	case "mysql", "clickhouse":
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	default:
+		return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	}
+}
+
+func whereClause(where string) string {
+	if strings.TrimSpace(where) == "" {
+		return ""
+	}
+	return " WHERE " + where
+}
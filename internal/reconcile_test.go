@@ -0,0 +1,280 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/DataBridgeTech/dbqcore"
+)
+
+func TestReconcileValuesMatch(t *testing.T) {
+	tests := []struct {
+		source    string
+		target    string
+		tolerance float64
+		want      bool
+	}{
+		{"1000", "1000", 0, true},
+		{"12.50", "12.5", 0, true},
+		{"100", "101", 0, false},
+		{"100", "101", 1, true},
+		{"100", "102", 1, false},
+		{"0.1", "0.10000000000000001", 0, true},
+		{"0", "0.000001", 0, false},
+		{"2024-01-01T00:00:00", "2024-01-01 00:00:00", 0, true},
+		{"2024-01-01 00:00:00+00:00", "2024-01-01T02:00:00+02:00", 0, true},
+		{"2024-01-01", "2024-01-01 00:00:00", 0, true},
+		{"2024-01-01", "2024-01-02", 0, false},
+		{"NULL", "", 0, false},
+		{"abc", "ABC", 0, false},
+	}
+
+	for _, tt := range tests {
+		if got := reconcileValuesMatch(tt.source, tt.target, tt.tolerance); got != tt.want {
+			t.Errorf("reconcileValuesMatch(%q, %q, %g) = %t, want %t", tt.source, tt.target, tt.tolerance, got, tt.want)
+		}
+	}
+}
+
+func TestReconcileKeyRange(t *testing.T) {
+	keyBounds := func(sourceMin, targetMin, sourceMax, targetMax string) []ReconcileValue {
+		return []ReconcileValue{
+			{Aggregate: aggregateRowCount, Source: "10", Target: "10"},
+			{Column: "other", Aggregate: aggregateMin, Source: "-100", Target: "-100"},
+			{Column: "ID", Aggregate: aggregateMin, Source: sourceMin, Target: targetMin},
+			{Column: "ID", Aggregate: aggregateMax, Source: sourceMax, Target: targetMax},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		aggregates []ReconcileValue
+		want       *[2]int64
+		wantErr    bool
+	}{
+		{name: "same bounds", aggregates: keyBounds("1", "1", "1000", "1000"), want: &[2]int64{1, 1000}},
+		{name: "union of both sides", aggregates: keyBounds("5", "1", "900", "1000"), want: &[2]int64{1, 1000}},
+		{name: "decimal formatted integers", aggregates: keyBounds("1.0", "1", "1000.0", "1000"), want: &[2]int64{1, 1000}},
+		{name: "target empty", aggregates: keyBounds("3", "NULL", "7", ""), want: &[2]int64{3, 7}},
+		{name: "both empty", aggregates: keyBounds("NULL", "NULL", "", "")},
+		{name: "fractional key", aggregates: keyBounds("1.5", "1", "10", "10"), wantErr: true},
+		{name: "text key", aggregates: keyBounds("a", "a", "z", "z"), wantErr: true},
+		{
+			name:       "key without min and max",
+			aggregates: []ReconcileValue{{Column: "id", Aggregate: aggregateNulls, Source: "0", Target: "0"}},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := reconcileKeyRange(tt.aggregates, "id")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reconcileKeyRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("reconcileKeyRange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBucketBounds(t *testing.T) {
+	tests := []struct {
+		from, to  int64
+		buckets   int
+		wantWidth int64
+		// wantBounds are the inclusive ranges of the buckets covering [from, to]
+		wantBounds [][2]int64
+	}{
+		{from: 1, to: 10, buckets: 2, wantWidth: 5, wantBounds: [][2]int64{{1, 5}, {6, 10}}},
+		{from: 1, to: 10, buckets: 3, wantWidth: 4, wantBounds: [][2]int64{{1, 4}, {5, 8}, {9, 10}}},
+		{from: 0, to: 0, buckets: 16, wantWidth: 1, wantBounds: [][2]int64{{0, 0}}},
+		{from: 5, to: 7, buckets: 16, wantWidth: 1, wantBounds: [][2]int64{{5, 5}, {6, 6}, {7, 7}}},
+		{from: -10, to: 9, buckets: 4, wantWidth: 5, wantBounds: [][2]int64{{-10, -6}, {-5, -1}, {0, 4}, {5, 9}}},
+	}
+
+	for _, tt := range tests {
+		width := bucketWidth(tt.from, tt.to, tt.buckets)
+		if width != tt.wantWidth {
+			t.Errorf("bucketWidth(%d, %d, %d) = %d, want %d", tt.from, tt.to, tt.buckets, width, tt.wantWidth)
+			continue
+		}
+		for bucket, want := range tt.wantBounds {
+			bucketFrom, bucketTo := bucketBounds(tt.from, tt.to, width, int64(bucket))
+			if bucketFrom != want[0] || bucketTo != want[1] {
+				t.Errorf("[%d, %d] bucket %d = [%d, %d], want %v", tt.from, tt.to, bucket, bucketFrom, bucketTo, want)
+			}
+		}
+		// the bucket following the last one starts past the range
+		if next, _ := bucketBounds(tt.from, tt.to, width, int64(len(tt.wantBounds))); next <= tt.to {
+			t.Errorf("[%d, %d] isn't covered by %d buckets", tt.from, tt.to, len(tt.wantBounds))
+		}
+	}
+}
+
+func TestBaseColumnType(t *testing.T) {
+	tests := []struct {
+		columnType string
+		want       string
+		numeric    bool
+		temporal   bool
+	}{
+		{"integer", "integer", true, false},
+		{"double precision", "double", true, false},
+		{"numeric(10,2)", "numeric", true, false},
+		{"UInt64", "uint64", true, false},
+		{"Nullable(Decimal(10, 2))", "decimal", true, false},
+		{"LowCardinality(Nullable(Float32))", "float32", true, false},
+		{"LowCardinality(String)", "string", false, false},
+		{"timestamp without time zone", "timestamp", false, true},
+		{"DateTime64(3, 'UTC')", "datetime64", false, true},
+		{"Nullable(Date)", "date", false, true},
+		{"interval", "interval", false, false},
+		{"  VARCHAR(255) ", "varchar", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := baseColumnType(tt.columnType); got != tt.want {
+			t.Errorf("baseColumnType(%q) = %q, want %q", tt.columnType, got, tt.want)
+		}
+		if got := isNumericColumnType(tt.columnType); got != tt.numeric {
+			t.Errorf("isNumericColumnType(%q) = %t, want %t", tt.columnType, got, tt.numeric)
+		}
+		if got := isTemporalColumnType(tt.columnType); got != tt.temporal {
+			t.Errorf("isTemporalColumnType(%q) = %t, want %t", tt.columnType, got, tt.temporal)
+		}
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		dataSourceType string
+		name           string
+		want           string
+	}{
+		{"postgresql", "user", `"user"`},
+		{"postgresql", "CreatedAt", `"CreatedAt"`},
+		{"postgresql", `a"b`, `"a""b"`},
+		{"mysql", "order", "`order`"},
+		{"mysql", "a`b", "`a``b`"},
+		{"ClickHouse", "Amount", "`Amount`"},
+	}
+
+	for _, tt := range tests {
+		if got := quoteIdentifier(tt.dataSourceType, tt.name); got != tt.want {
+			t.Errorf("quoteIdentifier(%q, %q) = %s, want %s", tt.dataSourceType, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBucketStatsQuery(t *testing.T) {
+	tests := []struct {
+		dialect string
+		key     string
+		want    string
+	}{
+		{
+			dialect: "postgresql",
+			key:     `"Id"`,
+			want: `SELECT FLOOR(("Id" - 1) / 10) AS bucket, COUNT(*) AS row_count, ` +
+				`SUM(('x' || SUBSTR(MD5(CONCAT_WS('|', COALESCE("Id"::text, ''), COALESCE("user"::text, ''))), 1, 8))::bit(32)::bigint) AS checksum ` +
+				`FROM public.orders WHERE "Id" >= 1 AND "Id" <= 100 AND (status = 'paid') GROUP BY FLOOR(("Id" - 1) / 10)`,
+		},
+		{
+			dialect: "clickhouse",
+			key:     "`Id`",
+			want: "SELECT intDiv(toInt64(`Id`) - 1, 10) AS bucket, COUNT(*) AS row_count, " +
+				"sum(reinterpretAsUInt32(reverse(substring(MD5(concatWithSeparator('|', ifNull(toString(`Id`), ''), ifNull(toString(`user`), ''))), 1, 4)))) AS checksum " +
+				"FROM public.orders WHERE `Id` >= 1 AND `Id` <= 100 AND (status = 'paid') GROUP BY intDiv(toInt64(`Id`) - 1, 10)",
+		},
+		{
+			dialect: "mysql",
+			key:     "`Id`",
+			want: "SELECT FLOOR((`Id` - 1) / 10) AS bucket, COUNT(*) AS row_count, " +
+				"SUM(CAST(CONV(SUBSTRING(MD5(CONCAT_WS('|', COALESCE(CAST(`Id` AS CHAR), ''), COALESCE(CAST(`user` AS CHAR), ''))), 1, 8), 16, 10) AS UNSIGNED)) AS checksum " +
+				"FROM public.orders WHERE `Id` >= 1 AND `Id` <= 100 AND (status = 'paid') GROUP BY FLOOR((`Id` - 1) / 10)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			hashColumns := []string{tt.key, quoteIdentifier(tt.dialect, "user")}
+			got, err := bucketStatsQuery(tt.dialect, "public.orders", tt.key, hashColumns, "status = 'paid'", 1, 100, 10)
+			if err != nil {
+				t.Fatalf("bucketStatsQuery() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("query mismatch\n got: %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := bucketStatsQuery("oracle", "t", "id", []string{"id"}, "", 1, 2, 1); err == nil {
+		t.Error("expected an error for an unsupported data source type")
+	}
+}
+
+// queryRecorder records queries and returns a fixed result
+type queryRecorder struct {
+	queries []string
+	result  string
+}
+
+func (r *queryRecorder) InterpretDataQualityCheck(check *dbqcore.DataQualityCheck, dataset string, defaultWhere string) (string, error) {
+	return "", nil
+}
+
+func (r *queryRecorder) ExecuteQuery(ctx context.Context, query string) (string, error) {
+	r.queries = append(r.queries, query)
+	return r.result, nil
+}
+
+func TestReconcileAggregatesQuotesColumns(t *testing.T) {
+	adapter := &queryRecorder{result: `[{"a0":3,"a1":6,"a2":1,"a3":3,"a4":0,"a5":"2024-01-01T00:00:00","a6":"2024-01-03T00:00:00","a7":1}]`}
+	side := &reconcileSide{
+		app:        &DbqAppImpl{logger: slog.New(slog.DiscardHandler)},
+		target:     ReconcileTarget{DataSource: "pg", Dataset: "public.orders"},
+		dataSource: &dbqcore.DataSource{ID: "pg", Type: "postgresql"},
+		adapter:    adapter,
+		columns:    map[string]string{"orderid": "OrderId", "user": "user"},
+	}
+	columns := []reconcileColumn{
+		// requested as 'orderid', the name as defined in the dataset is used
+		{name: "orderid", sourceType: "integer", targetType: "UInt64"},
+		{name: "user", sourceType: "timestamp", targetType: "DateTime"},
+	}
+
+	values, err := side.aggregates(context.Background(), columns, ReconcileOptions{Where: "status = 'paid'"})
+	if err != nil {
+		t.Fatalf("aggregates() error = %v", err)
+	}
+
+	wantQuery := `SELECT COUNT(*) AS a0, SUM("OrderId") AS a1, MIN("OrderId") AS a2, MAX("OrderId") AS a3, COUNT(*) - COUNT("OrderId") AS a4, ` +
+		`MIN("user") AS a5, MAX("user") AS a6, COUNT(*) - COUNT("user") AS a7 FROM public.orders WHERE status = 'paid'`
+	if len(adapter.queries) != 1 || !strings.Contains(adapter.queries[0], wantQuery) {
+		t.Fatalf("queries = %v, want one containing %s", adapter.queries, wantQuery)
+	}
+	if len(values) != 8 {
+		t.Fatalf("got %d values, want 8", len(values))
+	}
+	if values[1].Column != "orderid" || values[1].Aggregate != aggregateSum {
+		t.Errorf("values[1] = %+v", values[1])
+	}
+}
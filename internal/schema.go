@@ -0,0 +1,333 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DataBridgeTech/dbqcore"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SchemaDbqConfig  = "dbq"
+	SchemaChecksFile = "checks"
+)
+
+//go:embed schema/*.schema.json
+var schemaFiles embed.FS
+
+// SchemaNames lists the JSON Schemas available with GetJsonSchema
+var SchemaNames = []string{SchemaDbqConfig, SchemaChecksFile}
+
+// GetJsonSchema returns the JSON Schema of dbq config ("dbq") or checks file ("checks"), e.g. for editor autocompletion
+func GetJsonSchema(name string) ([]byte, error) {
+	data, err := schemaFiles.ReadFile("schema/" + name + ".schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("unknown schema '%s' (expected one of: %s)", name, strings.Join(SchemaNames, ", "))
+	}
+	return data, nil
+}
+
+// UnknownKey is a mapping key not recognized in a config or checks file
+type UnknownKey struct {
+	File   string
+	Path   string
+	Line   int
+	Column int
+}
+
+func (k UnknownKey) String() string {
+	return fmt.Sprintf("%s:%d:%d: unknown key '%s'", k.File, k.Line, k.Column, k.Path)
+}
+
+// UnknownKeysError is returned by strict loading of config and checks files
+type UnknownKeysError struct {
+	Keys []UnknownKey
+}
+
+func (e *UnknownKeysError) Error() string {
+	return strings.Join(e.Messages(), "\n") + "\n(use --lenient to ignore unknown keys)"
+}
+
+// Messages returns one 'file:line:column: unknown key' message per key
+func (e *UnknownKeysError) Messages() []string {
+	messages := make([]string, len(e.Keys))
+	for i, key := range e.Keys {
+		messages[i] = key.String()
+	}
+	return messages
+}
+
+// keySpec describes the known keys of a yaml node, a nil spec accepts any value
+type keySpec struct {
+	// fields are the known keys of a mapping
+	fields map[string]*keySpec
+	// items is the spec of list items
+	items *keySpec
+	// values is the spec of values of a mapping with arbitrary keys
+	values *keySpec
+	// ignoreCase matches keys case-insensitively, as viper does for dbq config
+	ignoreCase bool
+}
+
+// keySpecOf derives the known keys from yaml (or mapstructure) tags of the type
+func keySpecOf(t reflect.Type, ignoreCase bool) *keySpec {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return keySpecOf(t.Elem(), ignoreCase)
+	case reflect.Slice, reflect.Array:
+		if itemSpec := keySpecOf(t.Elem(), ignoreCase); itemSpec != nil {
+			return &keySpec{items: itemSpec}
+		}
+		return nil
+	case reflect.Map:
+		if valueSpec := keySpecOf(t.Elem(), ignoreCase); valueSpec != nil {
+			return &keySpec{values: valueSpec}
+		}
+		return nil
+	case reflect.Struct:
+		if t.Implements(reflect.TypeFor[yaml.Unmarshaler]()) || reflect.PointerTo(t).Implements(reflect.TypeFor[yaml.Unmarshaler]()) {
+			// custom format, can't be derived from the fields
+			return nil
+		}
+		spec := &keySpec{fields: make(map[string]*keySpec), ignoreCase: ignoreCase}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "" {
+				name = strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+			}
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			spec.fields[name] = keySpecOf(field.Type, ignoreCase)
+		}
+		return spec
+	default:
+		return nil
+	}
+}
+
+func (s *keySpec) field(key string) (*keySpec, bool) {
+	if fieldSpec, ok := s.fields[key]; ok || !s.ignoreCase {
+		return fieldSpec, ok
+	}
+	for name, fieldSpec := range s.fields {
+		if strings.EqualFold(name, key) {
+			return fieldSpec, true
+		}
+	}
+	return nil, false
+}
+
+// configKeySpec describes dbq config keys: dbqcore config, dbqctl settings and environment overrides
+func configKeySpec() *keySpec {
+	spec := keySpecOf(reflect.TypeFor[dbqcore.DbqConfig](), true)
+	for name, fieldSpec := range keySpecOf(reflect.TypeFor[CliConfig](), true).fields {
+		spec.fields[name] = fieldSpec
+	}
+
+	spec.fields["environments"] = &keySpec{
+		values: &keySpec{
+			fields:     map[string]*keySpec{"datasources": spec.fields["datasources"]},
+			ignoreCase: true,
+		},
+	}
+	return spec
+}
+
+// nodeFiles tells the file a yaml node was read from, nodes of included checks files and expanded templates
+// are listed in sources, any other node is from the main file
+type nodeFiles struct {
+	main    string
+	sources map[*yaml.Node]string
+}
+
+func (f nodeFiles) of(node *yaml.Node) string {
+	if file, ok := f.sources[node]; ok {
+		return file
+	}
+	return f.main
+}
+
+// findUnknownKeys reports mapping keys of the node missing in the spec, files are only used for reporting
+func findUnknownKeys(files nodeFiles, node *yaml.Node, spec *keySpec, path string) []UnknownKey {
+	if node == nil || spec == nil {
+		return nil
+	}
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return findUnknownKeys(files, node.Content[0], spec, path)
+	}
+
+	var unknown []UnknownKey
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := joinPath(path, key.Value)
+			if spec.values != nil {
+				unknown = append(unknown, findUnknownKeys(files, value, spec.values, keyPath)...)
+				continue
+			}
+			if spec.fields == nil {
+				continue
+			}
+			fieldSpec, ok := spec.field(key.Value)
+			if !ok {
+				unknown = append(unknown, UnknownKey{File: files.of(key), Path: keyPath, Line: key.Line, Column: key.Column})
+				continue
+			}
+			unknown = append(unknown, findUnknownKeys(files, value, fieldSpec, keyPath)...)
+		}
+	case yaml.SequenceNode:
+		if spec.items != nil {
+			for i, item := range node.Content {
+				unknown = append(unknown, findUnknownKeys(files, item, spec.items, path+"["+strconv.Itoa(i)+"]")...)
+			}
+		}
+	}
+	return unknown
+}
+
+// coreCheckKeys are check settings decoded by dbqcore, see checkSettingsKeys for the ones handled by dbqctl
+var coreCheckKeys = map[string]bool{
+	"desc":  true,
+	"query": true,
+}
+
+// schemaCheckKeys lists schema_check rules with their settings
+var schemaCheckKeys = map[string]*keySpec{
+	"expect_columns_ordered": {fields: map[string]*keySpec{"columns_order": nil}},
+	"expect_columns":         {fields: map[string]*keySpec{"columns": nil}},
+	"columns_not_present":    {fields: map[string]*keySpec{"columns": nil, "pattern": nil}},
+}
+
+// checkSettingsSpec describes the settings of a check, both the ones decoded by dbqcore and by dbqctl
+func checkSettingsSpec() *keySpec {
+	spec := keySpecOf(reflect.TypeFor[checkSettings](), false)
+	for key := range coreCheckKeys {
+		spec.fields[key] = nil
+	}
+	return spec
+}
+
+// checksFileKeySpec describes the keys of a checks file with includes and templates expanded, checks are
+// mappings keyed by their expression, so their settings are checked separately (see checkSettingsSpec)
+func checksFileKeySpec() *keySpec {
+	ruleSpec := keySpecOf(reflect.TypeFor[ruleSettings](), false)
+	for key, fieldSpec := range map[string]*keySpec{"dataset": nil, "where": nil, "checks": {}} {
+		ruleSpec.fields[key] = fieldSpec
+	}
+	return &keySpec{fields: map[string]*keySpec{"version": nil, "rules": {items: ruleSpec}}}
+}
+
+// findUnknownChecksFileKeys reports unknown keys of the checks file (with includes and templates expanded),
+// including check settings and schema_check rules, along with the ones found by the expansion
+func findUnknownChecksFileKeys(files nodeFiles, root *yaml.Node, expansionUnknown []UnknownKey) []UnknownKey {
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+
+	unknown := findUnknownKeys(files, doc, checksFileKeySpec(), "")
+
+	settingsSpec := checkSettingsSpec()
+	for ruleIdx, ruleNode := range sequenceItems(mappingValue(doc, "rules")) {
+		for checkIdx, checkNode := range sequenceItems(mappingValue(ruleNode, "checks")) {
+			if checkNode.Kind != yaml.MappingNode || len(checkNode.Content) < 2 {
+				continue
+			}
+			path := fmt.Sprintf("rules[%d].checks[%d]", ruleIdx, checkIdx)
+			exprNode, bodyNode := checkNode.Content[0], checkNode.Content[1]
+
+			// settings next to the expression, e.g. 'desc' of a schema_check
+			outer := &yaml.Node{Kind: yaml.MappingNode, Content: checkNode.Content[2:]}
+			unknown = append(unknown, findUnknownKeys(files, outer, settingsSpec, path)...)
+
+			if strings.TrimSpace(exprNode.Value) == CheckFuncSchemaCheck {
+				schemaSpec := &keySpec{fields: schemaCheckKeys}
+				unknown = append(unknown, findUnknownKeys(files, bodyNode, schemaSpec, joinPath(path, exprNode.Value))...)
+			} else {
+				unknown = append(unknown, findUnknownKeys(files, bodyNode, settingsSpec, joinPath(path, exprNode.Value))...)
+			}
+		}
+	}
+
+	unknown = uniqueUnknownKeys(append(expansionUnknown, unknown...))
+	sortUnknownKeys(unknown, files.main)
+	return unknown
+}
+
+// findUnknownConfigKeys reports keys of the dbq config files which are known neither to dbqcore nor to dbqctl
+func findUnknownConfigKeys(paths []string) ([]UnknownKey, error) {
+	spec := configKeySpec()
+	var unknown []UnknownKey
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+
+		var root yaml.Node
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+		unknown = append(unknown, findUnknownKeys(nodeFiles{main: path}, &root, spec, "")...)
+	}
+	return unknown, nil
+}
+
+// sortUnknownKeys orders keys by their location, keys of the main file go first
+func sortUnknownKeys(unknown []UnknownKey, main string) {
+	sort.SliceStable(unknown, func(i, j int) bool {
+		if unknown[i].File != unknown[j].File {
+			return unknown[i].File == main || (unknown[j].File != main && unknown[i].File < unknown[j].File)
+		}
+		return unknown[i].Line < unknown[j].Line
+	})
+}
+
+// uniqueUnknownKeys drops repeated reports of the same key, e.g. of a template used by several rules
+func uniqueUnknownKeys(unknown []UnknownKey) []UnknownKey {
+	type location struct {
+		file         string
+		line, column int
+	}
+	seen := make(map[location]bool, len(unknown))
+	unique := unknown[:0]
+	for _, key := range unknown {
+		if loc := (location{key.File, key.Line, key.Column}); !seen[loc] {
+			seen[loc] = true
+			unique = append(unique, key)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,304 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// jsonSchema is the subset of JSON Schema needed to derive the known keys
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Items                *jsonSchema            `json:"items"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	OneOf                []*jsonSchema          `json:"oneOf"`
+	Definitions          map[string]*jsonSchema `json:"definitions"`
+}
+
+func loadJsonSchema(t *testing.T, name string) *jsonSchema {
+	t.Helper()
+	data, err := GetJsonSchema(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema jsonSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("schema %s: %v", name, err)
+	}
+	return &schema
+}
+
+// definition resolves the reference, e.g. '#/definitions/anomaly'
+func (s *jsonSchema) definition(t *testing.T, root *jsonSchema) *jsonSchema {
+	t.Helper()
+	if s == nil || s.Ref == "" {
+		return s
+	}
+	definition, ok := root.Definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+	if !ok {
+		t.Fatalf("unresolved schema reference %s", s.Ref)
+	}
+	return definition.definition(t, root)
+}
+
+// keySpec derives the known keys of the schema the way keySpecOf does from types, values without keys are nil
+func (s *jsonSchema) keySpec(t *testing.T, root *jsonSchema) *keySpec {
+	t.Helper()
+	s = s.definition(t, root)
+	if s == nil {
+		return nil
+	}
+	if len(s.Properties) > 0 {
+		spec := &keySpec{fields: make(map[string]*keySpec)}
+		for name, property := range s.Properties {
+			spec.fields[name] = property.keySpec(t, root)
+		}
+		return spec
+	}
+	if s.Items != nil {
+		if itemSpec := s.Items.keySpec(t, root); itemSpec != nil {
+			return &keySpec{items: itemSpec}
+		}
+		return nil
+	}
+	var additional jsonSchema
+	if len(s.AdditionalProperties) > 0 && json.Unmarshal(s.AdditionalProperties, &additional) == nil {
+		if valueSpec := additional.keySpec(t, root); valueSpec != nil {
+			return &keySpec{values: valueSpec}
+		}
+		return nil
+	}
+	for _, variant := range s.OneOf {
+		if spec := variant.keySpec(t, root); spec != nil {
+			return spec
+		}
+	}
+	return nil
+}
+
+// compareKeySpecs reports keys known to the code but missing in the schema and the other way around
+func compareKeySpecs(t *testing.T, path string, code *keySpec, schema *keySpec) {
+	t.Helper()
+	if (code == nil) != (schema == nil) {
+		t.Errorf("%s: known keys differ, code %v, schema %v", path, code != nil, schema != nil)
+		return
+	}
+	if code == nil {
+		return
+	}
+
+	if (code.items == nil) != (schema.items == nil) || (code.values == nil) != (schema.values == nil) {
+		t.Errorf("%s: list items or mapping values differ between code and schema", path)
+		return
+	}
+	if code.items != nil {
+		compareKeySpecs(t, path+"[]", code.items, schema.items)
+	}
+	if code.values != nil {
+		compareKeySpecs(t, path+".*", code.values, schema.values)
+	}
+
+	var missing, extra []string
+	for name, fieldSpec := range code.fields {
+		schemaField, ok := schema.fields[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		compareKeySpecs(t, joinPath(path, name), fieldSpec, schemaField)
+	}
+	for name := range schema.fields {
+		if _, ok := code.fields[name]; !ok {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	if len(missing) > 0 {
+		t.Errorf("%s: keys missing in the schema: %v", path, missing)
+	}
+	if len(extra) > 0 {
+		t.Errorf("%s: keys unknown to the code: %v", path, extra)
+	}
+}
+
+func TestDbqConfigSchemaMatchesConfigKeys(t *testing.T) {
+	schema := loadJsonSchema(t, SchemaDbqConfig)
+	compareKeySpecs(t, "dbq", configKeySpec(), schema.keySpec(t, schema))
+}
+
+func TestChecksFileSchemaMatchesChecksFileKeys(t *testing.T) {
+	schema := loadJsonSchema(t, SchemaChecksFile)
+	schemaSpec := schema.keySpec(t, schema)
+
+	// include, templates and use are expanded before the keys of the checks file are checked
+	for _, key := range []string{"include", "templates"} {
+		if _, ok := schemaSpec.fields[key]; !ok {
+			t.Errorf("schema lacks '%s'", key)
+		}
+		delete(schemaSpec.fields, key)
+	}
+	ruleSpec := schemaSpec.fields["rules"].items
+	if _, ok := ruleSpec.fields["use"]; !ok {
+		t.Error("schema lacks 'rules[].use'")
+	}
+	delete(ruleSpec.fields, "use")
+
+	// checks are mappings keyed by their expression, their settings are compared below
+	checkSpec := ruleSpec.fields["checks"].items
+	ruleSpec.fields["checks"] = &keySpec{}
+	codeSpec := checksFileKeySpec()
+	codeSpec.fields["rules"].items.fields["checks"] = &keySpec{}
+	compareKeySpecs(t, "checks", codeSpec, schemaSpec)
+
+	compareKeySpecs(t, "checkSettings", checkSettingsSpec(), (&jsonSchema{Ref: "#/definitions/checkSettings"}).keySpec(t, schema))
+	compareKeySpecs(t, "schemaCheck", &keySpec{fields: schemaCheckKeys}, checkSpec.fields[CheckFuncSchemaCheck])
+
+	// settings can also be given next to the expression, e.g. 'desc' of a schema_check
+	delete(checkSpec.fields, CheckFuncSchemaCheck)
+	compareKeySpecs(t, "check", checkSettingsSpec(), checkSpec)
+}
+
+func TestLoadChecksFileUnknownKeys(t *testing.T) {
+	dir := writeChecksFiles(t, map[string]string{
+		"checks.yaml": `version: "1"
+include: [packs/shared.yaml]
+templates:
+  pk:
+    descr: primary key
+    checks:
+      - uniqueness(id):
+          on_fial: warn
+rules:
+  - dataset: pg@[public.orders]
+    tag: [sales]
+    use: [pk]
+    checks:
+      - row_count > 0:
+          desc: has rows
+          samples: 5
+      - schema_check:
+          expect_columns:
+            columns: [id]
+            order: true
+        desc: columns
+        owner: me
+`,
+		"packs/shared.yaml": `templates:
+  audit:
+    checks:
+      - not_null(created_at):
+          anomaly:
+            metod: stddev
+rules:
+  - dataset: pg@[public.customers]
+    use: [audit]
+    filter: active
+extra: 1
+`,
+	})
+	path := filepath.Join(dir, "checks.yaml")
+	shared := filepath.Join(dir, "packs", "shared.yaml")
+
+	_, err := LoadChecksFile(path, false)
+	var unknownErr *UnknownKeysError
+	if !errors.As(err, &unknownErr) {
+		t.Fatalf("LoadChecksFile() error = %v, want UnknownKeysError", err)
+	}
+
+	want := []string{
+		path + ":5:5: unknown key 'templates.pk.descr'",
+		path + ":8:11: unknown key 'rules[1].checks[0].uniqueness(id).on_fial'",
+		path + ":11:5: unknown key 'rules[1].tag'",
+		path + ":16:11: unknown key 'rules[1].checks[1].row_count > 0.samples'",
+		path + ":20:13: unknown key 'rules[1].checks[2].schema_check.expect_columns.order'",
+		path + ":22:9: unknown key 'rules[1].checks[2].owner'",
+		shared + ":6:13: unknown key 'rules[0].checks[0].not_null(created_at).anomaly.metod'",
+		shared + ":10:5: unknown key 'rules[0].filter'",
+		shared + ":11:1: unknown key 'extra'",
+	}
+	got := unknownErr.Messages()
+	if !slices.Equal(got, want) {
+		t.Errorf("unknown keys =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.HasSuffix(err.Error(), "(use --lenient to ignore unknown keys)") {
+		t.Errorf("error doesn't mention --lenient: %v", err)
+	}
+
+	checksFile, err := LoadChecksFile(path, true)
+	if err != nil {
+		t.Fatalf("lenient LoadChecksFile() error = %v", err)
+	}
+	if !slices.Equal(checksFile.Warnings, want) {
+		t.Errorf("lenient warnings =\n%s\nwant\n%s", strings.Join(checksFile.Warnings, "\n"), strings.Join(want, "\n"))
+	}
+	if len(checksFile.Rules) != 2 || len(checksFile.Rules[1].Checks) != 3 {
+		t.Errorf("lenient LoadChecksFile() rules = %+v", checksFile.Rules)
+	}
+}
+
+func TestConfigUnknownKeys(t *testing.T) {
+	dir := writeChecksFiles(t, map[string]string{
+		"dbq.yaml": `version: "1"
+DataSources:
+  - id: pg
+    type: postgresql
+    configuration:
+      host: localhost
+      hots: db
+history:
+  enabled: true
+`,
+		"local/dbq.local.yaml": `environments:
+  staging:
+    datasources:
+      - id: pg
+        configuraton:
+          host: staging
+failed_rows:
+  mask_column: [email]
+`,
+	})
+	paths := []string{filepath.Join(dir, "dbq.yaml"), filepath.Join(dir, "local", "dbq.local.yaml")}
+
+	want := []string{
+		paths[0] + ":7:7: unknown key 'DataSources[0].configuration.hots'",
+		paths[1] + ":5:9: unknown key 'environments.staging.datasources[0].configuraton'",
+		paths[1] + ":8:3: unknown key 'failed_rows.mask_column'",
+	}
+	_, _, _, _, err := initConfig(paths, "", false)
+	var unknownErr *UnknownKeysError
+	if !errors.As(err, &unknownErr) {
+		t.Fatalf("initConfig() error = %v, want UnknownKeysError", err)
+	}
+	if got := unknownErr.Messages(); !slices.Equal(got, want) {
+		t.Errorf("unknown keys =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	dbqConfig, _, _, _, err := initConfig(paths, "", true)
+	if err != nil {
+		t.Fatalf("lenient initConfig() error = %v", err)
+	}
+	if len(dbqConfig.DataSources) != 1 || dbqConfig.DataSources[0].Configuration.Host != "localhost" {
+		t.Errorf("lenient config data sources = %+v", dbqConfig.DataSources)
+	}
+}
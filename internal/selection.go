@@ -0,0 +1,97 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"path"
+	"slices"
+)
+
+// CheckSelector selects the checks of a run, an empty selector selects every check. Checks are selected
+// when they match every given criterion, lists of values match if any of the values matches
+type CheckSelector struct {
+	DataSources []string
+	// Datasets are glob patterns matched against dataset names, e.g. 'nyc_taxi.*'
+	Datasets    []string
+	Tags        []string
+	ExcludeTags []string
+	// CheckIds are ids of checks, or ids of rules to select all their checks
+	CheckIds []string
+	// FailedCheckKeys restricts the selection to checks which failed in a previous run (see HistoryCheckKey), unused if nil
+	FailedCheckKeys map[string]bool
+	// Environment is used to build keys of selected checks for FailedCheckKeys
+	Environment string
+}
+
+// Validate reports invalid dataset patterns
+func (s *CheckSelector) Validate() error {
+	for _, pattern := range s.Datasets {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid dataset pattern '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// SelectsDataSource reports whether checks of the data source can be selected at all
+func (s *CheckSelector) SelectsDataSource(dataSourceId string) bool {
+	return len(s.DataSources) == 0 || slices.Contains(s.DataSources, dataSourceId)
+}
+
+// Selects reports whether the check of the rule is selected for the dataset
+func (s *CheckSelector) Selects(dataSourceId string, dataset string, rule *ChecksRule, check *Check) bool {
+	if !s.SelectsDataSource(dataSourceId) {
+		return false
+	}
+
+	if len(s.Datasets) > 0 && !slices.ContainsFunc(s.Datasets, func(pattern string) bool {
+		matched, _ := path.Match(pattern, dataset)
+		return matched
+	}) {
+		return false
+	}
+
+	if len(s.Tags) > 0 && !slices.ContainsFunc(s.Tags, func(tag string) bool { return slices.Contains(check.Tags, tag) }) {
+		return false
+	}
+	if slices.ContainsFunc(s.ExcludeTags, func(tag string) bool { return slices.Contains(check.Tags, tag) }) {
+		return false
+	}
+
+	if len(s.CheckIds) > 0 && !slices.Contains(s.CheckIds, check.ID) && !slices.Contains(s.CheckIds, rule.ID) {
+		return false
+	}
+
+	if s.FailedCheckKeys != nil {
+		checkKey := HistoryCheckKey(s.Environment, dataSourceId, dataset, check.Expression, check.Description)
+		if !s.FailedCheckKeys[checkKey] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FailedCheckKeys returns keys of checks which failed (or errored) among the results of a run
+func FailedCheckKeys(results []HistoryCheckResult) map[string]bool {
+	failed := make(map[string]bool)
+	for _, result := range results {
+		if !result.Pass {
+			failed[result.CheckKey] = true
+		}
+	}
+	return failed
+}
@@ -0,0 +1,109 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/DataBridgeTech/dbqcore"
+)
+
+func TestCheckSelectorSelects(t *testing.T) {
+	rule := &ChecksRule{ID: "orders", Tags: []string{"sales"}}
+	check := &Check{
+		DataQualityCheck: dbqcore.DataQualityCheck{Expression: "row_count > 0"},
+		ID:               "orders.1",
+		Tags:             []string{"sales", "freshness"},
+	}
+	unnamedRule := &ChecksRule{}
+	unnamedCheck := &Check{DataQualityCheck: dbqcore.DataQualityCheck{Expression: "not_null(id)"}, ID: "2.3"}
+
+	tests := []struct {
+		name     string
+		selector CheckSelector
+		rule     *ChecksRule
+		check    *Check
+		want     bool
+	}{
+		{name: "empty selector", want: true},
+		{name: "data source", selector: CheckSelector{DataSources: []string{"ch", "pg"}}, want: true},
+		{name: "other data source", selector: CheckSelector{DataSources: []string{"ch"}}},
+		{name: "dataset glob", selector: CheckSelector{Datasets: []string{"nyc.*", "public.*"}}, want: true},
+		{name: "exact dataset", selector: CheckSelector{Datasets: []string{"public.orders"}}, want: true},
+		{name: "glob doesn't match", selector: CheckSelector{Datasets: []string{"public.ord"}}},
+		{name: "any tag", selector: CheckSelector{Tags: []string{"pii", "freshness"}}, want: true},
+		{name: "no tag matches", selector: CheckSelector{Tags: []string{"pii"}}},
+		{name: "excluded tag", selector: CheckSelector{ExcludeTags: []string{"freshness"}}},
+		{name: "excluded tag wins over tag", selector: CheckSelector{Tags: []string{"sales"}, ExcludeTags: []string{"freshness"}}},
+		{name: "unrelated excluded tag", selector: CheckSelector{ExcludeTags: []string{"pii"}}, want: true},
+		{name: "check id", selector: CheckSelector{CheckIds: []string{"orders.1"}}, want: true},
+		{name: "rule id selects its checks", selector: CheckSelector{CheckIds: []string{"orders"}}, want: true},
+		{name: "other check id", selector: CheckSelector{CheckIds: []string{"orders.2", "customers"}}},
+		{name: "check id prefix isn't a rule id", selector: CheckSelector{CheckIds: []string{"order"}}},
+		{name: "positional id", selector: CheckSelector{CheckIds: []string{"2.3"}}, rule: unnamedRule, check: unnamedCheck, want: true},
+		{name: "empty rule id doesn't select unnamed rules", selector: CheckSelector{CheckIds: []string{"2.1"}}, rule: unnamedRule, check: unnamedCheck},
+		{
+			name:     "all criteria match",
+			selector: CheckSelector{DataSources: []string{"pg"}, Datasets: []string{"public.*"}, Tags: []string{"sales"}, CheckIds: []string{"orders"}},
+			want:     true,
+		},
+		{
+			name:     "one criterion doesn't match",
+			selector: CheckSelector{DataSources: []string{"pg"}, Datasets: []string{"public.*"}, Tags: []string{"sales"}, CheckIds: []string{"customers"}},
+		},
+		{
+			name:     "failed in previous run",
+			selector: CheckSelector{FailedCheckKeys: map[string]bool{HistoryCheckKey("", "pg", "public.orders", "row_count > 0", ""): true}},
+			want:     true,
+		},
+		{
+			name:     "failed in another environment",
+			selector: CheckSelector{Environment: "prod", FailedCheckKeys: map[string]bool{HistoryCheckKey("", "pg", "public.orders", "row_count > 0", ""): true}},
+		},
+		{name: "nothing failed in previous run", selector: CheckSelector{FailedCheckKeys: map[string]bool{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRule, testCheck := rule, check
+			if tt.rule != nil {
+				testRule, testCheck = tt.rule, tt.check
+			}
+			if got := tt.selector.Selects("pg", "public.orders", testRule, testCheck); got != tt.want {
+				t.Errorf("Selects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSelectorValidate(t *testing.T) {
+	if err := (&CheckSelector{Datasets: []string{"public.*", "nyc_?"}}).Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if err := (&CheckSelector{Datasets: []string{"public.[orders"}}).Validate(); err == nil {
+		t.Error("Validate() of a malformed pattern didn't fail")
+	}
+}
+
+func TestFailedCheckKeys(t *testing.T) {
+	failed := FailedCheckKeys([]HistoryCheckResult{
+		{CheckKey: "passed", Pass: true},
+		{CheckKey: "failed"},
+		{CheckKey: "errored", Error: "timeout"},
+	})
+	if len(failed) != 2 || !failed["failed"] || !failed["errored"] {
+		t.Errorf("FailedCheckKeys() = %v", failed)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Severity of a failed check, set with the check 'on_fail' setting
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarn     Severity = "warn"
+	SeverityError    Severity = "error"
+	SeverityCritical Severity = "critical"
+)
+
+// Severities lists severities from the lowest to the highest
+var Severities = []Severity{SeverityInfo, SeverityWarn, SeverityError, SeverityCritical}
+
+// ParseSeverity parses the severity name, an empty value is the default 'error' severity
+func ParseSeverity(value string) (Severity, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return SeverityError, nil
+	}
+	if severity := Severity(value); slices.Contains(Severities, severity) {
+		return severity, nil
+	}
+	return "", fmt.Errorf("unknown severity '%s' (expected one of: %s)", value, severityNames())
+}
+
+// AtLeast reports whether the severity is the same or higher than the other one
+func (s Severity) AtLeast(other Severity) bool {
+	return slices.Index(Severities, s) >= slices.Index(Severities, other)
+}
+
+func severityNames() string {
+	names := make([]string, len(Severities))
+	for i, severity := range Severities {
+		names[i] = string(severity)
+	}
+	return strings.Join(names, ", ")
+}
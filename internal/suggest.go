@@ -0,0 +1,168 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DataBridgeTech/dbqcore"
+	"gopkg.in/yaml.v3"
+)
+
+// SuggestOptions control how much room suggested checks leave around profiled values
+type SuggestOptions struct {
+	// StatsSlackPct widens min/max/avg bounds by the percent of the profiled value
+	StatsSlackPct float64
+	// RowCountSlackPct widens row count bounds by the percent of the profiled row count
+	RowCountSlackPct float64
+}
+
+// SuggestChecksRule builds a checks file rule (in the format read by dbqcore.LoadChecksFileConfig)
+// with checks derived from the profiling results of a single dataset
+func SuggestChecksRule(dataSourceId string, dataset string, metrics *dbqcore.TableMetrics, opts SuggestOptions) *yaml.Node {
+	columns := make([]*dbqcore.ColumnMetrics, 0, len(metrics.ColumnsMetrics))
+	for _, column := range metrics.ColumnsMetrics {
+		columns = append(columns, column)
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		return columns[i].ColumnPosition < columns[j].ColumnPosition
+	})
+
+	checks := &yaml.Node{Kind: yaml.SequenceNode}
+
+	// schema
+	columnsOrder := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+	for _, column := range columns {
+		columnsOrder.Content = append(columnsOrder.Content, scalarNode(column.ColumnName))
+	}
+	checks.Content = append(checks.Content, mappingNode(
+		"schema_check", mappingNode(
+			"expect_columns_ordered", mappingNode("columns_order", columnsOrder),
+		),
+		"desc", quotedNode(fmt.Sprintf("Columns of %s should be in the profiled order", dataset)),
+		"on_fail", scalarNode(string(dbqcore.OnFailActionError)),
+	))
+
+	// volume
+	rowsMin := math.Floor(float64(metrics.TotalRows) * (1 - opts.RowCountSlackPct/100))
+	rowsMax := math.Ceil(float64(metrics.TotalRows) * (1 + opts.RowCountSlackPct/100))
+	checks.Content = append(checks.Content, suggestedCheck(
+		fmt.Sprintf("row_count between %s and %s", formatBound(math.Max(rowsMin, 0)), formatBound(rowsMax)),
+		fmt.Sprintf("Row count should stay within %g%% of the profiled %d rows", opts.RowCountSlackPct, metrics.TotalRows),
+		dbqcore.OnFailActionWarn))
+
+	for _, column := range columns {
+		if column.NullCount == 0 && metrics.TotalRows > 0 {
+			checks.Content = append(checks.Content, suggestedCheck(
+				fmt.Sprintf("not_null(%s)", column.ColumnName),
+				fmt.Sprintf("%s has no nulls in profiled data", column.ColumnName),
+				dbqcore.OnFailActionError))
+		}
+
+		if column.NullCount == 0 && looksLikeKeyColumn(dataset, column) {
+			checks.Content = append(checks.Content, suggestedCheck(
+				fmt.Sprintf("uniqueness(%s)", column.ColumnName),
+				fmt.Sprintf("%s looks like a key and should be unique", column.ColumnName),
+				dbqcore.OnFailActionError))
+		}
+
+		if column.MinValue != nil {
+			bound := widenBound(*column.MinValue, opts.StatsSlackPct, false)
+			checks.Content = append(checks.Content, suggestedCheck(
+				fmt.Sprintf("min(%s) >= %s", column.ColumnName, formatBound(bound)),
+				fmt.Sprintf("Minimum of %s should not drop below profiled %s (with %g%% slack)", column.ColumnName, formatBound(*column.MinValue), opts.StatsSlackPct),
+				dbqcore.OnFailActionWarn))
+		}
+
+		if column.MaxValue != nil {
+			bound := widenBound(*column.MaxValue, opts.StatsSlackPct, true)
+			checks.Content = append(checks.Content, suggestedCheck(
+				fmt.Sprintf("max(%s) <= %s", column.ColumnName, formatBound(bound)),
+				fmt.Sprintf("Maximum of %s should not exceed profiled %s (with %g%% slack)", column.ColumnName, formatBound(*column.MaxValue), opts.StatsSlackPct),
+				dbqcore.OnFailActionWarn))
+		}
+
+		if column.AvgValue != nil {
+			lower := widenBound(*column.AvgValue, opts.StatsSlackPct, false)
+			upper := widenBound(*column.AvgValue, opts.StatsSlackPct, true)
+			checks.Content = append(checks.Content, suggestedCheck(
+				fmt.Sprintf("avg(%s) between %s and %s", column.ColumnName, formatBound(lower), formatBound(upper)),
+				fmt.Sprintf("Average of %s should stay within %g%% of profiled %s", column.ColumnName, opts.StatsSlackPct, formatBound(*column.AvgValue)),
+				dbqcore.OnFailActionWarn))
+		}
+	}
+
+	return mappingNode(
+		"dataset", scalarNode(fmt.Sprintf("%s@[%s]", dataSourceId, dataset)),
+		"checks", checks,
+	)
+}
+
+// looksLikeKeyColumn is a naming heuristic, profiling results don't include distinct counts
+func looksLikeKeyColumn(dataset string, column *dbqcore.ColumnMetrics) bool {
+	name := strings.ToLower(column.ColumnName)
+	if name == "id" || name == "uuid" || name == "key" {
+		return true
+	}
+
+	table := strings.ToLower(dataset[strings.LastIndex(dataset, ".")+1:])
+	if name == table+"_id" || name == strings.TrimSuffix(table, "s")+"_id" {
+		return true
+	}
+
+	return column.ColumnPosition <= 1 && (strings.HasSuffix(name, "_id") || strings.HasSuffix(name, "_key") || strings.HasSuffix(name, "_uuid"))
+}
+
+// widenBound moves the value away by slackPct of its magnitude, up or down
+func widenBound(value float64, slackPct float64, up bool) float64 {
+	delta := math.Abs(value) * slackPct / 100
+	if up {
+		return value + delta
+	}
+	return value - delta
+}
+
+// formatBound rounds to 4 decimals and avoids exponent notation which check expressions don't support
+func formatBound(value float64) string {
+	return strconv.FormatFloat(math.Round(value*10000)/10000, 'f', -1, 64)
+}
+
+func suggestedCheck(expression string, desc string, onFail dbqcore.OnFailAction) *yaml.Node {
+	return mappingNode(expression, mappingNode(
+		"desc", quotedNode(desc),
+		"on_fail", scalarNode(string(onFail)),
+	))
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func quotedNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value, Style: yaml.DoubleQuotedStyle}
+}
+
+// mappingNode builds a mapping from key/value pairs where keys are strings and values are *yaml.Node
+func mappingNode(pairs ...any) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		node.Content = append(node.Content, scalarNode(pairs[i].(string)), pairs[i+1].(*yaml.Node))
+	}
+	return node
+}
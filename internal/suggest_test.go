@@ -0,0 +1,156 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DataBridgeTech/dbqcore"
+)
+
+func TestFormatBound(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0"},
+		{10, "10"},
+		{-2.5, "-2.5"},
+		{1.23456789, "1.2346"},
+		{0.00004, "0"},
+		{1e15, "1000000000000000"},
+		{123456789.5, "123456789.5"},
+	}
+
+	for _, tt := range tests {
+		if got := formatBound(tt.value); got != tt.want {
+			t.Errorf("formatBound(%g) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWidenBound(t *testing.T) {
+	tests := []struct {
+		value float64
+		up    bool
+		want  float64
+	}{
+		{100, true, 110},
+		{100, false, 90},
+		{-100, true, -90},
+		{-100, false, -110},
+		{0, true, 0},
+	}
+
+	for _, tt := range tests {
+		if got := widenBound(tt.value, 10, tt.up); got != tt.want {
+			t.Errorf("widenBound(%g, 10, %t) = %g, want %g", tt.value, tt.up, got, tt.want)
+		}
+	}
+}
+
+func TestLooksLikeKeyColumn(t *testing.T) {
+	tests := []struct {
+		dataset  string
+		column   string
+		position uint
+		want     bool
+	}{
+		{"public.orders", "id", 3, true},
+		{"public.orders", "UUID", 3, true},
+		{"public.orders", "order_id", 3, true},
+		{"public.orders", "orders_id", 3, true},
+		{"orders", "order_id", 3, true},
+		{"public.orders", "customer_id", 1, true},
+		{"public.orders", "customer_key", 1, true},
+		{"public.orders", "customer_id", 2, false},
+		{"public.orders", "name", 1, false},
+		{"public.orders", "idle", 1, false},
+	}
+
+	for _, tt := range tests {
+		column := &dbqcore.ColumnMetrics{ColumnName: tt.column, ColumnPosition: tt.position}
+		if got := looksLikeKeyColumn(tt.dataset, column); got != tt.want {
+			t.Errorf("looksLikeKeyColumn(%q, %q at %d) = %t, want %t", tt.dataset, tt.column, tt.position, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestChecksRule(t *testing.T) {
+	metrics := tableMetrics(1000,
+		&dbqcore.ColumnMetrics{ColumnName: "order_id", DataType: "UInt64", MinValue: float64Ptr(1), MaxValue: float64Ptr(1000), AvgValue: float64Ptr(500.5)},
+		&dbqcore.ColumnMetrics{ColumnName: "note", DataType: "String", NullCount: 10},
+		&dbqcore.ColumnMetrics{ColumnName: "delta", DataType: "Float64", NullCount: 1, MinValue: float64Ptr(-2.5), MaxValue: float64Ptr(0)},
+	)
+
+	rule := SuggestChecksRule("pg", "public.orders", metrics, SuggestOptions{StatsSlackPct: 10, RowCountSlackPct: 50})
+
+	if dataset := mappingValue(rule, "dataset"); dataset == nil || dataset.Value != "pg@[public.orders]" {
+		t.Fatalf("dataset = %+v", dataset)
+	}
+	checks := mappingValue(rule, "checks")
+	if checks == nil {
+		t.Fatal("rule has no checks")
+	}
+
+	var expressions []string
+	for _, check := range checks.Content {
+		expressions = append(expressions, check.Content[0].Value)
+	}
+	want := []string{
+		"schema_check",
+		"row_count between 500 and 1500",
+		"not_null(order_id)",
+		"uniqueness(order_id)",
+		"min(order_id) >= 0.9",
+		"max(order_id) <= 1100",
+		"avg(order_id) between 450.45 and 550.55",
+		"min(delta) >= -2.75",
+		"max(delta) <= 0",
+	}
+	if !reflect.DeepEqual(expressions, want) {
+		t.Fatalf("expressions = %q\nwant %q", expressions, want)
+	}
+
+	// suggested checks must be accepted by the checks file validation
+	for _, expression := range expressions {
+		if _, err := ParseCheckExpression(expression); err != nil {
+			t.Errorf("suggested check '%s' is invalid: %s", expression, err)
+		}
+	}
+
+	columnsOrder := mappingValue(mappingValue(mappingValue(checks.Content[0], "schema_check"), "expect_columns_ordered"), "columns_order")
+	var columns []string
+	for _, column := range columnsOrder.Content {
+		columns = append(columns, column.Value)
+	}
+	if !reflect.DeepEqual(columns, []string{"order_id", "note", "delta"}) {
+		t.Errorf("columns_order = %v", columns)
+	}
+}
+
+func TestSuggestChecksRuleEmptyDataset(t *testing.T) {
+	rule := SuggestChecksRule("pg", "public.empty", tableMetrics(0, &dbqcore.ColumnMetrics{ColumnName: "name", DataType: "String"}), SuggestOptions{RowCountSlackPct: 50})
+
+	var expressions []string
+	for _, check := range mappingValue(rule, "checks").Content {
+		expressions = append(expressions, check.Content[0].Value)
+	}
+	// not_null isn't suggested without rows to judge by
+	if want := []string{"schema_check", "row_count between 0 and 0"}; !reflect.DeepEqual(expressions, want) {
+		t.Errorf("expressions = %q, want %q", expressions, want)
+	}
+}
@@ -0,0 +1,504 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	templateNameRegex  = regexp.MustCompile(`^[A-Za-z_][\w-]*$`)
+	templateUseRegex   = regexp.MustCompile(`^([A-Za-z_][\w-]*)\s*(?:\((.*)\))?$`)
+	paramNameRegex     = regexp.MustCompile(`^\w+$`)
+	templateParamRegex = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+)
+
+// ChecksFileError is a problem of a checks file at the given location, e.g. an unknown template or a missing include
+type ChecksFileError struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *ChecksFileError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+}
+
+// checksTemplate is a named list of checks a rule can reuse with 'use', '{{param}}' placeholders
+// in the checks are replaced with the values given by the rule or the defaults of the template
+type checksTemplate struct {
+	name string
+	node *yaml.Node
+	// params lists parameter names along with their defaults, nil for required ones
+	params   []string
+	defaults map[string]*string
+	checks   *yaml.Node
+}
+
+// checksFileExpansion is the outcome of expanding includes and templates of a checks file
+type checksFileExpansion struct {
+	files nodeFiles
+	// included holds the content of every included file in load order
+	included [][]byte
+	// templates is the number of templates defined by the file and its includes
+	templates int
+	// unknown lists unknown keys of included files and template definitions
+	unknown []UnknownKey
+}
+
+type checksFileExpander struct {
+	files     nodeFiles
+	included  [][]byte
+	loaded    map[string]bool
+	templates map[string]*checksTemplate
+	unknown   []UnknownKey
+}
+
+// expandChecksFile merges the rules of included checks files into the checks file (before its own rules)
+// and replaces 'use' of every rule with the checks of the templates, so the result is a plain checks file.
+// Templates are shared by the checks file and all of its includes
+func expandChecksFile(path string, root *yaml.Node) (*checksFileExpansion, error) {
+	e := &checksFileExpander{
+		files:     nodeFiles{main: path, sources: make(map[*yaml.Node]string)},
+		loaded:    make(map[string]bool),
+		templates: make(map[string]*checksTemplate),
+	}
+	expansion := func() *checksFileExpansion {
+		return &checksFileExpansion{files: e.files, included: e.included, templates: len(e.templates), unknown: e.unknown}
+	}
+
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		return expansion(), nil
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	e.loaded[absPath] = true
+	rules, err := e.load(path, doc, []string{absPath})
+	if err != nil {
+		return nil, err
+	}
+	for _, ruleNode := range rules {
+		if err := e.expandRule(ruleNode); err != nil {
+			return nil, err
+		}
+	}
+
+	removeMappingKeys(doc, "include", "templates")
+	rulesNode := mappingValue(doc, "rules")
+	switch {
+	case rulesNode == nil && len(rules) > 0:
+		doc.Content = append(doc.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "rules"},
+			&yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: rules})
+	case rulesNode != nil && rulesNode.Kind == yaml.SequenceNode:
+		rulesNode.Content = rules
+	}
+	return expansion(), nil
+}
+
+// load registers templates of the checks file and returns its rules preceded by the rules of its includes,
+// stack lists absolute paths of the files including this one to detect include cycles
+func (e *checksFileExpander) load(file string, doc *yaml.Node, stack []string) ([]*yaml.Node, error) {
+	var rules []*yaml.Node
+	if includeNode := mappingValue(doc, "include"); includeNode != nil {
+		paths, err := e.includePaths(file, includeNode)
+		if err != nil {
+			return nil, err
+		}
+		for _, included := range paths {
+			absPath, err := filepath.Abs(included)
+			if err != nil {
+				return nil, err
+			}
+			if idx := slices.Index(stack, absPath); idx >= 0 {
+				cycle := append(slices.Clone(stack[idx:]), absPath)
+				return nil, e.errorAt(includeNode, "include cycle: %s", strings.Join(cycle, " -> "))
+			}
+			if e.loaded[absPath] {
+				// already included by another file, its rules must not run twice
+				continue
+			}
+			e.loaded[absPath] = true
+
+			includedRules, err := e.loadIncluded(included, includeNode, append(stack, absPath))
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, includedRules...)
+		}
+	}
+
+	if templatesNode := mappingValue(doc, "templates"); templatesNode != nil {
+		if err := e.addTemplates(templatesNode); err != nil {
+			return nil, err
+		}
+	}
+
+	if rulesNode := mappingValue(doc, "rules"); rulesNode != nil && rulesNode.Kind == yaml.SequenceNode {
+		rules = append(rules, rulesNode.Content...)
+	}
+	return rules, nil
+}
+
+// loadIncluded reads an included checks file and returns its rules
+func (e *checksFileExpander) loadIncluded(path string, includeNode *yaml.Node, stack []string) ([]*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, e.errorAt(includeNode, "failed to include checks file: %s", err)
+	}
+	e.included = append(e.included, data)
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		includeErr := &ChecksFileError{File: path, Msg: err.Error()}
+		if matches := yamlErrorLineRegex.FindStringSubmatch(err.Error()); matches != nil {
+			includeErr.Line, _ = strconv.Atoi(matches[1])
+			includeErr.Column = 1
+		}
+		return nil, includeErr
+	}
+	e.addSources(&root, path)
+
+	doc := &root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	if doc.Kind == yaml.DocumentNode || doc.Tag == "!!null" {
+		// empty file
+		return nil, nil
+	}
+	if doc.Kind != yaml.MappingNode {
+		return nil, e.errorAt(doc, "included checks file must be a mapping with 'rules', 'templates' or 'include'")
+	}
+
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key := doc.Content[i]
+		if !slices.Contains([]string{"version", "include", "templates", "rules"}, key.Value) {
+			e.unknown = append(e.unknown, UnknownKey{File: path, Path: key.Value, Line: key.Line, Column: key.Column})
+		}
+	}
+	return e.load(path, doc, stack)
+}
+
+// includePaths resolves the include paths (or glob patterns) relative to the directory of the including file
+func (e *checksFileExpander) includePaths(file string, includeNode *yaml.Node) ([]string, error) {
+	var patternNodes []*yaml.Node
+	switch includeNode.Kind {
+	case yaml.ScalarNode:
+		patternNodes = []*yaml.Node{includeNode}
+	case yaml.SequenceNode:
+		patternNodes = includeNode.Content
+	default:
+		return nil, e.errorAt(includeNode, "'include' must be a path or a list of paths")
+	}
+
+	var paths []string
+	for _, patternNode := range patternNodes {
+		pattern := strings.TrimSpace(patternNode.Value)
+		if patternNode.Kind != yaml.ScalarNode || pattern == "" {
+			return nil, e.errorAt(patternNode, "include path must be a non-empty string")
+		}
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(file), pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, e.errorAt(patternNode, "invalid include pattern '%s': %s", patternNode.Value, err)
+		}
+		if len(matches) == 0 {
+			return nil, e.errorAt(patternNode, "include '%s' matches no files", patternNode.Value)
+		}
+		sort.Strings(matches)
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// addTemplates registers templates defined either as a list of checks or as a mapping with 'params' and 'checks'
+func (e *checksFileExpander) addTemplates(templatesNode *yaml.Node) error {
+	if templatesNode.Kind != yaml.MappingNode {
+		return e.errorAt(templatesNode, "'templates' must be a mapping of template names to checks")
+	}
+
+	for i := 0; i+1 < len(templatesNode.Content); i += 2 {
+		nameNode, templateNode := templatesNode.Content[i], templatesNode.Content[i+1]
+		name := nameNode.Value
+		if !templateNameRegex.MatchString(name) {
+			return e.errorAt(nameNode, "invalid template name '%s', expected letters, digits, '_' or '-'", name)
+		}
+		if existing, ok := e.templates[name]; ok {
+			return e.errorAt(nameNode, "duplicate template '%s', already defined at %s:%d", name, e.files.of(existing.node), existing.node.Line)
+		}
+
+		template := &checksTemplate{name: name, node: nameNode, defaults: make(map[string]*string)}
+		switch templateNode.Kind {
+		case yaml.SequenceNode:
+			template.checks = templateNode
+		case yaml.MappingNode:
+			for j := 0; j+1 < len(templateNode.Content); j += 2 {
+				key, value := templateNode.Content[j], templateNode.Content[j+1]
+				switch key.Value {
+				case "desc":
+				case "params":
+					if err := e.addTemplateParams(template, value); err != nil {
+						return err
+					}
+				case "checks":
+					template.checks = value
+				default:
+					e.unknown = append(e.unknown, UnknownKey{File: e.files.of(key), Path: joinPath("templates."+name, key.Value), Line: key.Line, Column: key.Column})
+				}
+			}
+		default:
+			return e.errorAt(templateNode, "template '%s' must be a list of checks or a mapping with 'params' and 'checks'", name)
+		}
+		if template.checks == nil || template.checks.Kind != yaml.SequenceNode || len(template.checks.Content) == 0 {
+			return e.errorAt(nameNode, "template '%s' must have a non-empty list of checks", name)
+		}
+		e.templates[name] = template
+	}
+	return nil
+}
+
+// addTemplateParams reads template params, either a list of required params or a mapping of params to their defaults
+// (null for required ones)
+func (e *checksFileExpander) addTemplateParams(template *checksTemplate, paramsNode *yaml.Node) error {
+	addParam := func(nameNode *yaml.Node, defaultValue *string) error {
+		name := nameNode.Value
+		if nameNode.Kind != yaml.ScalarNode || !paramNameRegex.MatchString(name) {
+			return e.errorAt(nameNode, "invalid param name '%s' of template '%s'", name, template.name)
+		}
+		if name == "dataset" {
+			return e.errorAt(nameNode, "'dataset' can't be a template param, {{dataset}} is reserved for raw_query checks")
+		}
+		if _, ok := template.defaults[name]; ok {
+			return e.errorAt(nameNode, "duplicate param '%s' of template '%s'", name, template.name)
+		}
+		template.params = append(template.params, name)
+		template.defaults[name] = defaultValue
+		return nil
+	}
+
+	switch paramsNode.Kind {
+	case yaml.SequenceNode:
+		for _, nameNode := range paramsNode.Content {
+			if err := addParam(nameNode, nil); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(paramsNode.Content); i += 2 {
+			var defaultValue *string
+			if valueNode := paramsNode.Content[i+1]; valueNode.Tag != "!!null" {
+				if valueNode.Kind != yaml.ScalarNode {
+					return e.errorAt(valueNode, "default of param '%s' must be a scalar", paramsNode.Content[i].Value)
+				}
+				defaultValue = &valueNode.Value
+			}
+			if err := addParam(paramsNode.Content[i], defaultValue); err != nil {
+				return err
+			}
+		}
+	default:
+		return e.errorAt(paramsNode, "'params' of template '%s' must be a list of names or a mapping of names to defaults", template.name)
+	}
+	return nil
+}
+
+// expandRule replaces 'use' of the rule with checks of the templates, they go before the own checks of the rule
+func (e *checksFileExpander) expandRule(ruleNode *yaml.Node) error {
+	useNode := mappingValue(ruleNode, "use")
+	if useNode == nil {
+		return nil
+	}
+
+	var useNodes []*yaml.Node
+	switch useNode.Kind {
+	case yaml.ScalarNode:
+		useNodes = []*yaml.Node{useNode}
+	case yaml.SequenceNode:
+		useNodes = useNode.Content
+	default:
+		return e.errorAt(useNode, "'use' must be a template or a list of templates, e.g. [standard_pk(id_col=id)]")
+	}
+
+	var checks []*yaml.Node
+	for _, templateUseNode := range useNodes {
+		if templateUseNode.Kind != yaml.ScalarNode {
+			return e.errorAt(templateUseNode, "template use must be a string, e.g. standard_pk(id_col=id)")
+		}
+		name, args, err := parseTemplateUse(templateUseNode.Value)
+		if err != nil {
+			return e.errorAt(templateUseNode, "%s", err)
+		}
+		template, ok := e.templates[name]
+		if !ok {
+			return e.errorAt(templateUseNode, "unknown template '%s'%s", name, e.knownTemplates())
+		}
+		values, err := template.bind(args)
+		if err != nil {
+			return e.errorAt(templateUseNode, "%s", err)
+		}
+		for _, checkNode := range template.checks.Content {
+			checks = append(checks, e.cloneTemplateNode(checkNode, values))
+		}
+	}
+
+	removeMappingKeys(ruleNode, "use")
+	checksNode := mappingValue(ruleNode, "checks")
+	switch {
+	case checksNode == nil:
+		ruleNode.Content = append(ruleNode.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "checks"},
+			&yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: useNode.Line, Column: useNode.Column, Content: checks})
+	case checksNode.Kind == yaml.SequenceNode:
+		checksNode.Content = append(checks, checksNode.Content...)
+	case checksNode.Tag == "!!null":
+		*checksNode = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: checksNode.Line, Column: checksNode.Column, Content: checks}
+	}
+	return nil
+}
+
+func (e *checksFileExpander) knownTemplates() string {
+	if len(e.templates) == 0 {
+		return ", no templates are defined"
+	}
+	names := make([]string, 0, len(e.templates))
+	for name := range e.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf(" (expected one of: %s)", strings.Join(names, ", "))
+}
+
+// bind returns values of all params of the template, args override the defaults
+func (t *checksTemplate) bind(args map[string]string) (map[string]string, error) {
+	values := make(map[string]string, len(t.params))
+	for name, value := range args {
+		if _, ok := t.defaults[name]; !ok {
+			return nil, fmt.Errorf("template '%s' has no param '%s'%s", t.name, name, t.paramsHint())
+		}
+		values[name] = value
+	}
+	for _, name := range t.params {
+		if _, ok := values[name]; ok {
+			continue
+		}
+		if t.defaults[name] == nil {
+			return nil, fmt.Errorf("template '%s' requires param '%s', e.g. %s(%s=...)", t.name, name, t.name, name)
+		}
+		values[name] = *t.defaults[name]
+	}
+	return values, nil
+}
+
+func (t *checksTemplate) paramsHint() string {
+	if len(t.params) == 0 {
+		return ", it takes no params"
+	}
+	return fmt.Sprintf(" (expected one of: %s)", strings.Join(t.params, ", "))
+}
+
+// cloneTemplateNode deep copies the template node replacing '{{param}}' placeholders, unknown placeholders
+// (e.g. {{dataset}} of raw_query) are kept. Copies are attributed to the file of the template
+func (e *checksFileExpander) cloneTemplateNode(node *yaml.Node, values map[string]string) *yaml.Node {
+	clone := *node
+	if clone.Kind == yaml.ScalarNode {
+		clone.Value = templateParamRegex.ReplaceAllStringFunc(clone.Value, func(placeholder string) string {
+			if value, ok := values[templateParamRegex.FindStringSubmatch(placeholder)[1]]; ok {
+				return value
+			}
+			return placeholder
+		})
+	}
+	clone.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		clone.Content[i] = e.cloneTemplateNode(child, values)
+	}
+	e.files.sources[&clone] = e.files.of(node)
+	return &clone
+}
+
+func (e *checksFileExpander) addSources(node *yaml.Node, file string) {
+	e.files.sources[node] = file
+	for _, child := range node.Content {
+		e.addSources(child, file)
+	}
+}
+
+func (e *checksFileExpander) errorAt(node *yaml.Node, format string, args ...any) error {
+	return &ChecksFileError{File: e.files.of(node), Line: node.Line, Column: node.Column, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parseTemplateUse parses a template use, e.g. 'audit_columns' or "standard_pk(id_col=trip_id, desc='Trip id')"
+func parseTemplateUse(value string) (string, map[string]string, error) {
+	matches := templateUseRegex.FindStringSubmatch(strings.TrimSpace(value))
+	if matches == nil && strings.Count(value, "(") > strings.Count(value, ")") {
+		// commas separate items of yaml flow lists, e.g. [standard_pk(id_col=id, severity=warn)]
+		return "", nil, fmt.Errorf("invalid template use '%s', quote uses with several arguments, e.g. \"standard_pk(id_col=id, severity=warn)\"", value)
+	}
+	if matches == nil {
+		return "", nil, fmt.Errorf("invalid template use '%s', expected e.g. standard_pk(id_col=id)", value)
+	}
+
+	args := make(map[string]string)
+	for _, arg := range splitArgs(matches[2]) {
+		name, argValue, ok := strings.Cut(arg, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return "", nil, fmt.Errorf("invalid template argument '%s', expected name=value", strings.TrimSpace(arg))
+		}
+		if _, ok := args[name]; ok {
+			return "", nil, fmt.Errorf("duplicate template argument '%s'", name)
+		}
+		argValue = strings.TrimSpace(argValue)
+		if len(argValue) >= 2 && (argValue[0] == '\'' || argValue[0] == '"') && argValue[len(argValue)-1] == argValue[0] {
+			argValue = argValue[1 : len(argValue)-1]
+		}
+		args[name] = argValue
+	}
+	return matches[1], args, nil
+}
+
+func removeMappingKeys(mapping *yaml.Node, keys ...string) {
+	kept := mapping.Content[:0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if !slices.Contains(keys, mapping.Content[i].Value) {
+			kept = append(kept, mapping.Content[i], mapping.Content[i+1])
+		}
+	}
+	mapping.Content = kept
+}
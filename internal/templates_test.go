@@ -0,0 +1,304 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func ruleExpressions(rule ChecksRule) []string {
+	expressions := make([]string, len(rule.Checks))
+	for i, check := range rule.Checks {
+		expressions[i] = check.Expression
+	}
+	return expressions
+}
+
+func TestLoadChecksFileExpandsIncludesAndTemplates(t *testing.T) {
+	dir := writeChecksFiles(t, map[string]string{
+		"checks.yaml": `version: "1"
+include: [packs/*.yaml]
+templates:
+  standard_pk:
+    params:
+      id_col: id
+    checks:
+      - not_null({{id_col}}):
+          desc: "{{id_col}} is mandatory"
+      - uniqueness({{id_col}}):
+          desc: "{{id_col}} of {{dataset}} must be unique"
+rules:
+  - dataset: pg@[public.orders]
+    use: [standard_pk]
+    checks:
+      - row_count > 0
+  - dataset: pg@[public.customers]
+    use: "range(col=age, max='150')"
+`,
+		"packs/a.yaml": `templates:
+  range:
+    params: [col, max]
+    checks:
+      - max({{col}}) <= {{max}}
+rules:
+  - dataset: pg@[public.trips]
+    use: [standard_pk(id_col=trip_id)]
+`,
+		"packs/b.yaml": `rules:
+  - dataset: pg@[public.events]
+    checks:
+      - raw_query:
+          query: "select count() from {{dataset}}"
+`,
+	})
+
+	checksFile, err := LoadChecksFile(filepath.Join(dir, "checks.yaml"), false)
+	if err != nil {
+		t.Fatalf("LoadChecksFile() error = %v", err)
+	}
+
+	want := map[string][]string{
+		"pg@[public.trips]":     {"not_null(trip_id)", "uniqueness(trip_id)"},
+		"pg@[public.events]":    {"raw_query"},
+		"pg@[public.orders]":    {"not_null(id)", "uniqueness(id)", "row_count > 0"},
+		"pg@[public.customers]": {"max(age) <= 150"},
+	}
+	var datasets []string
+	for _, rule := range checksFile.Rules {
+		datasets = append(datasets, rule.Dataset)
+		if got := strings.Join(ruleExpressions(rule), "; "); got != strings.Join(want[rule.Dataset], "; ") {
+			t.Errorf("%s: checks = %s, want %s", rule.Dataset, got, strings.Join(want[rule.Dataset], "; "))
+		}
+	}
+	// included rules go first, in the sorted order of the glob matches
+	if got := strings.Join(datasets, ", "); got != "pg@[public.trips], pg@[public.events], pg@[public.orders], pg@[public.customers]" {
+		t.Errorf("rules order = %s", got)
+	}
+	if desc := checksFile.Rules[2].Checks[0].Description; desc != "id is mandatory" {
+		t.Errorf("template param not replaced in desc: %q", desc)
+	}
+	if desc := checksFile.Rules[2].Checks[1].Description; desc != "id of {{dataset}} must be unique" {
+		t.Errorf("unknown placeholder replaced in desc: %q", desc)
+	}
+}
+
+func TestLoadChecksFileIncludedFilesChangeHash(t *testing.T) {
+	dir := writeChecksFiles(t, map[string]string{
+		"checks.yaml": "include: pack.yaml\n",
+		"pack.yaml":   "rules:\n  - dataset: pg@[public.orders]\n    checks: [row_count > 0]\n",
+	})
+	first, err := LoadChecksFile(filepath.Join(dir, "checks.yaml"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "pack.yaml"), []byte("rules:\n  - dataset: pg@[public.orders]\n    checks: [row_count > 1]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	second, err := LoadChecksFile(filepath.Join(dir, "checks.yaml"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Hash == second.Hash {
+		t.Error("hash didn't change with the included file")
+	}
+}
+
+func TestExpandChecksFileErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		wantFile string
+		wantLine int
+		wantMsg  string
+	}{
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"checks.yaml": "include: a.yaml\n",
+				"a.yaml":      "include: b.yaml\n",
+				"b.yaml":      "rules: []\ninclude: [a.yaml]\n",
+			},
+			wantFile: "b.yaml",
+			wantLine: 2,
+			wantMsg:  "include cycle: ",
+		},
+		{
+			name: "self include",
+			files: map[string]string{
+				"checks.yaml": "rules: []\ninclude: checks.yaml\n",
+			},
+			wantFile: "checks.yaml",
+			wantLine: 2,
+			wantMsg:  "include cycle: ",
+		},
+		{
+			name: "glob with no matches",
+			files: map[string]string{
+				"checks.yaml": "include:\n  - packs/*.yaml\n",
+			},
+			wantFile: "checks.yaml",
+			wantLine: 2,
+			wantMsg:  "include 'packs/*.yaml' matches no files",
+		},
+		{
+			name: "missing param",
+			files: map[string]string{
+				"checks.yaml": `templates:
+  range:
+    params: [col]
+    checks:
+      - max({{col}}) < 10
+rules:
+  - dataset: pg@[public.orders]
+    use: [range]
+`,
+			},
+			wantFile: "checks.yaml",
+			wantLine: 8,
+			wantMsg:  "template 'range' requires param 'col', e.g. range(col=...)",
+		},
+		{
+			name: "unknown param",
+			files: map[string]string{
+				"checks.yaml": `templates:
+  range:
+    params: {col: id}
+    checks:
+      - max({{col}}) < 10
+rules:
+  - dataset: pg@[public.orders]
+    use: [range(column=id)]
+`,
+			},
+			wantFile: "checks.yaml",
+			wantLine: 8,
+			wantMsg:  "template 'range' has no param 'column' (expected one of: col)",
+		},
+		{
+			name: "unknown template",
+			files: map[string]string{
+				"checks.yaml": "include: pack.yaml\nrules:\n  - dataset: pg@[public.orders]\n    use:\n      - standard_fk\n",
+				"pack.yaml":   "templates:\n  standard_pk: [not_null(id)]\n",
+			},
+			wantFile: "checks.yaml",
+			wantLine: 5,
+			wantMsg:  "unknown template 'standard_fk' (expected one of: standard_pk)",
+		},
+		{
+			name: "unknown template without templates",
+			files: map[string]string{
+				"checks.yaml": "rules:\n  - dataset: pg@[public.orders]\n    use: standard_pk\n",
+			},
+			wantFile: "checks.yaml",
+			wantLine: 3,
+			wantMsg:  "unknown template 'standard_pk', no templates are defined",
+		},
+		{
+			name: "unquoted use with several arguments",
+			files: map[string]string{
+				"checks.yaml": "templates:\n  t: [row_count > 0]\nrules:\n  - dataset: pg@[public.orders]\n    use: [t(a=1, b=2)]\n",
+			},
+			wantFile: "checks.yaml",
+			wantLine: 5,
+			wantMsg:  "quote uses with several arguments",
+		},
+		{
+			name: "duplicate template across files",
+			files: map[string]string{
+				"checks.yaml": "include: pack.yaml\ntemplates:\n  t: [row_count > 0]\n",
+				"pack.yaml":   "templates:\n  t: [row_count > 1]\n",
+			},
+			wantFile: "checks.yaml",
+			wantLine: 3,
+			wantMsg:  "duplicate template 't', already defined at ",
+		},
+		{
+			name: "reserved dataset param",
+			files: map[string]string{
+				"checks.yaml": "templates:\n  t:\n    params: [dataset]\n    checks: [row_count > 0]\n",
+			},
+			wantFile: "checks.yaml",
+			wantLine: 3,
+			wantMsg:  "'dataset' can't be a template param",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeChecksFiles(t, tt.files)
+			_, err := LoadChecksFile(filepath.Join(dir, "checks.yaml"), false)
+
+			var fileErr *ChecksFileError
+			if !errors.As(err, &fileErr) {
+				t.Fatalf("LoadChecksFile() error = %v, want a ChecksFileError", err)
+			}
+			if filepath.Base(fileErr.File) != tt.wantFile || fileErr.Line != tt.wantLine {
+				t.Errorf("error at %s:%d, want %s:%d", filepath.Base(fileErr.File), fileErr.Line, tt.wantFile, tt.wantLine)
+			}
+			if !strings.Contains(fileErr.Msg, tt.wantMsg) {
+				t.Errorf("error = %q, want it to contain %q", fileErr.Msg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestParseTemplateUse(t *testing.T) {
+	tests := []struct {
+		use      string
+		wantName string
+		wantArgs map[string]string
+		wantErr  bool
+	}{
+		{use: "audit_columns", wantName: "audit_columns", wantArgs: map[string]string{}},
+		{use: "standard_pk()", wantName: "standard_pk", wantArgs: map[string]string{}},
+		{use: "standard_pk(id_col=trip_id)", wantName: "standard_pk", wantArgs: map[string]string{"id_col": "trip_id"}},
+		{
+			use:      "range(col=coalesce(a, b), desc='x, y', max=\"10\")",
+			wantName: "range",
+			wantArgs: map[string]string{"col": "coalesce(a, b)", "desc": "x, y", "max": "10"},
+		},
+		{use: "range(col)", wantErr: true},
+		{use: "range(col=a, col=b)", wantErr: true},
+		{use: "range(col=a", wantErr: true},
+		{use: "1range", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.use, func(t *testing.T) {
+			name, args, err := parseTemplateUse(tt.use)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTemplateUse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if name != tt.wantName {
+				t.Errorf("name = %q, want %q", name, tt.wantName)
+			}
+			if len(args) != len(tt.wantArgs) {
+				t.Fatalf("args = %v, want %v", args, tt.wantArgs)
+			}
+			for key, value := range tt.wantArgs {
+				if args[key] != value {
+					t.Errorf("args[%s] = %q, want %q", key, args[key], value)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,505 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	IssueSeverityError   = "error"
+	IssueSeverityWarning = "warning"
+)
+
+var yamlErrorLineRegex = regexp.MustCompile(`line (\d+)`)
+
+// ChecksFileIssue is a problem found in a checks file by ValidateChecksFile
+type ChecksFileIssue struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (i ChecksFileIssue) String() string {
+	if i.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", i.File, i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", i.File, i.Line, i.Column, i.Severity, i.Message)
+}
+
+type checksFileValidator struct {
+	path string
+	// files attributes nodes of included files and expanded templates to their files
+	files nodeFiles
+	// templates is the number of templates defined by the file and its includes
+	templates        int
+	dataSourceExists func(id string) bool
+	issues           []ChecksFileIssue
+	// checkIds maps ids of checks (explicit or derived from the position) to the line of the check
+	checkIds map[string]int
+}
+
+// ValidateChecksFile statically validates the checks file without connecting to any data source.
+// Unknown keys are reported as errors, or as warnings if lenient is set.
+// The returned error is only set if the file can't be read at all
+func ValidateChecksFile(path string, dataSourceExists func(id string) bool, lenient bool) ([]ChecksFileIssue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &checksFileValidator{path: path, files: nodeFiles{main: path}, dataSourceExists: dataSourceExists, checkIds: make(map[string]int)}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		v.addYamlError(err)
+		return v.issues, nil
+	}
+
+	// includes and templates are expanded first, so every check is validated the same way wherever it comes from
+	expansion, err := expandChecksFile(path, &root)
+	if err != nil {
+		v.addYamlError(err)
+		return v.issues, nil
+	}
+	v.files = expansion.files
+	v.templates = expansion.templates
+
+	v.validateRoot(&root)
+	v.validateKeys(&root, expansion.unknown, lenient)
+
+	// make sure dbqcore accepts everything the validator didn't catch
+	if len(v.issues) == 0 {
+		if _, err := LoadChecksFile(path, lenient); err != nil {
+			v.addYamlError(err)
+		}
+	}
+
+	sort.SliceStable(v.issues, func(i, j int) bool {
+		if v.issues[i].File != v.issues[j].File {
+			return v.issues[i].File == path || (v.issues[j].File != path && v.issues[i].File < v.issues[j].File)
+		}
+		if v.issues[i].Line != v.issues[j].Line {
+			return v.issues[i].Line < v.issues[j].Line
+		}
+		return v.issues[i].Column < v.issues[j].Column
+	})
+
+	return v.issues, nil
+}
+
+func (v *checksFileValidator) addIssue(node *yaml.Node, severity string, format string, args ...any) {
+	issue := ChecksFileIssue{File: v.path, Severity: severity, Message: fmt.Sprintf(format, args...)}
+	if node != nil {
+		issue.File, issue.Line, issue.Column = v.files.of(node), node.Line, node.Column
+	}
+	v.issues = append(v.issues, issue)
+}
+
+func (v *checksFileValidator) addYamlError(err error) {
+	var checksFileErr *ChecksFileError
+	if errors.As(err, &checksFileErr) {
+		v.issues = append(v.issues, ChecksFileIssue{
+			File:     checksFileErr.File,
+			Line:     checksFileErr.Line,
+			Column:   checksFileErr.Column,
+			Severity: IssueSeverityError,
+			Message:  checksFileErr.Msg,
+		})
+		return
+	}
+
+	issue := ChecksFileIssue{File: v.path, Severity: IssueSeverityError, Message: err.Error()}
+	if matches := yamlErrorLineRegex.FindStringSubmatch(err.Error()); matches != nil {
+		issue.Line, _ = strconv.Atoi(matches[1])
+		issue.Column = 1
+	}
+	v.issues = append(v.issues, issue)
+}
+
+// validateKeys reports unknown keys (e.g. misspelled 'desc'), unless another issue was already reported for the key,
+// includedUnknown are unknown keys of included files and template definitions found by expandChecksFile
+func (v *checksFileValidator) validateKeys(root *yaml.Node, includedUnknown []UnknownKey, lenient bool) {
+	severity := IssueSeverityError
+	if lenient {
+		severity = IssueSeverityWarning
+	}
+
+	type location struct {
+		file         string
+		line, column int
+	}
+	reported := make(map[location]bool, len(v.issues))
+	for _, issue := range v.issues {
+		reported[location{issue.File, issue.Line, issue.Column}] = true
+	}
+
+	for _, key := range findUnknownChecksFileKeys(v.files, root, includedUnknown) {
+		if reported[location{key.File, key.Line, key.Column}] {
+			continue
+		}
+		v.issues = append(v.issues, ChecksFileIssue{
+			File:     key.File,
+			Line:     key.Line,
+			Column:   key.Column,
+			Severity: severity,
+			Message:  fmt.Sprintf("unknown key '%s'", key.Path),
+		})
+	}
+}
+
+func (v *checksFileValidator) validateRoot(root *yaml.Node) {
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		v.addIssue(doc, IssueSeverityError, "checks file must be a mapping with 'version' and 'rules'")
+		return
+	}
+
+	rulesNode := mappingValue(doc, "rules")
+	if rulesNode == nil {
+		if v.templates == 0 {
+			v.addIssue(doc, IssueSeverityError, "missing 'rules' section")
+		}
+		// otherwise it's a set of templates shared by other checks files with 'include'
+		return
+	}
+	if rulesNode.Kind != yaml.SequenceNode {
+		v.addIssue(rulesNode, IssueSeverityError, "'rules' must be a list")
+		return
+	}
+	if len(rulesNode.Content) == 0 {
+		v.addIssue(rulesNode, IssueSeverityWarning, "'rules' list is empty")
+	}
+
+	for ruleIdx, ruleNode := range rulesNode.Content {
+		v.validateRule(ruleIdx, ruleNode)
+	}
+}
+
+func (v *checksFileValidator) validateRule(ruleIdx int, ruleNode *yaml.Node) {
+	if ruleNode.Kind != yaml.MappingNode {
+		v.addIssue(ruleNode, IssueSeverityError, "rule must be a mapping with 'dataset' and 'checks'")
+		return
+	}
+
+	ruleId := strconv.Itoa(ruleIdx + 1)
+	if idNode := mappingValue(ruleNode, "id"); idNode != nil {
+		if v.validateId(idNode) {
+			ruleId = strings.TrimSpace(idNode.Value)
+		}
+	}
+	if tagsNode := mappingValue(ruleNode, "tags"); tagsNode != nil {
+		v.validateTags(tagsNode)
+	}
+
+	var dataSourceId string
+	datasetNode := mappingValue(ruleNode, "dataset")
+	if datasetNode == nil {
+		v.addIssue(ruleNode, IssueSeverityError, "rule is missing 'dataset'")
+	} else if id, _, err := ParseDatasetString(datasetNode.Value); err != nil {
+		v.addIssue(datasetNode, IssueSeverityError, "%s", err)
+	} else {
+		dataSourceId = id
+		if v.dataSourceExists != nil && !v.dataSourceExists(dataSourceId) {
+			v.addIssue(datasetNode, IssueSeverityError, "data source '%s' not found in dbq configuration", dataSourceId)
+		}
+	}
+
+	if whereNode := mappingValue(ruleNode, "where"); whereNode != nil && whereNode.Kind != yaml.ScalarNode {
+		v.addIssue(whereNode, IssueSeverityError, "'where' must be a string")
+	}
+	ruleGrouped := false
+	for _, key := range []string{"group_by", "partition_by"} {
+		if groupNode := mappingValue(ruleNode, key); groupNode != nil {
+			ruleGrouped = v.validateGroupBy(key, groupNode) || ruleGrouped
+		}
+	}
+
+	checksNode := mappingValue(ruleNode, "checks")
+	if checksNode == nil {
+		v.addIssue(ruleNode, IssueSeverityError, "rule is missing 'checks'")
+		return
+	}
+	if checksNode.Kind != yaml.SequenceNode {
+		if checksNode.Tag == "!!null" {
+			v.addIssue(checksNode, IssueSeverityError, "'checks' list is empty")
+		} else {
+			v.addIssue(checksNode, IssueSeverityError, "'checks' must be a list")
+		}
+		return
+	}
+	if len(checksNode.Content) == 0 {
+		v.addIssue(checksNode, IssueSeverityError, "'checks' list is empty")
+		return
+	}
+
+	seen := make(map[string]int)
+	for checkIdx, checkNode := range checksNode.Content {
+		key, checkId := v.validateCheck(checkNode, dataSourceId, ruleGrouped)
+		if checkId == "" {
+			checkId = ruleId + "." + strconv.Itoa(checkIdx+1)
+		}
+		if firstLine, ok := v.checkIds[checkId]; ok {
+			v.addIssue(checkNode, IssueSeverityError, "duplicate check id '%s', same as the check on line %d", checkId, firstLine)
+		} else {
+			v.checkIds[checkId] = checkNode.Line
+		}
+
+		if key == "" {
+			continue
+		}
+		if firstLine, ok := seen[key]; ok {
+			v.addIssue(checkNode, IssueSeverityError, "duplicate check, same as the check on line %d", firstLine)
+		} else {
+			seen[key] = checkNode.Line
+		}
+	}
+}
+
+// validateCheck reports problems of a single check and returns its identity used to detect duplicates
+// along with its explicit id (if set), dataSourceId is the data source of the rule if known
+// and ruleGrouped tells whether the rule sets 'group_by' or 'partition_by'
+func (v *checksFileValidator) validateCheck(checkNode *yaml.Node, dataSourceId string, ruleGrouped bool) (string, string) {
+	var exprNode, bodyNode *yaml.Node
+	var settings []*yaml.Node
+
+	switch checkNode.Kind {
+	case yaml.ScalarNode:
+		exprNode = checkNode
+	case yaml.MappingNode:
+		if len(checkNode.Content) < 2 {
+			v.addIssue(checkNode, IssueSeverityError, "empty check")
+			return "", ""
+		}
+		exprNode, bodyNode = checkNode.Content[0], checkNode.Content[1]
+		settings = append(settings, checkNode.Content[2:]...)
+	default:
+		v.addIssue(checkNode, IssueSeverityError, "check must be an expression or a mapping with an expression key")
+		return "", ""
+	}
+
+	expr, err := ParseCheckExpression(exprNode.Value)
+	if err != nil {
+		v.addIssue(exprNode, IssueSeverityError, "%s", err)
+		return "", ""
+	}
+
+	identity := strings.Join(strings.Fields(strings.ToLower(exprNode.Value)), " ")
+
+	if expr.References != nil && dataSourceId != "" {
+		if err := expr.References.CheckDataSource(dataSourceId); err != nil {
+			v.addIssue(exprNode, IssueSeverityError, "%s", err)
+		}
+	}
+
+	if expr.Function == CheckFuncSchemaCheck {
+		v.validateSchemaCheck(exprNode, bodyNode)
+		identity += "|" + nodeFingerprint(bodyNode)
+	} else if bodyNode != nil && bodyNode.Kind == yaml.MappingNode {
+		settings = append(settings, bodyNode.Content...)
+	} else if bodyNode != nil && bodyNode.Tag != "!!null" {
+		v.addIssue(bodyNode, IssueSeverityError, "check settings must be a mapping (e.g. 'desc', 'on_fail')")
+	}
+
+	var queryNode, anomalyNode *yaml.Node
+	var checkId string
+	grouped := ruleGrouped && SupportsGrouping(exprNode.Value)
+	groupSet := false
+	for i := 0; i+1 < len(settings); i += 2 {
+		key, value := settings[i], settings[i+1]
+		switch key.Value {
+		case "on_fail":
+			if _, err := ParseSeverity(value.Value); err != nil {
+				v.addIssue(value, IssueSeverityError, "unknown on_fail value '%s' (expected one of: %s)", value.Value, severityNames())
+			}
+		case "query":
+			queryNode = value
+		case "id":
+			if v.validateId(value) {
+				checkId = strings.TrimSpace(value.Value)
+			}
+		case "tags":
+			v.validateTags(value)
+		case "timeout":
+			if _, err := time.ParseDuration(value.Value); err != nil {
+				v.addIssue(value, IssueSeverityError, "invalid timeout '%s' (expected e.g. 30s or 5m)", value.Value)
+			}
+		case "sample_failures":
+			if n, err := strconv.Atoi(value.Value); err != nil || n < 0 {
+				v.addIssue(value, IssueSeverityError, "invalid sample_failures '%s' (expected a non-negative number of rows)", value.Value)
+			} else if !SupportsFailedRows(exprNode.Value) {
+				v.addIssue(value, IssueSeverityError, "sample_failures is only supported by not_null, uniqueness and foreign_key checks")
+			}
+		case "group_by", "partition_by":
+			if !groupSet {
+				grouped, groupSet = false, true
+			}
+			if v.validateGroupBy(key.Value, value) {
+				grouped = true
+				if !SupportsGrouping(exprNode.Value) {
+					v.addIssue(key, IssueSeverityError, "%s is not supported by schema_check and raw_query checks", key.Value)
+				}
+			}
+			identity += "|" + key.Value + ":" + nodeFingerprint(value)
+		case "anomaly":
+			anomalyNode = value
+			var anomalyCfg AnomalyConfig
+			if err := value.Decode(&anomalyCfg); err != nil {
+				v.addIssue(value, IssueSeverityError, "invalid anomaly settings: %s", err)
+			} else if err := anomalyCfg.validate(); err != nil {
+				v.addIssue(value, IssueSeverityError, "%s", err)
+			}
+		}
+	}
+
+	if anomalyNode != nil && grouped {
+		v.addIssue(anomalyNode, IssueSeverityError, "anomaly detection is not supported by grouped checks, disable grouping of the check with 'group_by: []'")
+	}
+
+	if expr.Function == CheckFuncRawQuery {
+		if queryNode == nil || strings.TrimSpace(queryNode.Value) == "" {
+			v.addIssue(exprNode, IssueSeverityError, "raw_query check requires a non-empty 'query'")
+		} else {
+			if !strings.Contains(queryNode.Value, "{{dataset}}") {
+				v.addIssue(queryNode, IssueSeverityWarning, "query doesn't reference {{dataset}}, it will run the same way for every dataset of the rule")
+			}
+			identity += "|" + strings.Join(strings.Fields(queryNode.Value), " ")
+		}
+	}
+
+	return identity, checkId
+}
+
+// validateGroupBy reports 'group_by' values which are neither an expression nor a list of expressions
+// and 'partition_by' values which are not an expression, returns true if the value groups rows
+func (v *checksFileValidator) validateGroupBy(key string, groupNode *yaml.Node) bool {
+	switch {
+	case groupNode.Tag == "!!null":
+		return false
+	case groupNode.Kind == yaml.ScalarNode:
+		if strings.TrimSpace(groupNode.Value) == "" {
+			v.addIssue(groupNode, IssueSeverityError, "'%s' must be a non-empty expression", key)
+			return false
+		}
+		return true
+	case groupNode.Kind == yaml.SequenceNode && key == "group_by":
+		for _, itemNode := range groupNode.Content {
+			if itemNode.Kind != yaml.ScalarNode || strings.TrimSpace(itemNode.Value) == "" {
+				v.addIssue(itemNode, IssueSeverityError, "group_by expression must be a non-empty string")
+			}
+		}
+		return len(groupNode.Content) > 0
+	case key == "group_by":
+		v.addIssue(groupNode, IssueSeverityError, "'group_by' must be an expression or a list of expressions")
+	default:
+		v.addIssue(groupNode, IssueSeverityError, "'%s' must be an expression", key)
+	}
+	return false
+}
+
+// validateId reports ids which are not a non-empty string, returns true for a valid id
+func (v *checksFileValidator) validateId(idNode *yaml.Node) bool {
+	if idNode.Kind != yaml.ScalarNode || strings.TrimSpace(idNode.Value) == "" {
+		v.addIssue(idNode, IssueSeverityError, "'id' must be a non-empty string")
+		return false
+	}
+	return true
+}
+
+func (v *checksFileValidator) validateTags(tagsNode *yaml.Node) {
+	if tagsNode.Kind != yaml.SequenceNode {
+		v.addIssue(tagsNode, IssueSeverityError, "'tags' must be a list")
+		return
+	}
+	for _, tagNode := range tagsNode.Content {
+		if tagNode.Kind != yaml.ScalarNode || strings.TrimSpace(tagNode.Value) == "" {
+			v.addIssue(tagNode, IssueSeverityError, "tag must be a non-empty string")
+		}
+	}
+}
+
+func (v *checksFileValidator) validateSchemaCheck(exprNode *yaml.Node, bodyNode *yaml.Node) {
+	if bodyNode == nil || bodyNode.Kind != yaml.MappingNode || len(bodyNode.Content) == 0 {
+		v.addIssue(exprNode, IssueSeverityError, "schema_check requires one of: expect_columns_ordered, expect_columns, columns_not_present")
+		return
+	}
+	if len(bodyNode.Content) > 2 {
+		v.addIssue(bodyNode.Content[2], IssueSeverityError, "schema_check supports a single schema rule, move '%s' to a separate check", bodyNode.Content[2].Value)
+	}
+
+	kind, config := bodyNode.Content[0], bodyNode.Content[1]
+	requireList := func(key string) bool {
+		listNode := mappingValue(config, key)
+		if listNode == nil {
+			return false
+		}
+		if listNode.Kind != yaml.SequenceNode || len(listNode.Content) == 0 {
+			v.addIssue(listNode, IssueSeverityError, "'%s' must be a non-empty list", key)
+		}
+		return true
+	}
+
+	switch kind.Value {
+	case "expect_columns_ordered":
+		if !requireList("columns_order") {
+			v.addIssue(kind, IssueSeverityError, "expect_columns_ordered requires 'columns_order'")
+		}
+	case "expect_columns":
+		if !requireList("columns") {
+			v.addIssue(kind, IssueSeverityError, "expect_columns requires 'columns'")
+		}
+	case "columns_not_present":
+		hasColumns := requireList("columns")
+		if !hasColumns && mappingValue(config, "pattern") == nil {
+			v.addIssue(kind, IssueSeverityError, "columns_not_present requires 'columns' or 'pattern'")
+		}
+	default:
+		v.addIssue(kind, IssueSeverityError, "unknown schema check '%s' (expected one of: expect_columns_ordered, expect_columns, columns_not_present)", kind.Value)
+	}
+}
+
+// nodeFingerprint returns a canonical representation of the node content, ignoring style and comments
+func nodeFingerprint(node *yaml.Node) string {
+	if node == nil {
+		return ""
+	}
+	var b strings.Builder
+	switch node.Kind {
+	case yaml.ScalarNode:
+		b.WriteString(node.Value)
+	default:
+		b.WriteString("[")
+		for _, child := range node.Content {
+			b.WriteString(nodeFingerprint(child))
+			b.WriteString(",")
+		}
+		b.WriteString("]")
+	}
+	return b.String()
+}
@@ -0,0 +1,190 @@
+// Copyright 2025 The DBQ Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateChecksFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		lenient bool
+		want    []string
+	}{
+		{
+			name: "valid",
+			files: map[string]string{
+				"checks.yaml": `version: "1"
+rules:
+  - dataset: pg@[public.orders]
+    checks:
+      - max(created) < '2024-01-01 00:00:00'
+      - not_null(coalesce(a, b))
+      - row_count between 1 and 10:
+          on_fail: warn
+          timeout: 30s
+`,
+			},
+		},
+		{
+			name: "invalid expressions",
+			files: map[string]string{
+				"checks.yaml": `rules:
+  - dataset: pg@[public.orders]
+    checks:
+      - avg(price) > 'x'
+      - not_null(a, b)
+      - bogus(a)
+      - row_count
+      - max(a) < '2024
+      - not_null(coalesce(a, b)
+`,
+			},
+			want: []string{
+				"checks.yaml:4:9: error: invalid value ''x'' (expected a number)",
+				"checks.yaml:5:9: error: 'not_null' expects 1 argument(s), got 2",
+				"checks.yaml:6:9: error: unknown check function 'bogus'",
+				"checks.yaml:7:9: error: 'row_count' requires a comparison, e.g. 'row_count between 1 and 10' or 'row_count > 0'",
+				"checks.yaml:8:9: error: unterminated quote in 'max(a) < '2024'",
+				"checks.yaml:9:9: error: unbalanced parentheses in 'not_null(coalesce(a, b)'",
+			},
+		},
+		{
+			name: "invalid check settings",
+			files: map[string]string{
+				"checks.yaml": `rules:
+  - dataset: nope@[x]
+    checks:
+      - row_count > 0:
+          on_fail: sometimes
+          timeout: 5x
+      - uniqueness(id):
+          id: dup
+      - not_null(id):
+          id: dup
+`,
+			},
+			want: []string{
+				"checks.yaml:2:14: error: data source 'nope' not found in dbq configuration",
+				"checks.yaml:5:20: error: unknown on_fail value 'sometimes' (expected one of: info, warn, error, critical)",
+				"checks.yaml:6:20: error: invalid timeout '5x' (expected e.g. 30s or 5m)",
+				"checks.yaml:9:9: error: duplicate check id 'dup', same as the check on line 7",
+			},
+		},
+		{
+			name: "duplicate check",
+			files: map[string]string{
+				"checks.yaml": "rules:\n  - dataset: pg@[public.orders]\n    checks:\n      - row_count > 0\n      - row_count > 0\n",
+			},
+			want: []string{
+				"checks.yaml:5:9: error: duplicate check, same as the check on line 4",
+			},
+		},
+		{
+			name: "grouped check with anomaly",
+			files: map[string]string{
+				"checks.yaml": "rules:\n  - dataset: pg@[public.x]\n    checks:\n      - not_null(a):\n          group_by: a\n          anomaly: {method: stddev}\n",
+			},
+			want: []string{
+				"checks.yaml:6:20: error: anomaly detection is not supported by grouped checks, disable grouping of the check with 'group_by: []'",
+			},
+		},
+		{
+			name: "yaml syntax error",
+			files: map[string]string{
+				"checks.yaml": "rules:\n  - dataset: pg@[public.x]\n    checks:\n      - row_count > 0\n        on_fail: warn\n",
+			},
+			want: []string{
+				"checks.yaml:5:1: error: yaml: line 5: mapping values are not allowed in this context",
+			},
+		},
+		{
+			name: "issues of included files",
+			files: map[string]string{
+				"checks.yaml": `include: pack.yaml
+rules:
+  - dataset: pg@[public.orders]
+    use: [pk]
+    checks:
+      - row_count > 0:
+          on_fial: warn
+`,
+				"pack.yaml": `templates:
+  pk:
+    - not_null(id, x)
+rules:
+  - dataset: pg@[public.x]
+    colour: red
+    checks:
+      - row_count > 0
+`,
+			},
+			want: []string{
+				"checks.yaml:7:11: error: unknown key 'rules[1].checks[1].row_count > 0.on_fial'",
+				"pack.yaml:3:7: error: 'not_null' expects 1 argument(s), got 2",
+				"pack.yaml:6:5: error: unknown key 'rules[0].colour'",
+			},
+		},
+		{
+			name: "lenient unknown keys",
+			files: map[string]string{
+				"checks.yaml": "rules:\n  - dataset: pg@[public.x]\n    colour: red\n    checks:\n      - row_count > 0\n",
+			},
+			lenient: true,
+			want: []string{
+				"checks.yaml:3:5: warning: unknown key 'rules[0].colour'",
+			},
+		},
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"checks.yaml": "include: a.yaml\nrules: []\n",
+				"a.yaml":      "include:\n  - checks.yaml\n",
+			},
+			want: []string{
+				"a.yaml:2:3: error: include cycle: ",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeChecksFiles(t, tt.files)
+			issues, err := ValidateChecksFile(filepath.Join(dir, "checks.yaml"), func(id string) bool { return id != "nope" }, tt.lenient)
+			if err != nil {
+				t.Fatalf("ValidateChecksFile() error = %v", err)
+			}
+
+			got := make([]string, len(issues))
+			for i, issue := range issues {
+				issue.File = filepath.Base(issue.File)
+				got[i] = issue.String()
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d issue(s), want %d:\n%s", len(got), len(tt.want), strings.Join(got, "\n"))
+			}
+			for i := range tt.want {
+				// cycles are reported with absolute paths, only their prefix is compared
+				if !strings.HasPrefix(got[i], tt.want[i]) {
+					t.Errorf("issue %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -15,7 +15,12 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/DataBridgeTech/dbqctl/cmd"
 	"github.com/DataBridgeTech/dbqctl/internal"
@@ -24,16 +29,45 @@ import (
 )
 
 func main() {
+	// global flags needed to set up the app are parsed ahead of cobra, anywhere in the command line,
+	// everything else (including --help) is left to cobra
 	bootstrapFlagSet := pflag.NewFlagSet("bootstrap", pflag.ContinueOnError)
-	bootstrapFlagSet.SetInterspersed(false)
+	bootstrapFlagSet.ParseErrorsWhitelist.UnknownFlags = true
+	bootstrapFlagSet.Usage = func() {}
 
-	dbqConfigFile := bootstrapFlagSet.String("config", "", "config file (default is $HOME/.dbq.yaml or ./dbq.yaml)")
-	if err := bootstrapFlagSet.Parse(os.Args[1:]); err != nil {
+	dbqConfigFiles := bootstrapFlagSet.StringArray("config", nil, cmd.ConfigFlagUsage)
+	environment := bootstrapFlagSet.String("env", "", cmd.EnvFlagUsage)
+	lenient := bootstrapFlagSet.Bool("lenient", false, cmd.LenientFlagUsage)
+	var logOpts internal.LogOptions
+	bootstrapFlagSet.BoolVarP(&logOpts.Verbose, "verbose", "v", false, cmd.VerboseFlagUsage)
+	bootstrapFlagSet.StringVar(&logOpts.Level, "log-level", "", cmd.LogLevelFlagUsage)
+	bootstrapFlagSet.StringVar(&logOpts.Format, "log-format", internal.LogFormatText, cmd.LogFormatFlagUsage)
+	bootstrapFlagSet.StringVar(&logOpts.File, "log-file", "", cmd.LogFileFlagUsage)
+	if err := bootstrapFlagSet.Parse(os.Args[1:]); err != nil && !errors.Is(err, pflag.ErrHelp) {
 		cobra.CheckErr(err)
 	}
 
-	app := internal.NewDbqCliApp(*dbqConfigFile)
+	app := internal.NewDbqCliApp(internal.AppOptions{
+		ConfigFiles: *dbqConfigFiles,
+		Environment: *environment,
+		Lenient:     *lenient,
+		Log:         logOpts,
+	})
 
 	cmd.AddCommands(app)
-	cmd.Execute()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		// restore default signal handling, so a second Ctrl-C terminates immediately
+		<-ctx.Done()
+		stop()
+	}()
+
+	exitCode := cmd.Execute(ctx)
+
+	if err := app.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to close data source connections: %s\n", err)
+	}
+
+	os.Exit(exitCode)
 }